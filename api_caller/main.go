@@ -6,6 +6,12 @@ import (
 	"net/http"
 	"os"
 	"runtime/debug"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 )
 
 // LargeResponseSize is increased to 50 MB to heavily stress network I/O throughput.
@@ -14,6 +20,11 @@ const LargeResponseSize = 50 * 1024 * 1024 // 50 MB of data
 // LargePayload will hold a pre-allocated large byte slice of data.
 var LargePayload []byte
 
+// ChunkSize is the size of each write of the payload, set from CHUNK_SIZE.
+// 0 writes the whole payload at once. Smaller chunks mean more write syscalls,
+// which magnifies the per-write overhead of rootless user-space networking stacks.
+var ChunkSize int
+
 func init() {
 	// Initialize the large payload once at startup.
 	// We use simple bytes instead of strings for slightly better performance.
@@ -34,7 +45,7 @@ func stressHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Write the large payload. This forces high network throughput,
 	// which is the weakest area for rootless user-space networking stacks.
-	_, err := w.Write(LargePayload)
+	err := writePayload(w, LargePayload, ChunkSize)
 	if err != nil {
 		// Log error, but don't stop the server
 		log.Printf("Error writing response: %v", err)
@@ -50,6 +61,27 @@ func stressHandler(w http.ResponseWriter, r *http.Request) {
 	// No sleep to maximize throughput.
 }
 
+// writePayload writes the payload in chunks of chunkSize bytes, or at once when chunkSize is 0.
+// Each chunk is flushed so it reaches the socket as its own write instead of being coalesced by the response buffer.
+func writePayload(w http.ResponseWriter, payload []byte, chunkSize int) error {
+	if chunkSize <= 0 {
+		_, err := w.Write(payload)
+		return err
+	}
+
+	flusher, _ := w.(http.Flusher)
+	for start := 0; start < len(payload); start += chunkSize {
+		end := min(start+chunkSize, len(payload))
+		if _, err := w.Write(payload[start:end]); err != nil {
+			return err
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+	return nil
+}
+
 func main() {
 	port := os.Getenv("PORT")
 	if port == "" {
@@ -57,11 +89,59 @@ func main() {
 	}
 	addr := ":" + port
 
-	http.HandleFunc("/", stressHandler)
+	http.HandleFunc("/", instrument("/", stressHandler))
+	http.HandleFunc("/mixed", instrument("/mixed", mixedHandler))
+	http.Handle("/metrics", promhttp.Handler())
+
+	if value := os.Getenv("CHUNK_SIZE"); value != "" {
+		size, err := strconv.Atoi(value)
+		if err != nil || size < 0 {
+			log.Fatalf("Invalid CHUNK_SIZE %q: must be a non-negative number of bytes", value)
+		}
+		ChunkSize = size
+	}
+	if ChunkSize > 0 {
+		log.Printf("Writing payload in %d byte chunks (%d writes per response).", ChunkSize, (LargeResponseSize+ChunkSize-1)/ChunkSize)
+	} else {
+		log.Printf("Writing payload in a single write.")
+	}
+	loadMixedRatios()
+
+	// No read/write timeouts since a 50 MB response can legitimately take seconds on a slow stack,
+	// but slow-loris clients and idle keep-alive connections are still cut off.
+	server := &http.Server{
+		Addr:              addr,
+		ReadHeaderTimeout: 5 * time.Second,
+		IdleTimeout:       60 * time.Second,
+	}
+
+	// DISABLE_KEEPALIVE closes the connection after every response, so each request pays the
+	// TCP handshake and, for rootless, the user-space network stack's connection setup.
+	if value := os.Getenv("DISABLE_KEEPALIVE"); value != "" {
+		disable, err := strconv.ParseBool(value)
+		if err != nil {
+			log.Fatalf("Invalid DISABLE_KEEPALIVE %q: %v", value, err)
+		}
+		server.SetKeepAlivesEnabled(!disable)
+		log.Printf("Keep-alive enabled: %t", !disable)
+	}
+
+	// ENABLE_H2C serves HTTP/2 over plaintext next to HTTP/1.1, so multiplexed throughput can be
+	// compared across the rootful and rootless stacks. HTTP/1.1 stays the default.
+	if value := os.Getenv("ENABLE_H2C"); value != "" {
+		enable, err := strconv.ParseBool(value)
+		if err != nil {
+			log.Fatalf("Invalid ENABLE_H2C %q: %v", value, err)
+		}
+		if enable {
+			server.Handler = h2c.NewHandler(http.DefaultServeMux, &http2.Server{})
+		}
+		log.Printf("HTTP/2 cleartext enabled: %t", enable)
+	}
 
 	log.Printf("🔥 Starting EXTREME I/O Stress Server on port %s", port)
 
-	if err := http.ListenAndServe(addr, nil); err != nil {
+	if err := server.ListenAndServe(); err != nil {
 		log.Fatalf("Server failed to start: %v", err)
 	}
 }
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// requestDuration tracks how long each workload takes to serve, broken down by handler and method.
+// Comparing the same handler across the rootful and rootless instances isolates the overhead of each stack.
+var requestDuration = prometheus.NewHistogramVec(
+	prometheus.HistogramOpts{
+		Name:    "api_caller_request_duration_seconds",
+		Help:    "Time taken to serve a request",
+		Buckets: prometheus.ExponentialBuckets(0.001, 2, 15), // 1ms .. ~16s
+	},
+	[]string{"handler", "method"},
+)
+
+// knownMethods bounds the method label, anything else is reported as "other".
+var knownMethods = map[string]bool{
+	http.MethodGet:  true,
+	http.MethodHead: true,
+	http.MethodPost: true,
+}
+
+func init() {
+	prometheus.MustRegister(requestDuration)
+}
+
+// instrument wraps a handler so each request is observed in requestDuration under the given route.
+// Only requests for exactly that route get its label, so arbitrary paths caught by "/" can't blow up cardinality.
+func instrument(route string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		next(w, r)
+
+		handler := route
+		if r.URL.Path != route {
+			handler = "other"
+		}
+		method := r.Method
+		if !knownMethods[method] {
+			method = "other"
+		}
+		requestDuration.WithLabelValues(handler, method).Observe(time.Since(start).Seconds())
+	}
+}
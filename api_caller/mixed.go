@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Mixed500Ratio and Mixed429Ratio are the shares of /mixed responses that are 500 and 429, set from
+// MIXED_500_RATIO and MIXED_429_RATIO. The rest are 200.
+var (
+	Mixed500Ratio = 0.1
+	Mixed429Ratio = 0.1
+)
+
+// mixedResponses counts the status codes /mixed emitted, to check the configured distribution was served.
+var mixedResponses = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "api_caller_mixed_responses_total",
+		Help: "Responses served by /mixed by status code",
+	},
+	[]string{"code"},
+)
+
+func init() {
+	prometheus.MustRegister(mixedResponses)
+}
+
+// loadMixedRatios reads MIXED_500_RATIO and MIXED_429_RATIO, exiting on invalid values.
+func loadMixedRatios() {
+	for name, ratio := range map[string]*float64{"MIXED_500_RATIO": &Mixed500Ratio, "MIXED_429_RATIO": &Mixed429Ratio} {
+		value := os.Getenv(name)
+		if value == "" {
+			continue
+		}
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil || parsed < 0 || parsed > 1 {
+			log.Fatalf("Invalid %s %q: must be a number between 0 and 1", name, value)
+		}
+		*ratio = parsed
+	}
+	if Mixed500Ratio+Mixed429Ratio > 1 {
+		log.Fatalf("MIXED_500_RATIO and MIXED_429_RATIO must add up to at most 1, got %g", Mixed500Ratio+Mixed429Ratio)
+	}
+	log.Printf("/mixed responses: %.0f%% 500, %.0f%% 429, %.0f%% 200.", Mixed500Ratio*100, Mixed429Ratio*100, (1-Mixed500Ratio-Mixed429Ratio)*100)
+}
+
+// mixedHandler models a backend with errors by answering with a random status code in the configured ratios.
+// Error-heavy traffic exercises the connection reset and teardown paths that differ between the rootful and
+// rootless network stacks, so 500s also close the connection like a crashing backend would.
+func mixedHandler(w http.ResponseWriter, r *http.Request) {
+	code := http.StatusOK
+	switch roll := rand.Float64(); {
+	case roll < Mixed500Ratio:
+		code = http.StatusInternalServerError
+		w.Header().Set("Connection", "close")
+	case roll < Mixed500Ratio+Mixed429Ratio:
+		code = http.StatusTooManyRequests
+		w.Header().Set("Retry-After", "1")
+	}
+
+	w.Header().Set("Content-Type", "text/plain")
+	w.WriteHeader(code)
+	fmt.Fprintln(w, http.StatusText(code))
+	mixedResponses.WithLabelValues(strconv.Itoa(code)).Inc()
+}
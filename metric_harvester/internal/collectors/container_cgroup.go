@@ -0,0 +1,573 @@
+package collectors
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+// setContainerCPUThrottling sets the CPU throttling counters of each monitored container of a runtime
+// They show how often and for how long the CPU limit was enforced, which the stats CPU percentage averages away.
+// The counters are read from the container's cpu cgroup through the PID cached by setContainerPIDs,
+// which must run first. Containers whose cgroup can't be read, e.g. on macOS, are skipped
+func (c *ContainerCollector) setContainerCPUThrottling(ctx context.Context, runtime string, containerNames []string) {
+	c.containerThrottledPeriods.DeletePartialMatch(prometheus.Labels{"runtime": runtime})
+	c.containerThrottledSeconds.DeletePartialMatch(prometheus.Labels{"runtime": runtime})
+
+	for _, containerName := range containerNames {
+		if !c.isContainerMonitored(containerName) {
+			continue
+		}
+		pid, ok := c.pids[runtime+"/"+containerName]
+		if !ok || pid == "0" {
+			continue
+		}
+
+		periods, seconds, err := c.readCgroupCPUThrottling(ctx, pid)
+		if err != nil {
+			c.deps.Logger.Debug("Failed to read container CPU throttling from its cgroup",
+				zap.String("container", containerName),
+				zap.String("runtime", runtime),
+				zap.Error(err))
+			continue
+		}
+		c.containerThrottledPeriods.WithLabelValues(containerName, runtime).Set(periods)
+		c.containerThrottledSeconds.WithLabelValues(containerName, runtime).Set(seconds)
+	}
+}
+
+// readCgroupCPUThrottling reads the throttled periods and time of the cpu cgroup a process belongs to
+// The commands it runs are:
+// - cat /proc/<pid>/cgroup
+// - cat /sys/fs/cgroup/<path>/cpu.stat (cgroup v2)
+// - cat /sys/fs/cgroup/cpu/<path>/cpu.stat (cgroup v1)
+func (c *ContainerCollector) readCgroupCPUThrottling(ctx context.Context, pid string) (periods, seconds float64, err error) {
+	output, file, err := c.readCgroupFile(ctx, pid, "cpu", "cpu.stat", "cpu.stat")
+	if err != nil {
+		return 0, 0, err
+	}
+
+	periods, seconds, ok := parseCPUThrottling(output)
+	if !ok {
+		// v2 only has the throttling fields when the cpu controller is enabled for the cgroup
+		return 0, 0, fmt.Errorf("no throttling fields in %s", file)
+	}
+	return periods, seconds, nil
+}
+
+// setContainerCPUCores sets the CPU usage in cores of each monitored container of a runtime
+// The stats CPU percentage is hard to compare across hosts with different core counts and runtime versions,
+// while the cgroup usage counter is unambiguous: its rate in CPU seconds per second is the number of cores used.
+// The rate is averaged over metrics.rate_window cycles, so it appears from the second cycle on.
+// The counter is read through the PID cached by setContainerPIDs, which must run first
+func (c *ContainerCollector) setContainerCPUCores(ctx context.Context, runtime string, containerNames []string) {
+	c.containerCPUCores.DeletePartialMatch(prometheus.Labels{"runtime": runtime})
+
+	now := time.Now()
+	for _, containerName := range containerNames {
+		if !c.isContainerMonitored(containerName) {
+			continue
+		}
+		pid, ok := c.pids[runtime+"/"+containerName]
+		if !ok || pid == "0" {
+			continue
+		}
+
+		seconds, err := c.readCgroupCPUUsage(ctx, pid)
+		if err != nil {
+			c.deps.Logger.Debug("Failed to read container CPU usage from its cgroup",
+				zap.String("container", containerName),
+				zap.String("runtime", runtime),
+				zap.Error(err))
+			continue
+		}
+		if cores, ok := c.rates.Observe(containerName+"/"+runtime+"/cpu", seconds, now); ok {
+			c.containerCPUCores.WithLabelValues(containerName, runtime).Set(cores)
+		}
+	}
+}
+
+// readCgroupCPUUsage reads the total CPU time in seconds used by the cgroup a process belongs to
+// The commands it runs are:
+// - cat /proc/<pid>/cgroup
+// - cat /sys/fs/cgroup/<path>/cpu.stat (cgroup v2)
+// - cat /sys/fs/cgroup/cpuacct/<path>/cpuacct.usage (cgroup v1)
+func (c *ContainerCollector) readCgroupCPUUsage(ctx context.Context, pid string) (float64, error) {
+	output, file, err := c.readCgroupFile(ctx, pid, "cpuacct", "cpu.stat", "cpuacct.usage")
+	if err != nil {
+		return 0, err
+	}
+
+	// v1 cpuacct.usage is a single value in nanoseconds
+	if path.Base(file) == "cpuacct.usage" {
+		nanoseconds, err := strconv.ParseFloat(strings.TrimSpace(output), 64)
+		if err != nil {
+			return 0, fmt.Errorf("parsing %s: %w", file, err)
+		}
+		return nanoseconds / 1e9, nil
+	}
+
+	seconds, ok := parseCPUStatUsage(output)
+	if !ok {
+		return 0, fmt.Errorf("no usage_usec field in %s", file)
+	}
+	return seconds, nil
+}
+
+// setContainerSwap sets the swap usage and limit of each monitored container of a runtime
+// Rootless containers often run without swap, e.g. where the swap controller isn't delegated to the user.
+// The usage is read from the container's memory cgroup through the PID cached by setContainerPIDs, which must run first.
+// Without swap accounting the files don't exist and the metric is omitted
+func (c *ContainerCollector) setContainerSwap(ctx context.Context, runtime string, containerNames []string) {
+	c.containerSwap.DeletePartialMatch(prometheus.Labels{"runtime": runtime})
+
+	for _, containerName := range containerNames {
+		if !c.isContainerMonitored(containerName) {
+			continue
+		}
+		pid, ok := c.pids[runtime+"/"+containerName]
+		if !ok || pid == "0" {
+			continue
+		}
+
+		used, limit, hasLimit, err := c.readCgroupSwap(ctx, pid)
+		if err != nil {
+			c.deps.Logger.Debug("Failed to read container swap from its cgroup",
+				zap.String("container", containerName),
+				zap.String("runtime", runtime),
+				zap.Error(err))
+			continue
+		}
+		c.containerSwap.WithLabelValues(containerName, runtime, "used").Set(used)
+		if hasLimit {
+			c.containerSwap.WithLabelValues(containerName, runtime, "limit").Set(limit)
+		}
+	}
+}
+
+// readCgroupSwap reads the swap usage and limit of the memory cgroup a process belongs to
+// The limit is only known on cgroup v2 and left out when it is "max", i.e. unlimited
+// The commands it runs are:
+// - cat /proc/<pid>/cgroup
+// - cat /sys/fs/cgroup/<path>/memory.swap.current and memory.swap.max (cgroup v2)
+// - cat /sys/fs/cgroup/memory/<path>/memory.stat (cgroup v1)
+func (c *ContainerCollector) readCgroupSwap(ctx context.Context, pid string) (used, limit float64, hasLimit bool, err error) {
+	output, file, err := c.readCgroupFile(ctx, pid, "memory", "memory.swap.current", "memory.stat")
+	if err != nil {
+		return 0, 0, false, err
+	}
+
+	dir, name := path.Split(file)
+	if name == "memory.stat" {
+		swap, ok := parseMemoryStatSwap(output)
+		if !ok {
+			// v1 only reports swap with swap accounting enabled, e.g. swapaccount=1
+			return 0, 0, false, fmt.Errorf("no swap field in %s", file)
+		}
+		return swap, 0, false, nil
+	}
+
+	used, err = strconv.ParseFloat(strings.TrimSpace(output), 64)
+	if err != nil {
+		return 0, 0, false, fmt.Errorf("parsing %s: %w", file, err)
+	}
+
+	// The usage is still worth reporting when the limit can't be read
+	maxOutput, err := c.deps.Executor.Execute(ctx, "cat", dir+"memory.swap.max")
+	if err != nil {
+		return used, 0, false, nil
+	}
+	// "max" doesn't parse, leaving the limit out
+	limit, err = strconv.ParseFloat(strings.TrimSpace(string(maxOutput)), 64)
+	return used, limit, err == nil, nil
+}
+
+// setContainerWorkingSet sets the working set of each monitored container of a runtime
+// The stats memory usage includes page cache, which differs by storage driver, so this is the fairer number to compare.
+// It is computed like Kubernetes does, as the memory cgroup usage minus inactive_file from memory.stat.
+// The usage is read through the PID cached by setContainerPIDs, which must run first
+func (c *ContainerCollector) setContainerWorkingSet(ctx context.Context, runtime string, containerNames []string) {
+	c.containerWorkingSet.DeletePartialMatch(prometheus.Labels{"runtime": runtime})
+
+	for _, containerName := range containerNames {
+		if !c.isContainerMonitored(containerName) {
+			continue
+		}
+		pid, ok := c.pids[runtime+"/"+containerName]
+		if !ok || pid == "0" {
+			continue
+		}
+
+		workingSet, err := c.readCgroupWorkingSet(ctx, pid)
+		if err != nil {
+			c.deps.Logger.Debug("Failed to read container working set from its cgroup",
+				zap.String("container", containerName),
+				zap.String("runtime", runtime),
+				zap.Error(err))
+			continue
+		}
+		c.containerWorkingSet.WithLabelValues(containerName, runtime).Set(workingSet)
+	}
+}
+
+// readCgroupWorkingSet reads the usage of the memory cgroup a process belongs to minus its inactive file cache
+// The commands it runs are:
+// - cat /proc/<pid>/cgroup
+// - cat /sys/fs/cgroup/<path>/memory.current and memory.stat (cgroup v2)
+// - cat /sys/fs/cgroup/memory/<path>/memory.usage_in_bytes and memory.stat (cgroup v1)
+func (c *ContainerCollector) readCgroupWorkingSet(ctx context.Context, pid string) (float64, error) {
+	output, file, err := c.readCgroupFile(ctx, pid, "memory", "memory.current", "memory.usage_in_bytes")
+	if err != nil {
+		return 0, err
+	}
+
+	usage, err := strconv.ParseFloat(strings.TrimSpace(output), 64)
+	if err != nil {
+		return 0, fmt.Errorf("parsing %s: %w", file, err)
+	}
+
+	dir, _ := path.Split(file)
+	statOutput, err := c.deps.Executor.Execute(ctx, "cat", dir+"memory.stat")
+	if err != nil {
+		return 0, err
+	}
+	inactiveFile, ok := parseMemoryStatInactiveFile(string(statOutput))
+	if !ok {
+		return 0, fmt.Errorf("no inactive_file field in %smemory.stat", dir)
+	}
+
+	// The counters are read at slightly different times, so the difference can dip below 0
+	if inactiveFile > usage {
+		return 0, nil
+	}
+	return usage - inactiveFile, nil
+}
+
+// blockIOStats are the block I/O counters of a cgroup on one device
+type blockIOStats struct {
+	readBytes, writeBytes float64
+	readOps, writeOps     float64
+}
+
+// setContainerBlockIODevices sets the block I/O of each monitored container of a runtime per device
+// The stats block I/O sums all devices, while the io cgroup tells e.g. the overlay's backing disk from a volume's,
+// which matters when comparing storage driver overhead between rootful and rootless.
+// The counters are read through the PID cached by setContainerPIDs, which must run first. Devices are named from
+// /proc/partitions, which lists device mapper targets as dm-<n>, or by major:minor when they aren't listed there,
+// e.g. a device removed since or a hidden NVMe multipath path
+// The command it runs is:
+// - cat /proc/partitions
+func (c *ContainerCollector) setContainerBlockIODevices(ctx context.Context, runtime string, containerNames []string) {
+	c.containerBlockIODeviceBytes.DeletePartialMatch(prometheus.Labels{"runtime": runtime})
+	c.containerBlockIODeviceOps.DeletePartialMatch(prometheus.Labels{"runtime": runtime})
+
+	var devices map[string]string
+	for _, containerName := range containerNames {
+		if !c.isContainerMonitored(containerName) {
+			continue
+		}
+		pid, ok := c.pids[runtime+"/"+containerName]
+		if !ok || pid == "0" {
+			continue
+		}
+
+		stats, err := c.readCgroupBlockIO(ctx, pid)
+		if err != nil {
+			c.deps.Logger.Debug("Failed to read container block I/O from its cgroup",
+				zap.String("container", containerName),
+				zap.String("runtime", runtime),
+				zap.Error(err))
+			continue
+		}
+
+		// Read once per cycle, and only when a container has block I/O to name the devices of
+		if devices == nil && len(stats) > 0 {
+			devices = make(map[string]string)
+			if output, err := c.deps.Executor.Execute(ctx, "cat", "/proc/partitions"); err == nil {
+				devices = parsePartitions(string(output))
+			}
+		}
+		for number, stat := range stats {
+			device, ok := devices[number]
+			if !ok {
+				device = number
+			}
+			c.containerBlockIODeviceBytes.WithLabelValues(containerName, runtime, device, "read").Set(stat.readBytes)
+			c.containerBlockIODeviceBytes.WithLabelValues(containerName, runtime, device, "write").Set(stat.writeBytes)
+			c.containerBlockIODeviceOps.WithLabelValues(containerName, runtime, device, "read").Set(stat.readOps)
+			c.containerBlockIODeviceOps.WithLabelValues(containerName, runtime, device, "write").Set(stat.writeOps)
+		}
+	}
+}
+
+// readCgroupBlockIO reads the block I/O per device, keyed by major:minor, of the io cgroup a process belongs to
+// The commands it runs are:
+// - cat /proc/<pid>/cgroup
+// - cat /sys/fs/cgroup/<path>/io.stat (cgroup v2)
+// - cat /sys/fs/cgroup/blkio/<path>/blkio.throttle.io_service_bytes and blkio.throttle.io_serviced (cgroup v1)
+func (c *ContainerCollector) readCgroupBlockIO(ctx context.Context, pid string) (map[string]blockIOStats, error) {
+	output, file, err := c.readCgroupFile(ctx, pid, "blkio", "io.stat", "blkio.throttle.io_service_bytes")
+	if err != nil {
+		return nil, err
+	}
+	if path.Base(file) == "io.stat" {
+		return parseIOStat(output), nil
+	}
+
+	dir, _ := path.Split(file)
+	opsOutput, err := c.deps.Executor.Execute(ctx, "cat", dir+"blkio.throttle.io_serviced")
+	if err != nil {
+		return nil, err
+	}
+	stats := make(map[string]blockIOStats)
+	for number, bytes := range parseBlkioThrottle(output) {
+		stats[number] = blockIOStats{readBytes: bytes[0], writeBytes: bytes[1]}
+	}
+	for number, ops := range parseBlkioThrottle(string(opsOutput)) {
+		stat := stats[number]
+		stat.readOps, stat.writeOps = ops[0], ops[1]
+		stats[number] = stat
+	}
+	return stats, nil
+}
+
+// parseIOStat parses a cgroup v2 io.stat into major:minor -> counters
+// Example: "8:0 rbytes=1459200 wbytes=314773504 rios=192 wios=353 dbytes=0 dios=0"
+func parseIOStat(output string) map[string]blockIOStats {
+	stats := make(map[string]blockIOStats)
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+
+		var stat blockIOStats
+		for _, field := range fields[1:] {
+			key, value, ok := strings.Cut(field, "=")
+			if !ok {
+				continue
+			}
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			switch key {
+			case "rbytes":
+				stat.readBytes = parsed
+			case "wbytes":
+				stat.writeBytes = parsed
+			case "rios":
+				stat.readOps = parsed
+			case "wios":
+				stat.writeOps = parsed
+			}
+		}
+		stats[fields[0]] = stat
+	}
+	return stats
+}
+
+// parseBlkioThrottle parses a cgroup v1 blkio.throttle.io_service_bytes or io_serviced into major:minor -> [read, write]
+// Example:
+// "8:0 Read 1459200"
+// "8:0 Write 314773504"
+// "Total 316232704"
+func parseBlkioThrottle(output string) map[string][2]float64 {
+	counters := make(map[string][2]float64)
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			continue
+		}
+		value, err := strconv.ParseFloat(fields[2], 64)
+		if err != nil {
+			continue
+		}
+
+		counter := counters[fields[0]]
+		switch fields[1] {
+		case "Read":
+			counter[0] = value
+		case "Write":
+			counter[1] = value
+		default:
+			continue
+		}
+		counters[fields[0]] = counter
+	}
+	return counters
+}
+
+// parsePartitions parses /proc/partitions into major:minor -> device name
+// Example:
+// "major minor  #blocks  name"
+// ""
+// "   8        0  488386584 sda"
+func parsePartitions(output string) map[string]string {
+	devices := make(map[string]string)
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 4 {
+			continue
+		}
+		if _, err := strconv.Atoi(fields[0]); err != nil {
+			continue // The header
+		}
+		devices[fields[0]+":"+fields[1]] = fields[3]
+	}
+	return devices
+}
+
+// readCgroupFile reads a file of the cgroup a process belongs to for the given controller
+// v2File is read from the unified hierarchy, v1File from the controller's own hierarchy
+// Returns:
+// - string: contents of the file
+// - string: path of the file, for error messages
+// - error: error if the cgroup isn't found or the file can't be read
+func (c *ContainerCollector) readCgroupFile(ctx context.Context, pid, controller, v2File, v1File string) (string, string, error) {
+	output, err := c.deps.Executor.Execute(ctx, "cat", "/proc/"+pid+"/cgroup")
+	if err != nil {
+		return "", "", err
+	}
+
+	path, v2, ok := parseCgroupPath(string(output), controller)
+	if !ok {
+		return "", "", fmt.Errorf("no %s cgroup for pid %s", controller, pid)
+	}
+
+	file := "/sys/fs/cgroup" + path + "/" + v2File
+	if !v2 {
+		file = "/sys/fs/cgroup/" + controller + path + "/" + v1File
+	}
+	output, err = c.deps.Executor.Execute(ctx, "cat", file)
+	if err != nil {
+		return "", file, err
+	}
+	return string(output), file, nil
+}
+
+// parseCgroupPath finds the cgroup path of a controller in /proc/<pid>/cgroup
+// Example cgroup v2, a single unified hierarchy:
+// "0::/system.slice/docker-<id>.scope"
+// Example cgroup v1, one line per hierarchy:
+// "4:memory:/docker/<id>"
+// "3:cpu,cpuacct:/docker/<id>"
+func parseCgroupPath(output, controller string) (path string, v2 bool, ok bool) {
+	var unified string
+	for _, line := range strings.Split(output, "\n") {
+		parts := strings.SplitN(strings.TrimSpace(line), ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		if parts[0] == "0" && parts[1] == "" {
+			unified = parts[2]
+			continue
+		}
+		for _, name := range strings.Split(parts[1], ",") {
+			if name == controller {
+				return parts[2], false, true
+			}
+		}
+	}
+
+	// In hybrid setups the v1 hierarchy wins above, as the controller isn't delegated to the unified one
+	if unified != "" {
+		return unified, true, true
+	}
+	return "", false, false
+}
+
+// parseMemoryStatSwap parses the swap usage of the cgroup itself from a v1 memory.stat
+// Example:
+// "cache 1234"
+// "swap 5678"
+func parseMemoryStatSwap(output string) (float64, bool) {
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[0] == "swap" {
+			if value, err := strconv.ParseFloat(fields[1], 64); err == nil {
+				return value, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// parseMemoryStatInactiveFile parses the inactive file cache of a memory.stat
+// It is inactive_file in v2. v1 has both, total_inactive_file is used as it includes child cgroups like usage_in_bytes
+// Example:
+// "active_file 1234"
+// "inactive_file 5678"
+func parseMemoryStatInactiveFile(output string) (float64, bool) {
+	var inactiveFile float64
+	var found bool
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		value, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil {
+			continue
+		}
+		switch fields[0] {
+		case "total_inactive_file":
+			return value, true
+		case "inactive_file":
+			inactiveFile, found = value, true
+		}
+	}
+	return inactiveFile, found
+}
+
+// parseCPUStatUsage parses the total CPU time in seconds of a v2 cpu.stat
+// usage_usec is present whether or not the cpu controller is enabled for the cgroup
+// Example:
+// "usage_usec 123456789"
+// "user_usec 100000000"
+func parseCPUStatUsage(output string) (float64, bool) {
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[0] == "usage_usec" {
+			if value, err := strconv.ParseFloat(fields[1], 64); err == nil {
+				return value / 1e6, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// parseCPUThrottling parses the throttled periods and time of cpu.stat
+// The time is throttled_usec in v2 and throttled_time, in nanoseconds, in v1
+// Example:
+// "nr_periods 120"
+// "nr_throttled 12"
+// "throttled_usec 345678"
+func parseCPUThrottling(output string) (periods, seconds float64, ok bool) {
+	var foundPeriods, foundTime bool
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		value, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil {
+			continue
+		}
+		switch fields[0] {
+		case "nr_throttled":
+			periods, foundPeriods = value, true
+		case "throttled_usec":
+			seconds, foundTime = value/1e6, true
+		case "throttled_time":
+			seconds, foundTime = value/1e9, true
+		}
+	}
+	return periods, seconds, foundPeriods && foundTime
+}
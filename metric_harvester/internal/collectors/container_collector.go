@@ -2,9 +2,13 @@ package collectors
 
 import (
 	"context"
+	"metric_harvester/internal/utils"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"go.uber.org/zap"
@@ -19,6 +23,91 @@ type ContainerCollector struct {
 	containerNetIO   *prometheus.GaugeVec
 	containerBlockIO *prometheus.GaugeVec
 	containerStatus  *prometheus.GaugeVec
+
+	// containerMemoryHist: memory used observed every cycle, for monitored containers only
+	// It keeps the peaks and percentiles that the point-in-time gauge loses between scrapes
+	containerMemoryHist *prometheus.HistogramVec
+
+	// containerNetIORate: network I/O rate averaged over the last rate_window cycles
+	containerNetIORate *prometheus.GaugeVec
+	rates              *rateTracker
+
+	// Prometheus metrics refreshed from ps rather than stats
+	// Kept separate from the stats gauges so the image string doesn't multiply their cardinality
+	// containerInfo: always 1, carries the image label
+	// containerCreated: unix time the container was created
+	containerInfo    *prometheus.GaugeVec
+	containerCreated *prometheus.GaugeVec
+
+	// containerRootless: whether the container's runtime runs rootless (1) or rootful (0)
+	containerRootless *prometheus.GaugeVec
+
+	// containerCount: number of reported containers per runtime, from the same listing as the info metrics
+	containerCount *prometheus.GaugeVec
+
+	// containerPID: always 1, carries the host PID of the container's main process
+	containerPID *prometheus.GaugeVec
+
+	// containerOOMKills: OOM kills in the container's memory cgroup
+	// containerOOMKilled: 1/0 for whether the container's last exit was an OOM kill, where the cgroup can't be read
+	containerOOMKills  *prometheus.GaugeVec
+	containerOOMKilled *prometheus.GaugeVec
+
+	// containerThrottledPeriods and containerThrottledSeconds: CPU limit enforcement from the container's cpu cgroup
+	containerThrottledPeriods *prometheus.GaugeVec
+	containerThrottledSeconds *prometheus.GaugeVec
+
+	// containerCPUCores: CPU used in cores, from the usage counter of the container's cpu cgroup
+	containerCPUCores *prometheus.GaugeVec
+
+	// containerSwap: swap used and its limit from the container's memory cgroup
+	containerSwap *prometheus.GaugeVec
+
+	// containerWorkingSet: memory usage without inactive page cache, from the container's memory cgroup
+	containerWorkingSet *prometheus.GaugeVec
+
+	// containerBlockIODeviceBytes and containerBlockIODeviceOps: block I/O per device from the container's io cgroup
+	containerBlockIODeviceBytes *prometheus.GaugeVec
+	containerBlockIODeviceOps   *prometheus.GaugeVec
+
+	// containerInterface*: counters of each interface inside the container's network namespace, read through nsenter
+	containerInterfaceRxBytes   *prometheus.GaugeVec
+	containerInterfaceTxBytes   *prometheus.GaugeVec
+	containerInterfaceRxPackets *prometheus.GaugeVec
+	containerInterfaceTxPackets *prometheus.GaugeVec
+	containerInterfaceRxDropped *prometheus.GaugeVec
+	containerInterfaceTxDropped *prometheus.GaugeVec
+
+	// batchOffsets is the round-robin position per runtime when MaxPerCycle is set
+	// listedContainers is the container set of the previous listing per runtime, for StatsDelta and to keep the rates
+	// of containers outside the current batch
+	batchOffsets     map[string]int
+	listedContainers map[string]map[string]bool
+
+	// rootlessModes caches whether each runtime runs rootless since it rarely changes
+	rootlessModes map[string]rootlessMode
+
+	// ignoredNamePatterns are the compiled containers.ignored_name_patterns
+	ignoredNamePatterns []*regexp.Regexp
+
+	// pids caches the main PID per runtime/container
+	pids map[string]string
+
+	// procfs checks whether the collected host has /proc, pidUnsupported warns once when host PIDs can't be
+	// checked without it, e.g. on macOS
+	procfs         hostFileCheck
+	pidUnsupported unsupportedWarning
+
+	// netnsWarning warns once when nsenter fails, usually for lack of privileges
+	netnsWarning sync.Once
+
+	// cycleTraffic is the network rx + tx of each runtime/container whose stats were set this cycle,
+	// from which containers.max_reported keeps those with the most traffic
+	cycleTraffic   map[string]float64
+	cycleTrafficMu sync.Mutex
+
+	// reportedCapWarning warns once when containers.max_reported is first hit, later cycles log at debug
+	reportedCapWarning sync.Once
 }
 
 // NewContainerCollector creates a new ContainerCollector
@@ -28,7 +117,14 @@ type ContainerCollector struct {
 // - *ContainerCollector: new ContainerCollector instance
 func NewContainerCollector(deps *CollectorDependencies) *ContainerCollector {
 	return &ContainerCollector{
-		deps: deps,
+		deps:                deps,
+		batchOffsets:        make(map[string]int),
+		listedContainers:    make(map[string]map[string]bool),
+		rootlessModes:       make(map[string]rootlessMode),
+		pids:                make(map[string]string),
+		cycleTraffic:        make(map[string]float64),
+		rates:               newRateTracker(deps.Config.Metrics.RateWindow),
+		ignoredNamePatterns: compilePatterns(deps.Config.Containers.IgnoredNamePatterns),
 		containerCPU: prometheus.NewGaugeVec(
 			prometheus.GaugeOpts{
 				Name: "container_cpu_usage_percent",
@@ -43,6 +139,14 @@ func NewContainerCollector(deps *CollectorDependencies) *ContainerCollector {
 			},
 			[]string{"container", "runtime", "type"}, // used, limit
 		),
+		containerMemoryHist: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "container_memory_used_bytes_hist",
+				Help:    "Container memory used in bytes, observed every collection cycle",
+				Buckets: prometheus.ExponentialBuckets(1<<20, 2, 16), // 1MiB .. 32GiB
+			},
+			[]string{"container", "runtime"},
+		),
 		containerNetIO: prometheus.NewGaugeVec(
 			prometheus.GaugeOpts{
 				Name: "container_network_io_bytes",
@@ -57,6 +161,13 @@ func NewContainerCollector(deps *CollectorDependencies) *ContainerCollector {
 			},
 			[]string{"container", "runtime", "direction"}, // read, write
 		),
+		containerNetIORate: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "container_network_io_bytes_per_second",
+				Help: "Container network I/O rate in bytes per second, averaged over the rate window",
+			},
+			[]string{"container", "runtime", "direction"}, // rx, tx
+		),
 		containerStatus: prometheus.NewGaugeVec(
 			prometheus.GaugeOpts{
 				Name: "container_running",
@@ -64,6 +175,146 @@ func NewContainerCollector(deps *CollectorDependencies) *ContainerCollector {
 			},
 			[]string{"container", "runtime"},
 		),
+		containerInfo: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "container_info",
+				Help: "Container metadata, always 1",
+			},
+			[]string{"container", "runtime", "image"},
+		),
+		containerCreated: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "container_created_timestamp_seconds",
+				Help: "Unix time at which the container was created",
+			},
+			[]string{"container", "runtime"},
+		),
+		containerRootless: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "container_rootless",
+				Help: "Container runtime runs rootless or with user namespace remapping (1) or rootful (0)",
+			},
+			[]string{"container", "runtime"},
+		),
+		containerCount: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "container_count",
+				Help: "Number of containers seen for the runtime in the last collection",
+			},
+			[]string{"runtime"}, // docker, podman
+		),
+		containerPID: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "container_pid",
+				Help: "Host PID of the container's main process, always 1",
+			},
+			[]string{"container", "runtime", "pid"},
+		),
+		containerOOMKills: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "container_oom_killed_total",
+				Help: "Number of OOM kills in the container's memory cgroup",
+			},
+			[]string{"container", "runtime"},
+		),
+		containerOOMKilled: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "container_oom_killed",
+				Help: "Whether the container's last exit was an OOM kill (1) or not (0), from inspect where its cgroup can't be read",
+			},
+			[]string{"container", "runtime"},
+		),
+		containerThrottledPeriods: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "container_cpu_throttled_periods_total",
+				Help: "Number of CPU periods in which the container was throttled by its CPU limit",
+			},
+			[]string{"container", "runtime"},
+		),
+		containerThrottledSeconds: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "container_cpu_throttled_seconds_total",
+				Help: "Total time the container was throttled by its CPU limit in seconds",
+			},
+			[]string{"container", "runtime"},
+		),
+		containerCPUCores: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "container_cpu_cores",
+				Help: "Container CPU usage in cores, averaged over the rate window",
+			},
+			[]string{"container", "runtime"},
+		),
+		containerSwap: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "container_swap_usage_bytes",
+				Help: "Container swap usage in bytes",
+			},
+			[]string{"container", "runtime", "type"}, // used, limit
+		),
+		containerWorkingSet: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "container_working_set_bytes",
+				Help: "Container memory usage minus inactive file cache in bytes",
+			},
+			[]string{"container", "runtime"},
+		),
+		containerBlockIODeviceBytes: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "container_block_io_device_bytes_total",
+				Help: "Bytes the container read from or wrote to a block device, from its io cgroup",
+			},
+			[]string{"container", "runtime", "device", "direction"}, // read, write
+		),
+		containerBlockIODeviceOps: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "container_block_io_device_operations_total",
+				Help: "Read or write operations of the container on a block device, from its io cgroup",
+			},
+			[]string{"container", "runtime", "device", "direction"}, // read, write
+		),
+		containerInterfaceRxBytes: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "container_interface_rx_bytes_total",
+				Help: "Bytes received by an interface inside the container's network namespace",
+			},
+			[]string{"container", "runtime", "interface"},
+		),
+		containerInterfaceTxBytes: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "container_interface_tx_bytes_total",
+				Help: "Bytes transmitted by an interface inside the container's network namespace",
+			},
+			[]string{"container", "runtime", "interface"},
+		),
+		containerInterfaceRxPackets: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "container_interface_rx_packets_total",
+				Help: "Packets received by an interface inside the container's network namespace",
+			},
+			[]string{"container", "runtime", "interface"},
+		),
+		containerInterfaceTxPackets: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "container_interface_tx_packets_total",
+				Help: "Packets transmitted by an interface inside the container's network namespace",
+			},
+			[]string{"container", "runtime", "interface"},
+		),
+		containerInterfaceRxDropped: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "container_interface_rx_dropped_total",
+				Help: "Received packets dropped by an interface inside the container's network namespace",
+			},
+			[]string{"container", "runtime", "interface"},
+		),
+		containerInterfaceTxDropped: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "container_interface_tx_dropped_total",
+				Help: "Transmitted packets dropped by an interface inside the container's network namespace",
+			},
+			[]string{"container", "runtime", "interface"},
+		),
 	}
 }
 
@@ -71,126 +322,320 @@ func (c *ContainerCollector) Name() string {
 	return "container"
 }
 
+func (c *ContainerCollector) RequiredCommands() []string {
+	var commands []string
+	// The Docker API socket replaces the docker CLI
+	if c.deps.Config.Containers.DockerEnabled && c.deps.DockerAPI == nil {
+		commands = append(commands, c.deps.Config.Containers.DockerPath)
+	}
+	if c.deps.Config.Containers.PodmanEnabled {
+		if c.deps.Config.Containers.PodmanUser != "" {
+			commands = append(commands, "sudo")
+		}
+		commands = append(commands, c.deps.Config.Containers.PodmanPath)
+	}
+	if c.deps.Config.Containers.EnableNetnsStats {
+		commands = append(commands, "nsenter")
+	}
+	return commands
+}
+
 func (c *ContainerCollector) Describe(ch chan<- *prometheus.Desc) {
 	c.containerCPU.Describe(ch)
 	c.containerMemory.Describe(ch)
+	c.containerMemoryHist.Describe(ch)
 	c.containerNetIO.Describe(ch)
+	c.containerNetIORate.Describe(ch)
 	c.containerBlockIO.Describe(ch)
 	c.containerStatus.Describe(ch)
+	c.containerInfo.Describe(ch)
+	c.containerCreated.Describe(ch)
+	c.containerRootless.Describe(ch)
+	c.containerCount.Describe(ch)
+	c.containerPID.Describe(ch)
+	c.containerOOMKills.Describe(ch)
+	c.containerOOMKilled.Describe(ch)
+	c.containerThrottledPeriods.Describe(ch)
+	c.containerThrottledSeconds.Describe(ch)
+	c.containerCPUCores.Describe(ch)
+	c.containerSwap.Describe(ch)
+	c.containerWorkingSet.Describe(ch)
+	c.containerBlockIODeviceBytes.Describe(ch)
+	c.containerBlockIODeviceOps.Describe(ch)
+	for _, vec := range c.containerInterfaceStats() {
+		vec.Describe(ch)
+	}
 }
 
 func (c *ContainerCollector) Collect(ch chan<- prometheus.Metric) {
 	c.containerCPU.Collect(ch)
 	c.containerMemory.Collect(ch)
+	c.containerMemoryHist.Collect(ch)
 	c.containerNetIO.Collect(ch)
+	c.containerNetIORate.Collect(ch)
 	c.containerBlockIO.Collect(ch)
 	c.containerStatus.Collect(ch)
+	c.containerInfo.Collect(ch)
+	c.containerCreated.Collect(ch)
+	c.containerRootless.Collect(ch)
+	c.containerCount.Collect(ch)
+	c.containerPID.Collect(ch)
+	c.containerOOMKills.Collect(ch)
+	c.containerOOMKilled.Collect(ch)
+	c.containerThrottledPeriods.Collect(ch)
+	c.containerThrottledSeconds.Collect(ch)
+	c.containerCPUCores.Collect(ch)
+	c.containerSwap.Collect(ch)
+	c.containerWorkingSet.Collect(ch)
+	c.containerBlockIODeviceBytes.Collect(ch)
+	c.containerBlockIODeviceOps.Collect(ch)
+	for _, vec := range c.containerInterfaceStats() {
+		vec.Collect(ch)
+	}
 }
 
 // CollectMetrics collects container metrics
 // This is the main function that collects all the container metrics
 // The commands it runs are:
-// - docker stats --no-stream --format "table {{.Container}}\\t{{.CPUPerc}}\\t{{.MemUsage}}\\t{{.NetIO}}\\t{{.BlockIO}}"
-// - podman stats --no-stream --format "table {{.Name}}\\t{{.CPUPerc}}\\t{{.MemUsage}}\\t{{.NetIO}}\\t{{.BlockIO}}"
+// - docker stats --no-stream [--no-trunc] --format "table {{.Container}}\\t{{.CPUPerc}}\\t{{.MemUsage}}\\t{{.NetIO}}\\t{{.BlockIO}}"
+// - podman stats --no-stream [--no-trunc] --format "table {{.Name}}\\t{{.CPUPerc}}\\t{{.MemUsage}}\\t{{.NetIO}}\\t{{.BlockIO}}"
+// When a docker socket is configured, Docker stats are read from the API instead of the CLI
+// It returns a *CollectionError describing which runtimes failed, if any
 func (c *ContainerCollector) CollectMetrics(ctx context.Context) error {
 	c.deps.Logger.Debug("Collecting container metrics")
 
+	result := NewCollectionError(c.Name())
+
 	// Collect Docker metrics if enabled
 	if c.deps.Config.Containers.DockerEnabled {
-		if err := c.collectDockerMetrics(ctx); err != nil {
+		collectDocker := c.collectDockerMetrics
+		if c.deps.DockerAPI != nil {
+			collectDocker = c.collectDockerAPIMetrics
+		}
+		if err := result.Record("docker", collectDocker(ctx)); err != nil {
 			c.deps.Logger.Error("Failed to collect Docker metrics", zap.Error(err))
 		}
+		c.capReportedContainers("docker")
+
+		collectDockerInfo := c.collectDockerInfo
+		if c.deps.DockerAPI != nil {
+			collectDockerInfo = c.collectDockerAPIInfo
+		}
+		if err := result.Record("docker_info", collectDockerInfo(ctx)); err != nil {
+			c.deps.Logger.Error("Failed to collect Docker container info", zap.Error(err))
+		}
 	}
 
 	// Collect Podman metrics if enabled
 	if c.deps.Config.Containers.PodmanEnabled {
-		if err := c.collectPodmanMetrics(ctx); err != nil {
+		if err := result.Record("podman", c.collectPodmanMetrics(ctx)); err != nil {
 			c.deps.Logger.Error("Failed to collect Podman metrics", zap.Error(err))
 		}
+		c.capReportedContainers("podman")
+
+		if err := result.Record("podman_info", c.collectPodmanInfo(ctx)); err != nil {
+			c.deps.Logger.Error("Failed to collect Podman container info", zap.Error(err))
+		}
 	}
 
-	return nil
+	// Containers outside this cycle's batch of MaxPerCycle keep their rates while they are still listed
+	c.rates.Prune(func(key string) bool {
+		parts := strings.SplitN(key, "/", 3)
+		return len(parts) == 3 && c.listedContainers[parts[1]][parts[0]]
+	})
+
+	// Share the PIDs with collectors that join host process metrics to containers later in the cycle
+	pids := make(map[string]string, len(c.pids))
+	for key, pid := range c.pids {
+		pids[key] = pid
+	}
+	CycleStateFrom(ctx).Set(ContainerPIDsKey, pids)
+
+	return result.ErrOrNil()
 }
 
+// statsFunc gets stats for a single container, or for all containers when the name is empty
+type statsFunc func(ctx context.Context, containerName string) ([]byte, error)
+
 // collectDockerMetrics collects Docker metrics
 // If MonitoredNames is specified, it gets stats only for those containers
 // Otherwise, it gets stats for all containers
 func (c *ContainerCollector) collectDockerMetrics(ctx context.Context) error {
+	return c.collectRuntimeMetrics(ctx, "docker", c.deps.Executor.GetDockerStats, c.deps.Executor.ListDockerContainers)
+}
+
+// collectPodmanMetrics collects Podman metrics
+// If MonitoredNames is specified, it gets stats only for those containers
+// Otherwise, it gets stats for all containers
+func (c *ContainerCollector) collectPodmanMetrics(ctx context.Context) error {
+	return c.collectRuntimeMetrics(ctx, "podman", c.deps.Executor.GetPodmanStats, c.deps.Executor.ListPodmanContainers)
+}
+
+// collectRuntimeMetrics collects metrics for one container runtime
+// If MonitoredNames is specified, it gets stats only for those containers
+// If MaxPerCycle is set, it lists the containers and gets stats for at most MaxPerCycle of them,
+// moving round-robin through the list so every container is covered over several cycles
+// Otherwise, it gets stats for all containers in a single call
+func (c *ContainerCollector) collectRuntimeMetrics(ctx context.Context, runtime string, getStats statsFunc, listContainers func(ctx context.Context) ([]byte, error)) error {
 	// If specific containers are configured, get stats for each one
 	if len(c.deps.Config.Containers.MonitoredNames) > 0 {
-		for _, containerName := range c.deps.Config.Containers.MonitoredNames {
-			// Skip ignored containers
-			if c.isContainerIgnored(containerName) {
-				continue
-			}
-
-			output, err := c.deps.Executor.GetDockerStats(ctx, containerName)
-			if err != nil {
-				c.deps.Logger.Warn("Failed to get stats for container",
-					zap.String("container", containerName),
-					zap.Error(err))
-				continue
-			}
+		c.collectStatsByName(ctx, runtime, c.deps.Config.Containers.MonitoredNames, getStats)
+		return nil
+	}
 
-			if err := c.parseContainerStats(string(output), "docker"); err != nil {
-				c.deps.Logger.Warn("Failed to parse stats for container",
-					zap.String("container", containerName),
-					zap.Error(err))
-			}
+	// Bound the cost on busy hosts by covering only a batch of containers per cycle
+	if c.deps.Config.Containers.MaxPerCycle > 0 {
+		output, err := listContainers(ctx)
+		if err != nil {
+			return err
 		}
+
+		batch := c.nextContainerBatch(runtime, utils.ParseCommandOutput(output, "\n"))
+		c.collectStatsByName(ctx, runtime, batch, getStats)
 		return nil
 	}
 
 	// Get stats for all containers
-	output, err := c.deps.Executor.GetDockerStats(ctx, "")
+	output, err := getStats(ctx, "")
 	if err != nil {
 		return err
 	}
 
-	return c.parseContainerStats(string(output), "docker")
+	return c.parseContainerStats(string(output), runtime)
 }
 
-// collectPodmanMetrics collects Podman metrics
-// If MonitoredNames is specified, it gets stats only for those containers
-// Otherwise, it gets stats for all containers
-func (c *ContainerCollector) collectPodmanMetrics(ctx context.Context) error {
-	// If specific containers are configured, get stats for each one
-	if len(c.deps.Config.Containers.MonitoredNames) > 0 {
-		for _, containerName := range c.deps.Config.Containers.MonitoredNames {
-			// Skip ignored containers
-			if c.isContainerIgnored(containerName) {
-				continue
-			}
+// statsParseFunc parses the stats output of a single container and sets the container metrics
+type statsParseFunc func(output []byte, containerName string) error
+
+// collectStatsByName gets and parses stats for each named container from the CLI
+func (c *ContainerCollector) collectStatsByName(ctx context.Context, runtime string, containerNames []string, getStats statsFunc) {
+	c.collectStatsConcurrently(ctx, containerNames, getStats, func(output []byte, _ string) error {
+		return c.parseContainerStats(string(output), runtime)
+	})
+}
+
+// collectStatsConcurrently gets and parses stats for each named container
+// Up to StatsConcurrency containers are queried at the same time since each stats call is independent
+// Failures for a single container are logged and don't stop the others
+// Containers not yet started when the context is done are skipped
+func (c *ContainerCollector) collectStatsConcurrently(ctx context.Context, containerNames []string, getStats statsFunc, parse statsParseFunc) {
+	concurrency := c.deps.Config.Containers.StatsConcurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	semaphore := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
 
-			output, err := c.deps.Executor.GetPodmanStats(ctx, containerName)
+	for _, containerName := range containerNames {
+		// Skip ignored containers
+		if c.isContainerIgnored(containerName) {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			c.deps.Logger.Warn("Stopping container stats collection", zap.Error(ctx.Err()))
+			wg.Wait()
+			return
+		case semaphore <- struct{}{}:
+		}
+
+		wg.Add(1)
+		go func(containerName string) {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+
+			output, err := getStats(ctx, containerName)
 			if err != nil {
 				c.deps.Logger.Warn("Failed to get stats for container",
 					zap.String("container", containerName),
 					zap.Error(err))
-				continue
+				return
 			}
 
-			if err := c.parseContainerStats(string(output), "podman"); err != nil {
+			// The gauges are safe for concurrent use
+			if err := parse(output, containerName); err != nil {
 				c.deps.Logger.Warn("Failed to parse stats for container",
 					zap.String("container", containerName),
 					zap.Error(err))
 			}
+		}(containerName)
+	}
+
+	wg.Wait()
+}
+
+// nextContainerBatch returns the next MaxPerCycle non-ignored containers for a runtime
+// The position is remembered per runtime so consecutive cycles continue where the last one stopped.
+// With StatsDelta, containers that appeared since the previous listing come first, so a new container gets stats
+// right away instead of when the rotation reaches it; they count toward MaxPerCycle
+func (c *ContainerCollector) nextContainerBatch(runtime string, containerNames []string) []string {
+	var candidates []string
+	for _, containerName := range containerNames {
+		if !c.isContainerIgnored(containerName) {
+			candidates = append(candidates, containerName)
 		}
-		return nil
 	}
 
-	// Get stats for all containers
-	output, err := c.deps.Executor.GetPodmanStats(ctx, "")
-	if err != nil {
-		return err
+	maxPerCycle := c.deps.Config.Containers.MaxPerCycle
+	previous := c.listedContainers[runtime]
+	listed := make(map[string]bool, len(candidates))
+	for _, containerName := range candidates {
+		listed[containerName] = true
+	}
+	c.listedContainers[runtime] = listed
+
+	if len(candidates) <= maxPerCycle {
+		c.batchOffsets[runtime] = 0
+		return candidates
+	}
+
+	batch := make([]string, 0, maxPerCycle)
+	inBatch := make(map[string]bool, maxPerCycle)
+	// Everything is new on the first listing, which is left to the rotation
+	if c.deps.Config.Containers.StatsDelta && previous != nil {
+		for _, containerName := range candidates {
+			if len(batch) == maxPerCycle {
+				break
+			}
+			if !previous[containerName] {
+				batch = append(batch, containerName)
+				inBatch[containerName] = true
+			}
+		}
 	}
 
-	return c.parseContainerStats(string(output), "podman")
+	// The container set may have shrunk since the last cycle
+	offset := c.batchOffsets[runtime] % len(candidates)
+	advanced := 0
+	for advanced < len(candidates) && len(batch) < maxPerCycle {
+		containerName := candidates[(offset+advanced)%len(candidates)]
+		advanced++
+		if !inBatch[containerName] {
+			batch = append(batch, containerName)
+		}
+	}
+	c.batchOffsets[runtime] = (offset + advanced) % len(candidates)
+
+	return batch
+}
+
+// isStatsHeader reports whether a stats line is the table header rather than a container
+// The header is recognized by its columns instead of its position, since a runtime may omit it
+// and a single container's stats would otherwise be dropped as the header. The CPU column is checked too,
+// so a container named CONTAINER or NAME isn't taken for the header, its second column is a percentage
+// Docker: "CONTAINER      CPU %     MEM USAGE / LIMIT     NET I/O           BLOCK I/O"
+// Podman: "NAME           CPU %     MEM USAGE / LIMIT     NET IO            BLOCK IO"
+func isStatsHeader(line string) bool {
+	fields := strings.Fields(line)
+	return len(fields) > 1 && (fields[0] == "CONTAINER" || fields[0] == "NAME") && fields[1] == "CPU"
 }
 
 // parseContainerStats parses container stats
 // This is the main function that parses the container stats
 // Example: "artisan-agent-api   1.24%     601.9MiB / 7.654GiB   12.9kB / 6.34kB   164MB / 0B"
+// The first column is a name or, with --no-trunc, a full 64 character ID, so it is matched as any non-space run
 func (c *ContainerCollector) parseContainerStats(output, runtime string) error {
 	c.deps.Logger.Debug("Parsing container stats",
 		zap.String("runtime", runtime),
@@ -199,14 +644,14 @@ func (c *ContainerCollector) parseContainerStats(output, runtime string) error {
 	lines := strings.Split(output, "\n")
 
 	for i, line := range lines {
-		if i == 0 || strings.TrimSpace(line) == "" {
+		if strings.TrimSpace(line) == "" || isStatsHeader(line) {
 			c.deps.Logger.Debug("Skipping line",
 				zap.Int("line_number", i),
 				zap.String("line", line),
 				zap.String("reason", "header or empty"))
 			continue // Skip header and empty lines
 		}
- 
+
 		c.deps.Logger.Debug("Processing container stats line",
 			zap.Int("line_number", i),
 			zap.String("line", line))
@@ -222,6 +667,7 @@ func (c *ContainerCollector) parseContainerStats(output, runtime string) error {
 			zap.Strings("matches", matches))
 
 		if len(matches) != 9 {
+			c.deps.parseFailed(c.Name(), "stats_line")
 			c.deps.Logger.Warn("Failed to parse container stats line",
 				zap.String("line", line),
 				zap.Int("expected_matches", 9),
@@ -271,8 +717,7 @@ func (c *ContainerCollector) parseContainerStats(output, runtime string) error {
 			zap.Float64("mem_used_bytes", used),
 			zap.String("mem_limit_str", memLimit),
 			zap.Float64("mem_limit_bytes", limit))
-		c.containerMemory.WithLabelValues(containerName, runtime, "used").Set(used)
-		c.containerMemory.WithLabelValues(containerName, runtime, "limit").Set(limit)
+		c.setContainerMemory(containerName, runtime, used, limit)
 
 		// Parse network I/O
 		rx := parseNetworkValue(netRx)
@@ -283,8 +728,7 @@ func (c *ContainerCollector) parseContainerStats(output, runtime string) error {
 			zap.Float64("net_rx_bytes", rx),
 			zap.String("net_tx_str", netTx),
 			zap.Float64("net_tx_bytes", tx))
-		c.containerNetIO.WithLabelValues(containerName, runtime, "rx").Set(rx)
-		c.containerNetIO.WithLabelValues(containerName, runtime, "tx").Set(tx)
+		c.setContainerNetIO(containerName, runtime, rx, tx)
 
 		// Parse block I/O
 		read := parseByteValue(blockRead)
@@ -302,14 +746,203 @@ func (c *ContainerCollector) parseContainerStats(output, runtime string) error {
 	return nil
 }
 
-// isContainerIgnored checks if a container should be ignored
+// setContainerMemory sets the memory used and limit of a container
+// Memory used is also observed in the histogram when the container is listed in containers.monitored_names,
+// which bounds the histogram's series to containers picked on purpose
+func (c *ContainerCollector) setContainerMemory(containerName, runtime string, used, limit float64) {
+	c.containerMemory.WithLabelValues(containerName, runtime, "used").Set(used)
+	c.containerMemory.WithLabelValues(containerName, runtime, "limit").Set(limit)
+
+	if c.isContainerMonitored(containerName) {
+		c.containerMemoryHist.WithLabelValues(containerName, runtime).Observe(used)
+	}
+}
+
+// setContainerNetIO sets the cumulative network I/O of a container and its rate over the rate window
+func (c *ContainerCollector) setContainerNetIO(containerName, runtime string, rx, tx float64) {
+	c.cycleTrafficMu.Lock()
+	c.cycleTraffic[runtime+"/"+containerName] = rx + tx
+	c.cycleTrafficMu.Unlock()
+
+	now := time.Now()
+	for direction, value := range map[string]float64{"rx": rx, "tx": tx} {
+		c.containerNetIO.WithLabelValues(containerName, runtime, direction).Set(value)
+		if rate, ok := c.rates.Observe(containerName+"/"+runtime+"/"+direction, value, now); ok {
+			c.containerNetIORate.WithLabelValues(containerName, runtime, direction).Set(rate)
+		}
+	}
+}
+
+// capReportedContainers keeps the stats series of the containers.max_reported containers of a runtime with the most
+// network traffic, a safety valve against cardinality on hosts running hundreds of containers.
+// The stats series of the other containers set this cycle are deleted. Info, count and the per-container metrics of
+// containers.monitored_names are not capped
+func (c *ContainerCollector) capReportedContainers(runtime string) {
+	c.cycleTrafficMu.Lock()
+	defer c.cycleTrafficMu.Unlock()
+
+	type containerTraffic struct {
+		name    string
+		traffic float64
+	}
+	var containers []containerTraffic
+	for key, traffic := range c.cycleTraffic {
+		if name, ok := strings.CutPrefix(key, runtime+"/"); ok {
+			containers = append(containers, containerTraffic{name: name, traffic: traffic})
+			delete(c.cycleTraffic, key)
+		}
+	}
+
+	maxReported := c.deps.Config.Containers.MaxReported
+	if maxReported <= 0 || len(containers) <= maxReported {
+		return
+	}
+
+	sort.Slice(containers, func(i, j int) bool {
+		if containers[i].traffic != containers[j].traffic {
+			return containers[i].traffic > containers[j].traffic
+		}
+		return containers[i].name < containers[j].name
+	})
+	for _, dropped := range containers[maxReported:] {
+		labels := prometheus.Labels{"container": dropped.name, "runtime": runtime}
+		c.containerCPU.DeletePartialMatch(labels)
+		c.containerMemory.DeletePartialMatch(labels)
+		c.containerMemoryHist.DeletePartialMatch(labels)
+		c.containerNetIO.DeletePartialMatch(labels)
+		c.containerNetIORate.DeletePartialMatch(labels)
+		c.containerBlockIO.DeletePartialMatch(labels)
+		c.containerBlockIODeviceBytes.DeletePartialMatch(labels)
+		c.containerBlockIODeviceOps.DeletePartialMatch(labels)
+		c.containerStatus.DeletePartialMatch(labels)
+	}
+
+	c.reportedCapWarning.Do(func() {
+		c.deps.Logger.Warn("More containers than containers.max_reported, reporting those with the most traffic",
+			zap.String("runtime", runtime),
+			zap.Int("containers", len(containers)),
+			zap.Int("max_reported", maxReported))
+	})
+	c.deps.Logger.Debug("Capped reported containers",
+		zap.String("runtime", runtime),
+		zap.Int("containers", len(containers)),
+		zap.Int("max_reported", maxReported))
+}
+
+// collectDockerInfo collects Docker container image, creation time, count and PIDs
+func (c *ContainerCollector) collectDockerInfo(ctx context.Context) error {
+	output, err := c.deps.Executor.GetDockerContainerInfo(ctx)
+	if err != nil {
+		return err
+	}
+
+	containerNames := c.parseContainerInfo(string(output), "docker")
+	c.containerCount.WithLabelValues("docker").Set(float64(len(containerNames)))
+	c.setContainerPIDs(ctx, "docker", containerNames)
+	c.setContainerOOMKills(ctx, "docker", containerNames)
+	c.setContainerCPUThrottling(ctx, "docker", containerNames)
+	c.setContainerCPUCores(ctx, "docker", containerNames)
+	c.setContainerSwap(ctx, "docker", containerNames)
+	c.setContainerWorkingSet(ctx, "docker", containerNames)
+	c.setContainerBlockIODevices(ctx, "docker", containerNames)
+	c.setContainerInterfaceStats(ctx, "docker", containerNames)
+	return c.setContainerRootless(ctx, "docker", containerNames)
+}
+
+// collectPodmanInfo collects Podman container image, creation time, count and PIDs
+func (c *ContainerCollector) collectPodmanInfo(ctx context.Context) error {
+	output, err := c.deps.Executor.GetPodmanContainerInfo(ctx)
+	if err != nil {
+		return err
+	}
+
+	containerNames := c.parseContainerInfo(string(output), "podman")
+	c.containerCount.WithLabelValues("podman").Set(float64(len(containerNames)))
+	c.setContainerPIDs(ctx, "podman", containerNames)
+	c.setContainerOOMKills(ctx, "podman", containerNames)
+	c.setContainerCPUThrottling(ctx, "podman", containerNames)
+	c.setContainerCPUCores(ctx, "podman", containerNames)
+	c.setContainerSwap(ctx, "podman", containerNames)
+	c.setContainerWorkingSet(ctx, "podman", containerNames)
+	c.setContainerBlockIODevices(ctx, "podman", containerNames)
+	c.setContainerInterfaceStats(ctx, "podman", containerNames)
+	return c.setContainerRootless(ctx, "podman", containerNames)
+}
+
+// parseContainerInfo parses container info lines and sets the info metrics
+// Containers that are no longer listed are dropped
+// It returns the names of the reported containers
+// Example: "api-caller\tapi-caller:latest\t2024-01-15 10:30:00 +0000 UTC"
+func (c *ContainerCollector) parseContainerInfo(output, runtime string) []string {
+	c.containerInfo.DeletePartialMatch(prometheus.Labels{"runtime": runtime})
+	c.containerCreated.DeletePartialMatch(prometheus.Labels{"runtime": runtime})
+
+	var containerNames []string
+
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Split(strings.TrimSpace(line), "\t")
+		if len(fields) != 3 {
+			continue
+		}
+
+		containerName, image, createdAt := fields[0], fields[1], fields[2]
+		if !c.isContainerReported(containerName) {
+			continue
+		}
+		containerNames = append(containerNames, containerName)
+
+		c.containerInfo.WithLabelValues(containerName, runtime, image).Set(1)
+
+		// Fractional seconds (podman) are accepted even though the layout omits them
+		if created, err := time.Parse("2006-01-02 15:04:05 -0700 MST", createdAt); err == nil {
+			c.containerCreated.WithLabelValues(containerName, runtime).Set(float64(created.Unix()))
+		} else {
+			c.deps.Logger.Debug("Failed to parse container creation time",
+				zap.String("container", containerName),
+				zap.String("created_at", createdAt),
+				zap.Error(err))
+		}
+	}
+
+	return containerNames
+}
+
+// setContainerInfo sets the info metrics for a single container
+func (c *ContainerCollector) setContainerInfo(containerName, runtime, image string, created time.Time) {
+	c.containerInfo.WithLabelValues(containerName, runtime, image).Set(1)
+	c.containerCreated.WithLabelValues(containerName, runtime).Set(float64(created.Unix()))
+}
+
+// isContainerReported checks if a container is monitored and not ignored
+func (c *ContainerCollector) isContainerReported(containerName string) bool {
+	if c.isContainerIgnored(containerName) {
+		return false
+	}
+	if len(c.deps.Config.Containers.MonitoredNames) == 0 {
+		return true
+	}
+	return c.isContainerMonitored(containerName)
+}
+
+// isContainerMonitored checks if a container is listed in containers.monitored_names
+// Metrics with a higher cost per container are limited to these
+func (c *ContainerCollector) isContainerMonitored(containerName string) bool {
+	for _, monitored := range c.deps.Config.Containers.MonitoredNames {
+		if containerName == monitored {
+			return true
+		}
+	}
+	return false
+}
+
+// isContainerIgnored checks if a container should be ignored, by exact name or pattern
 func (c *ContainerCollector) isContainerIgnored(containerName string) bool {
 	for _, ignored := range c.deps.Config.Containers.IgnoredNames {
 		if containerName == ignored {
 			return true
 		}
 	}
-	return false
+	return matchesAny(c.ignoredNamePatterns, containerName)
 }
 
 // parseMemoryValue converts memory strings like "1.5GiB", "512MiB" to bytes
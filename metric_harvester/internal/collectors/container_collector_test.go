@@ -0,0 +1,92 @@
+package collectors
+
+import (
+	"reflect"
+	"testing"
+
+	"metric_harvester/internal/config"
+)
+
+func TestIsStatsHeader(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+		want bool
+	}{
+		{
+			name: "docker header",
+			line: "CONTAINER      CPU %     MEM USAGE / LIMIT     NET I/O           BLOCK I/O",
+			want: true,
+		},
+		{
+			name: "podman header",
+			line: "NAME           CPU %     MEM USAGE / LIMIT     NET IO            BLOCK IO",
+			want: true,
+		},
+		{
+			name: "single container without header",
+			line: "artisan-agent-api   1.24%     601.9MiB / 7.654GiB   12.9kB / 6.34kB   164MB / 0B",
+			want: false,
+		},
+		{
+			name: "container named NAME",
+			line: "NAME   0.50%     10MiB / 7.654GiB   1kB / 0B   0B / 0B",
+			want: false,
+		},
+		{
+			name: "container named CONTAINER",
+			line: "CONTAINER   0.50%     10MiB / 7.654GiB   1kB / 0B   0B / 0B",
+			want: false,
+		},
+		{
+			name: "empty line",
+			line: "",
+			want: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isStatsHeader(tt.line); got != tt.want {
+				t.Errorf("isStatsHeader(%q) = %v, want %v", tt.line, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNextContainerBatch(t *testing.T) {
+	tests := []struct {
+		name       string
+		statsDelta bool
+		listings   [][]string
+		want       [][]string
+	}{
+		{
+			name:     "round-robin",
+			listings: [][]string{{"a", "b", "c"}, {"a", "b", "c"}, {"a", "b", "c", "d"}},
+			want:     [][]string{{"a", "b"}, {"c", "a"}, {"b", "c"}},
+		},
+		{
+			name:       "new containers first",
+			statsDelta: true,
+			// d is collected as soon as it appears, the rotation then goes on from c
+			listings: [][]string{{"a", "b", "c"}, {"a", "b", "c", "d"}, {"a", "b", "c", "d"}},
+			want:     [][]string{{"a", "b"}, {"d", "c"}, {"d", "a"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.New()
+			cfg.Containers.MaxPerCycle = 2
+			cfg.Containers.StatsDelta = tt.statsDelta
+			c := NewContainerCollector(&CollectorDependencies{Config: cfg})
+
+			for i, listing := range tt.listings {
+				if got := c.nextContainerBatch("docker", listing); !reflect.DeepEqual(got, tt.want[i]) {
+					t.Errorf("cycle %d: got %v, want %v", i+1, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
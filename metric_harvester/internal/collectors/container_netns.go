@@ -0,0 +1,125 @@
+package collectors
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+// netDevStats are the counters of one interface in /proc/net/dev
+type netDevStats struct {
+	name                          string
+	rxBytes, rxPackets, rxDropped float64
+	txBytes, txPackets, txDropped float64
+}
+
+// setContainerInterfaceStats sets the per-interface counters seen inside each monitored container's network namespace
+// They show the traffic of the container's own interfaces, e.g. the tap device of slirp4netns or pasta for rootless
+// and the veth end for rootful, which the stats network I/O sums into a single figure.
+// The namespace is entered through the PID cached by setContainerPIDs, which must run first.
+// nsenter needs CAP_SYS_ADMIN, so failures are warned about once and then only logged at debug level
+func (c *ContainerCollector) setContainerInterfaceStats(ctx context.Context, runtime string, containerNames []string) {
+	if !c.deps.Config.Containers.EnableNetnsStats {
+		return
+	}
+
+	for _, vec := range c.containerInterfaceStats() {
+		vec.DeletePartialMatch(prometheus.Labels{"runtime": runtime})
+	}
+
+	for _, containerName := range containerNames {
+		if !c.isContainerMonitored(containerName) {
+			continue
+		}
+		pid, ok := c.pids[runtime+"/"+containerName]
+		if !ok || pid == "0" {
+			continue
+		}
+
+		output, err := c.deps.Executor.Execute(ctx, "nsenter", "-t", pid, "-n", "cat", "/proc/net/dev")
+		if err != nil {
+			c.netnsWarning.Do(func() {
+				c.deps.Logger.Warn("Failed to enter container network namespace, nsenter needs root or CAP_SYS_ADMIN",
+					zap.String("container", containerName),
+					zap.String("runtime", runtime),
+					zap.Error(err))
+			})
+			c.deps.Logger.Debug("Failed to read container interface stats",
+				zap.String("container", containerName),
+				zap.String("runtime", runtime),
+				zap.Error(err))
+			continue
+		}
+
+		for _, stats := range parseNetDev(string(output)) {
+			if stats.name == "lo" {
+				continue
+			}
+			labels := []string{containerName, runtime, stats.name}
+			c.containerInterfaceRxBytes.WithLabelValues(labels...).Set(stats.rxBytes)
+			c.containerInterfaceTxBytes.WithLabelValues(labels...).Set(stats.txBytes)
+			c.containerInterfaceRxPackets.WithLabelValues(labels...).Set(stats.rxPackets)
+			c.containerInterfaceTxPackets.WithLabelValues(labels...).Set(stats.txPackets)
+			c.containerInterfaceRxDropped.WithLabelValues(labels...).Set(stats.rxDropped)
+			c.containerInterfaceTxDropped.WithLabelValues(labels...).Set(stats.txDropped)
+		}
+	}
+}
+
+// containerInterfaceStats returns the per-interface metrics read through nsenter
+func (c *ContainerCollector) containerInterfaceStats() []*prometheus.GaugeVec {
+	return []*prometheus.GaugeVec{
+		c.containerInterfaceRxBytes,
+		c.containerInterfaceTxBytes,
+		c.containerInterfaceRxPackets,
+		c.containerInterfaceTxPackets,
+		c.containerInterfaceRxDropped,
+		c.containerInterfaceTxDropped,
+	}
+}
+
+// parseNetDev parses the interface lines of /proc/net/dev, skipping the two header lines
+// Example: "eth0: 1234567 8901 0 0 0 0 0 0 2345678 9012 0 0 0 0 0 0"
+// fields[0], [1] and [3] are the received bytes, packets and dropped
+// fields[8], [9] and [11] are the transmitted bytes, packets and dropped
+func parseNetDev(output string) []netDevStats {
+	var result []netDevStats
+	for _, line := range strings.Split(output, "\n") {
+		name, counters, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		fields := strings.Fields(counters)
+		if len(fields) < 16 {
+			continue
+		}
+
+		values := make([]float64, len(fields))
+		valid := true
+		for i, field := range fields {
+			value, err := strconv.ParseFloat(field, 64)
+			if err != nil {
+				valid = false
+				break
+			}
+			values[i] = value
+		}
+		if !valid {
+			continue
+		}
+
+		result = append(result, netDevStats{
+			name:      strings.TrimSpace(name),
+			rxBytes:   values[0],
+			rxPackets: values[1],
+			rxDropped: values[3],
+			txBytes:   values[8],
+			txPackets: values[9],
+			txDropped: values[11],
+		})
+	}
+	return result
+}
@@ -0,0 +1,124 @@
+package collectors
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+// setContainerOOMKills sets container_oom_killed_total for each monitored container of a runtime
+// The count is read from the container's memory cgroup through the PID cached by setContainerPIDs,
+// which must run first. When the cgroup can't be read, e.g. the harvester runs in its own cgroup namespace
+// or on macOS, it falls back to container_oom_killed, whether inspect says the container's last exit was an OOM kill.
+// The flag is a separate gauge since it isn't a count and can't be summed or rated with the cgroup counts
+// Failures for a single container are logged and don't stop the others
+func (c *ContainerCollector) setContainerOOMKills(ctx context.Context, runtime string, containerNames []string) {
+	c.containerOOMKills.DeletePartialMatch(prometheus.Labels{"runtime": runtime})
+	c.containerOOMKilled.DeletePartialMatch(prometheus.Labels{"runtime": runtime})
+
+	for _, containerName := range containerNames {
+		if !c.isContainerMonitored(containerName) {
+			continue
+		}
+
+		if pid, ok := c.pids[runtime+"/"+containerName]; ok && pid != "0" {
+			kills, err := c.readCgroupOOMKills(ctx, pid)
+			if err == nil {
+				c.containerOOMKills.WithLabelValues(containerName, runtime).Set(kills)
+				continue
+			}
+			c.deps.Logger.Debug("Failed to read container OOM kills from its cgroup, inspecting instead",
+				zap.String("container", containerName),
+				zap.String("runtime", runtime),
+				zap.Error(err))
+		}
+
+		killed, err := c.inspectContainerOOMKilled(ctx, runtime, containerName)
+		if err != nil {
+			c.deps.Logger.Warn("Failed to inspect container OOM kill",
+				zap.String("container", containerName),
+				zap.String("runtime", runtime),
+				zap.Error(err))
+			continue
+		}
+		value := 0.0
+		if killed {
+			value = 1
+		}
+		c.containerOOMKilled.WithLabelValues(containerName, runtime).Set(value)
+	}
+}
+
+// readCgroupOOMKills reads the oom_kill count of the memory cgroup a process belongs to
+// The commands it runs are:
+// - cat /proc/<pid>/cgroup
+// - cat /sys/fs/cgroup/<path>/memory.events (cgroup v2)
+// - cat /sys/fs/cgroup/memory/<path>/memory.oom_control (cgroup v1)
+func (c *ContainerCollector) readCgroupOOMKills(ctx context.Context, pid string) (float64, error) {
+	output, file, err := c.readCgroupFile(ctx, pid, "memory", "memory.events", "memory.oom_control")
+	if err != nil {
+		return 0, err
+	}
+
+	kills, ok := parseOOMKills(output)
+	if !ok {
+		// oom_kill was only added to memory.oom_control in Linux 4.13
+		return 0, fmt.Errorf("no oom_kill field in %s", file)
+	}
+	return kills, nil
+}
+
+// inspectContainerOOMKilled gets whether a container's last exit was an OOM kill
+// The commands it runs are:
+// - docker inspect -f {{.State.OOMKilled}} <name> (or GET /containers/<name>/json when the Docker API socket is configured)
+// - podman inspect -f {{.State.OOMKilled}} <name>
+func (c *ContainerCollector) inspectContainerOOMKilled(ctx context.Context, runtime, containerName string) (bool, error) {
+	if runtime == "docker" && c.deps.DockerAPI != nil {
+		output, err := c.deps.DockerAPI.InspectContainer(ctx, containerName)
+		if err != nil {
+			return false, err
+		}
+
+		var container struct {
+			State struct {
+				OOMKilled bool `json:"OOMKilled"`
+			} `json:"State"`
+		}
+		if err := json.Unmarshal(output, &container); err != nil {
+			return false, err
+		}
+		return container.State.OOMKilled, nil
+	}
+
+	inspect := c.deps.Executor.GetDockerContainerOOMKilled
+	if runtime == "podman" {
+		inspect = c.deps.Executor.GetPodmanContainerOOMKilled
+	}
+
+	output, err := inspect(ctx, containerName)
+	if err != nil {
+		return false, err
+	}
+	return strconv.ParseBool(strings.TrimSpace(string(output)))
+}
+
+// parseOOMKills parses the oom_kill field of memory.events (v2) or memory.oom_control (v1)
+// Example:
+// "oom 2"
+// "oom_kill 2"
+func parseOOMKills(output string) (float64, bool) {
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[0] == "oom_kill" {
+			if value, err := strconv.ParseFloat(fields[1], 64); err == nil {
+				return value, true
+			}
+		}
+	}
+	return 0, false
+}
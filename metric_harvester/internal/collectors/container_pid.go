@@ -0,0 +1,109 @@
+package collectors
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+// setContainerPIDs sets container_pid for each container of a runtime
+// The PID joins container metrics with host process metrics, e.g. to attribute context switches to a container
+// PIDs are cached and a container is only inspected again once its cached process is gone, i.e. after a restart
+// Containers that are no longer listed are dropped
+// Failures for a single container are logged and don't stop the others
+func (c *ContainerCollector) setContainerPIDs(ctx context.Context, runtime string, containerNames []string) {
+	// PIDs are host PIDs, which mean nothing where the runtime runs in a VM, e.g. Docker Desktop on macOS
+	if !c.pidUnsupported.check(c.procfs.check(ctx, c.deps, procfsProbe), c.deps.Logger, "container_pid") {
+		return
+	}
+
+	listed := make(map[string]bool, len(containerNames))
+	c.containerPID.DeletePartialMatch(prometheus.Labels{"runtime": runtime})
+
+	for _, containerName := range containerNames {
+		key := runtime + "/" + containerName
+		listed[key] = true
+
+		pid, ok := c.pids[key]
+		if !ok || !c.processExists(ctx, pid) {
+			var err error
+			pid, err = c.inspectContainerPID(ctx, runtime, containerName)
+			if err != nil {
+				c.deps.Logger.Warn("Failed to inspect container PID",
+					zap.String("container", containerName),
+					zap.String("runtime", runtime),
+					zap.Error(err))
+				delete(c.pids, key)
+				continue
+			}
+			c.pids[key] = pid
+		}
+
+		// PID 0 means the container isn't running
+		if pid == "0" {
+			continue
+		}
+		c.containerPID.WithLabelValues(containerName, runtime, pid).Set(1)
+	}
+
+	// Forget containers of this runtime that are gone
+	for key := range c.pids {
+		if strings.HasPrefix(key, runtime+"/") && !listed[key] {
+			delete(c.pids, key)
+		}
+	}
+}
+
+// inspectContainerPID gets the host PID of a container's main process
+// The commands it runs are:
+// - docker inspect -f {{.State.Pid}} <name> (or GET /containers/<name>/json when the Docker API socket is configured)
+// - podman inspect -f {{.State.Pid}} <name>
+func (c *ContainerCollector) inspectContainerPID(ctx context.Context, runtime, containerName string) (string, error) {
+	if runtime == "docker" && c.deps.DockerAPI != nil {
+		output, err := c.deps.DockerAPI.InspectContainer(ctx, containerName)
+		if err != nil {
+			return "", err
+		}
+
+		var container struct {
+			State struct {
+				Pid int `json:"Pid"`
+			} `json:"State"`
+		}
+		if err := json.Unmarshal(output, &container); err != nil {
+			return "", err
+		}
+		return strconv.Itoa(container.State.Pid), nil
+	}
+
+	inspect := c.deps.Executor.GetDockerContainerPID
+	if runtime == "podman" {
+		inspect = c.deps.Executor.GetPodmanContainerPID
+	}
+
+	output, err := inspect(ctx, containerName)
+	if err != nil {
+		return "", err
+	}
+
+	pid := strings.TrimSpace(string(output))
+	if _, err := strconv.Atoi(pid); err != nil {
+		return "", err
+	}
+	return pid, nil
+}
+
+// processExists checks if a process of the collected host is still running
+// The command it runs on an ssh target is:
+// - cat /proc/<pid>/stat
+func (c *ContainerCollector) processExists(ctx context.Context, pid string) bool {
+	if pid == "0" {
+		return false
+	}
+	_, err := c.deps.readProcFile(ctx, "/proc/"+pid+"/stat")
+	return err == nil
+}
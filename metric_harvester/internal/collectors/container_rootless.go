@@ -0,0 +1,116 @@
+package collectors
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// rootlessRefreshInterval is how long a runtime's rootless mode is cached before it is inspected again
+const rootlessRefreshInterval = 10 * time.Minute
+
+// rootlessMode is a cached result of inspecting whether a runtime runs rootless
+type rootlessMode struct {
+	rootless  bool
+	checkedAt time.Time
+}
+
+// setContainerRootless sets container_rootless for each container of a runtime
+// Containers that are no longer listed are dropped
+func (c *ContainerCollector) setContainerRootless(ctx context.Context, runtime string, containerNames []string) error {
+	rootless, err := c.isRuntimeRootless(ctx, runtime)
+	if err != nil {
+		return err
+	}
+
+	value := 0.0
+	if rootless {
+		value = 1
+	}
+
+	c.containerRootless.DeletePartialMatch(prometheus.Labels{"runtime": runtime})
+	for _, containerName := range containerNames {
+		c.containerRootless.WithLabelValues(containerName, runtime).Set(value)
+	}
+
+	return nil
+}
+
+// isRuntimeRootless reports whether a runtime runs rootless, inspecting it only when the cached result is stale
+// The commands it runs are:
+// - docker info --format {{.SecurityOptions}} (or GET /info when the Docker API socket is configured)
+// - podman info --format {{.Host.Security.Rootless}}
+func (c *ContainerCollector) isRuntimeRootless(ctx context.Context, runtime string) (bool, error) {
+	if mode, ok := c.rootlessModes[runtime]; ok && time.Since(mode.checkedAt) < rootlessRefreshInterval {
+		return mode.rootless, nil
+	}
+
+	var (
+		rootless bool
+		err      error
+	)
+	switch {
+	case runtime == "podman":
+		rootless, err = c.inspectPodmanRootless(ctx)
+	case c.deps.DockerAPI != nil:
+		rootless, err = c.inspectDockerAPIRootless(ctx)
+	default:
+		rootless, err = c.inspectDockerRootless(ctx)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	c.rootlessModes[runtime] = rootlessMode{rootless: rootless, checkedAt: time.Now()}
+	return rootless, nil
+}
+
+// inspectPodmanRootless asks podman whether it runs rootless
+func (c *ContainerCollector) inspectPodmanRootless(ctx context.Context) (bool, error) {
+	output, err := c.deps.Executor.GetPodmanRootless(ctx)
+	if err != nil {
+		return false, err
+	}
+	return strconv.ParseBool(strings.TrimSpace(string(output)))
+}
+
+// inspectDockerRootless checks the Docker daemon's security options for rootless mode or userns-remap
+func (c *ContainerCollector) inspectDockerRootless(ctx context.Context) (bool, error) {
+	output, err := c.deps.Executor.GetDockerSecurityOptions(ctx)
+	if err != nil {
+		return false, err
+	}
+	return hasRootlessSecurityOption(strings.Fields(strings.Trim(strings.TrimSpace(string(output)), "[]"))), nil
+}
+
+// inspectDockerAPIRootless checks the Docker daemon's security options through the Docker API socket
+func (c *ContainerCollector) inspectDockerAPIRootless(ctx context.Context) (bool, error) {
+	output, err := c.deps.DockerAPI.GetInfo(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	var info struct {
+		SecurityOptions []string `json:"SecurityOptions"`
+	}
+	if err := json.Unmarshal(output, &info); err != nil {
+		return false, err
+	}
+	return hasRootlessSecurityOption(info.SecurityOptions), nil
+}
+
+// hasRootlessSecurityOption checks Docker security options like "name=rootless" or "name=userns"
+// Each option is a comma separated list whose first entry names it, e.g. "name=seccomp,profile=builtin"
+func hasRootlessSecurityOption(options []string) bool {
+	for _, option := range options {
+		name := strings.SplitN(option, ",", 2)[0]
+		if name == "name=rootless" || name == "name=userns" {
+			return true
+		}
+	}
+	return false
+}
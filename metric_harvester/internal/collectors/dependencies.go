@@ -0,0 +1,127 @@
+package collectors
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// DependentCollector is a collector that needs other collectors to run before it in a cycle,
+// e.g. to read the container PIDs the container collector shares through the CycleState
+type DependentCollector interface {
+	Collector
+	// DependsOn lists the names of the collectors that must run first
+	// Dependencies that aren't enabled are ignored, so a dependent must cope with missing shared state
+	DependsOn() []string
+}
+
+// OrderCollectors sorts collectors so every collector comes after the collectors it depends on
+// The sort is stable, collectors without dependencies between them keep their order
+// It returns an error naming the collectors involved when the dependencies form a cycle
+func OrderCollectors(collectors []Collector) ([]Collector, error) {
+	byName := make(map[string]Collector, len(collectors))
+	for _, collector := range collectors {
+		byName[collector.Name()] = collector
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[string]int, len(collectors))
+	ordered := make([]Collector, 0, len(collectors))
+	var path []string
+
+	var visit func(collector Collector) error
+	visit = func(collector Collector) error {
+		name := collector.Name()
+		switch state[name] {
+		case visited:
+			return nil
+		case visiting:
+			return fmt.Errorf("collector dependency cycle: %s -> %s", strings.Join(path, " -> "), name)
+		}
+
+		state[name] = visiting
+		path = append(path, name)
+		if dependent, ok := collector.(DependentCollector); ok {
+			for _, dependency := range dependent.DependsOn() {
+				if next, ok := byName[dependency]; ok {
+					if err := visit(next); err != nil {
+						return err
+					}
+				}
+			}
+		}
+		path = path[:len(path)-1]
+		state[name] = visited
+
+		ordered = append(ordered, collector)
+		return nil
+	}
+
+	for _, collector := range collectors {
+		if err := visit(collector); err != nil {
+			return nil, err
+		}
+	}
+	return ordered, nil
+}
+
+// MustOrderCollectors is OrderCollectors for the built-in collectors, it panics on a dependency cycle
+// Dependencies are declared in code, so a cycle is a programming error caught at startup
+func MustOrderCollectors(collectors []Collector) []Collector {
+	ordered, err := OrderCollectors(collectors)
+	if err != nil {
+		panic(err)
+	}
+	return ordered
+}
+
+// ContainerPIDsKey is the CycleState key of the container PIDs, a map[string]string of runtime/container -> host PID
+const ContainerPIDsKey = "container_pids"
+
+// CycleState is state shared between the collectors of a single collection cycle
+// When collectors run at their own intervals, a target's collectors share one CycleState for as long as they run,
+// so values are those of the latest collection of the collector that set them
+// It is safe for concurrent use, and all its methods can be called on a nil *CycleState
+type CycleState struct {
+	mu     sync.Mutex
+	values map[string]any
+}
+
+type cycleStateKey struct{}
+
+// WithCycleState returns a context carrying a new, empty CycleState
+func WithCycleState(ctx context.Context) context.Context {
+	return context.WithValue(ctx, cycleStateKey{}, &CycleState{values: make(map[string]any)})
+}
+
+// CycleStateFrom returns the CycleState of the context, or nil when the collector runs without one, e.g. in the selftest
+func CycleStateFrom(ctx context.Context) *CycleState {
+	state, _ := ctx.Value(cycleStateKey{}).(*CycleState)
+	return state
+}
+
+// Set stores a value for the collectors that run later in the cycle
+func (s *CycleState) Set(key string, value any) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.values[key] = value
+}
+
+// Get returns a value stored earlier in the cycle
+func (s *CycleState) Get(key string) (any, bool) {
+	if s == nil {
+		return nil, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	value, ok := s.values[key]
+	return value, ok
+}
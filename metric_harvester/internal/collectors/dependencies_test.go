@@ -0,0 +1,122 @@
+package collectors
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// fakeCollector is a collector that only has a name and dependencies
+type fakeCollector struct {
+	name      string
+	dependsOn []string
+}
+
+func (c *fakeCollector) Describe(chan<- *prometheus.Desc)         {}
+func (c *fakeCollector) Collect(chan<- prometheus.Metric)         {}
+func (c *fakeCollector) Name() string                             { return c.name }
+func (c *fakeCollector) CollectMetrics(ctx context.Context) error { return nil }
+func (c *fakeCollector) RequiredCommands() []string               { return nil }
+func (c *fakeCollector) DependsOn() []string                      { return c.dependsOn }
+
+func TestOrderCollectors(t *testing.T) {
+	tests := []struct {
+		name       string
+		collectors []*fakeCollector
+		want       []string
+		wantCycle  string
+	}{
+		{
+			name:       "no dependencies keeps the order",
+			collectors: []*fakeCollector{{name: "system"}, {name: "container"}, {name: "network"}},
+			want:       []string{"system", "container", "network"},
+		},
+		{
+			name: "dependent moves after its dependency",
+			collectors: []*fakeCollector{
+				{name: "process", dependsOn: []string{"container"}},
+				{name: "system"},
+				{name: "container"},
+			},
+			want: []string{"container", "process", "system"},
+		},
+		{
+			name: "chain of dependencies",
+			collectors: []*fakeCollector{
+				{name: "c", dependsOn: []string{"b"}},
+				{name: "b", dependsOn: []string{"a"}},
+				{name: "a"},
+			},
+			want: []string{"a", "b", "c"},
+		},
+		{
+			name: "disabled dependency is ignored",
+			collectors: []*fakeCollector{
+				{name: "process", dependsOn: []string{"container"}},
+				{name: "system"},
+			},
+			want: []string{"process", "system"},
+		},
+		{
+			name: "cycle",
+			collectors: []*fakeCollector{
+				{name: "a", dependsOn: []string{"b"}},
+				{name: "b", dependsOn: []string{"a"}},
+			},
+			wantCycle: "a -> b -> a",
+		},
+		{
+			name:       "self dependency",
+			collectors: []*fakeCollector{{name: "a", dependsOn: []string{"a"}}},
+			wantCycle:  "a -> a",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := make([]Collector, len(tt.collectors))
+			for i, collector := range tt.collectors {
+				input[i] = collector
+			}
+
+			ordered, err := OrderCollectors(input)
+			if tt.wantCycle != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantCycle) {
+					t.Fatalf("error = %v, want a cycle %q", err, tt.wantCycle)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			var got []string
+			for _, collector := range ordered {
+				got = append(got, collector.Name())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCycleState(t *testing.T) {
+	ctx := WithCycleState(context.Background())
+	CycleStateFrom(ctx).Set(ContainerPIDsKey, map[string]string{"docker/web": "42"})
+
+	value, ok := CycleStateFrom(ctx).Get(ContainerPIDsKey)
+	if !ok || !reflect.DeepEqual(value, map[string]string{"docker/web": "42"}) {
+		t.Errorf("Get = %v, %v, want the stored PIDs", value, ok)
+	}
+
+	// Without a CycleState, values are dropped rather than panicking
+	state := CycleStateFrom(context.Background())
+	state.Set(ContainerPIDsKey, "ignored")
+	if _, ok := state.Get(ContainerPIDsKey); ok {
+		t.Error("Get on a nil CycleState found a value")
+	}
+}
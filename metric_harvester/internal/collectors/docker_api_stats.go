@@ -0,0 +1,189 @@
+package collectors
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// dockerAPIContainer is the subset of a /containers/json entry that is needed
+type dockerAPIContainer struct {
+	Names   []string `json:"Names"`
+	Image   string   `json:"Image"`
+	Created int64    `json:"Created"`
+}
+
+// dockerAPICPUStats is the CPU section of a Docker API stats sample
+type dockerAPICPUStats struct {
+	CPUUsage struct {
+		TotalUsage  uint64   `json:"total_usage"`
+		PercpuUsage []uint64 `json:"percpu_usage"`
+	} `json:"cpu_usage"`
+	SystemUsage uint64 `json:"system_cpu_usage"`
+	OnlineCPUs  uint32 `json:"online_cpus"`
+}
+
+// dockerAPIStats is the subset of a /containers/<id>/stats sample that is needed
+type dockerAPIStats struct {
+	Name        string            `json:"name"`
+	CPUStats    dockerAPICPUStats `json:"cpu_stats"`
+	PreCPUStats dockerAPICPUStats `json:"precpu_stats"`
+	MemoryStats struct {
+		Usage uint64            `json:"usage"`
+		Limit uint64            `json:"limit"`
+		Stats map[string]uint64 `json:"stats"`
+	} `json:"memory_stats"`
+	Networks map[string]struct {
+		RxBytes uint64 `json:"rx_bytes"`
+		TxBytes uint64 `json:"tx_bytes"`
+	} `json:"networks"`
+	BlkioStats struct {
+		IoServiceBytesRecursive []struct {
+			Op    string `json:"op"`
+			Value uint64 `json:"value"`
+		} `json:"io_service_bytes_recursive"`
+	} `json:"blkio_stats"`
+}
+
+// collectDockerAPIMetrics collects Docker metrics through the Docker API socket
+// If MonitoredNames is specified, it gets stats only for those containers
+// Otherwise, it lists the running containers and gets stats for each of them, or for the next batch of MaxPerCycle
+// of them like the CLI path, see nextContainerBatch
+func (c *ContainerCollector) collectDockerAPIMetrics(ctx context.Context) error {
+	containerNames := c.deps.Config.Containers.MonitoredNames
+	if len(containerNames) == 0 {
+		output, err := c.deps.DockerAPI.ListContainers(ctx)
+		if err != nil {
+			return err
+		}
+
+		containerNames, err = parseDockerAPIContainerNames(output)
+		if err != nil {
+			return err
+		}
+		if c.deps.Config.Containers.MaxPerCycle > 0 {
+			containerNames = c.nextContainerBatch("docker", containerNames)
+		}
+	}
+
+	c.collectStatsConcurrently(ctx, containerNames, c.deps.DockerAPI.GetDockerStats, c.parseDockerAPIStats)
+
+	return nil
+}
+
+// collectDockerAPIInfo collects Docker container image, creation time, count and PIDs through the Docker API socket
+func (c *ContainerCollector) collectDockerAPIInfo(ctx context.Context) error {
+	output, err := c.deps.DockerAPI.ListContainers(ctx)
+	if err != nil {
+		return err
+	}
+
+	var containers []dockerAPIContainer
+	if err := json.Unmarshal(output, &containers); err != nil {
+		return err
+	}
+
+	// Drop containers that are no longer listed
+	c.containerInfo.DeletePartialMatch(prometheus.Labels{"runtime": "docker"})
+	c.containerCreated.DeletePartialMatch(prometheus.Labels{"runtime": "docker"})
+
+	var containerNames []string
+	for _, container := range containers {
+		if len(container.Names) == 0 {
+			continue
+		}
+		containerName := strings.TrimPrefix(container.Names[0], "/")
+		if !c.isContainerReported(containerName) {
+			continue
+		}
+		containerNames = append(containerNames, containerName)
+		c.setContainerInfo(containerName, "docker", container.Image, time.Unix(container.Created, 0))
+	}
+	c.containerCount.WithLabelValues("docker").Set(float64(len(containerNames)))
+	c.setContainerPIDs(ctx, "docker", containerNames)
+	c.setContainerOOMKills(ctx, "docker", containerNames)
+	c.setContainerCPUThrottling(ctx, "docker", containerNames)
+	c.setContainerCPUCores(ctx, "docker", containerNames)
+	c.setContainerSwap(ctx, "docker", containerNames)
+	c.setContainerWorkingSet(ctx, "docker", containerNames)
+	c.setContainerInterfaceStats(ctx, "docker", containerNames)
+
+	return c.setContainerRootless(ctx, "docker", containerNames)
+}
+
+// parseDockerAPIContainerNames extracts container names from a /containers/json response
+func parseDockerAPIContainerNames(output []byte) ([]string, error) {
+	var containers []dockerAPIContainer
+	if err := json.Unmarshal(output, &containers); err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, container := range containers {
+		if len(container.Names) > 0 {
+			// The API reports names with a leading slash, e.g. "/api-caller"
+			names = append(names, strings.TrimPrefix(container.Names[0], "/"))
+		}
+	}
+	return names, nil
+}
+
+// parseDockerAPIStats parses a Docker API stats sample and sets the container metrics
+// The values are derived the same way the docker CLI derives its stats columns
+func (c *ContainerCollector) parseDockerAPIStats(output []byte, containerName string) error {
+	var stats dockerAPIStats
+	if err := json.Unmarshal(output, &stats); err != nil {
+		return err
+	}
+
+	const runtime = "docker"
+
+	// CPU percentage is the container's share of the system CPU time since the previous sample
+	onlineCPUs := float64(stats.CPUStats.OnlineCPUs)
+	if onlineCPUs == 0 {
+		onlineCPUs = float64(len(stats.CPUStats.CPUUsage.PercpuUsage))
+	}
+	cpuDelta := float64(stats.CPUStats.CPUUsage.TotalUsage) - float64(stats.PreCPUStats.CPUUsage.TotalUsage)
+	systemDelta := float64(stats.CPUStats.SystemUsage) - float64(stats.PreCPUStats.SystemUsage)
+	cpu := 0.0
+	if cpuDelta > 0 && systemDelta > 0 {
+		cpu = cpuDelta / systemDelta * onlineCPUs * 100
+	}
+	c.containerCPU.WithLabelValues(containerName, runtime).Set(cpu)
+	c.containerStatus.WithLabelValues(containerName, runtime).Set(1) // Running
+
+	// Memory used excludes the page cache, matching the docker CLI
+	used := float64(stats.MemoryStats.Usage)
+	if inactive, ok := stats.MemoryStats.Stats["inactive_file"]; ok { // cgroup v2
+		used -= float64(inactive)
+	} else if inactive, ok := stats.MemoryStats.Stats["total_inactive_file"]; ok { // cgroup v1
+		used -= float64(inactive)
+	}
+	c.setContainerMemory(containerName, runtime, used, float64(stats.MemoryStats.Limit))
+
+	// Network I/O is summed across all of the container's interfaces
+	var rx, tx float64
+	for _, network := range stats.Networks {
+		rx += float64(network.RxBytes)
+		tx += float64(network.TxBytes)
+	}
+	c.setContainerNetIO(containerName, runtime, rx, tx)
+
+	// Block I/O is summed across all devices
+	var read, write float64
+	for _, entry := range stats.BlkioStats.IoServiceBytesRecursive {
+		switch strings.ToLower(entry.Op) {
+		case "read":
+			read += float64(entry.Value)
+		case "write":
+			write += float64(entry.Value)
+		}
+	}
+	c.containerBlockIO.WithLabelValues(containerName, runtime, "read").Set(read)
+	c.containerBlockIO.WithLabelValues(containerName, runtime, "write").Set(write)
+
+	return nil
+}
@@ -0,0 +1,80 @@
+package collectors
+
+import (
+	"fmt"
+	"strings"
+)
+
+// CollectionError aggregates the failures of a collector's sub-collections
+// It makes partial failures observable, e.g. "disk failed but CPU worked"
+type CollectionError struct {
+	Collector string
+	Failures  []SubCollectionFailure
+
+	attempted int
+}
+
+// SubCollectionFailure is a single failed sub-collection like cpu or memory
+type SubCollectionFailure struct {
+	Name string
+	Err  error
+}
+
+// NewCollectionError creates a new, empty CollectionError
+// Args:
+// - collector: name of the collector the sub-collections belong to
+// Returns:
+// - *CollectionError: new CollectionError instance
+func NewCollectionError(collector string) *CollectionError {
+	return &CollectionError{
+		Collector: collector,
+	}
+}
+
+// Record records the outcome of a sub-collection and returns err unchanged
+// A nil err records a successful sub-collection
+func (e *CollectionError) Record(name string, err error) error {
+	e.attempted++
+	if err != nil {
+		e.Failures = append(e.Failures, SubCollectionFailure{Name: name, Err: err})
+	}
+	return err
+}
+
+// HasErrors reports whether any sub-collection failed
+func (e *CollectionError) HasErrors() bool {
+	return len(e.Failures) > 0
+}
+
+// Partial reports whether some, but not all, sub-collections failed
+func (e *CollectionError) Partial() bool {
+	return e.HasErrors() && len(e.Failures) < e.attempted
+}
+
+// FailedNames returns the names of the failed sub-collections
+func (e *CollectionError) FailedNames() []string {
+	names := make([]string, 0, len(e.Failures))
+	for _, failure := range e.Failures {
+		names = append(names, failure.Name)
+	}
+	return names
+}
+
+// Error implements the error interface
+func (e *CollectionError) Error() string {
+	parts := make([]string, 0, len(e.Failures))
+	for _, failure := range e.Failures {
+		parts = append(parts, fmt.Sprintf("%s: %v", failure.Name, failure.Err))
+	}
+	return fmt.Sprintf("%s collector: %d of %d sub-collections failed: %s",
+		e.Collector, len(e.Failures), e.attempted, strings.Join(parts, "; "))
+}
+
+// ErrOrNil returns the CollectionError if any sub-collection failed, nil otherwise
+// Use it when returning from CollectMetrics so callers can compare against nil
+func (e *CollectionError) ErrOrNil() error {
+	if !e.HasErrors() {
+		return nil
+	}
+	return e
+}
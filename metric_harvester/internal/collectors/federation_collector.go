@@ -0,0 +1,197 @@
+package collectors
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"go.uber.org/zap"
+)
+
+// FederationCollector scrapes upstream /metrics endpoints and re-exposes their series
+// Every re-exposed series gets an extra "source" label naming the upstream it came from,
+// so the rootful and rootless harvesters can be merged into a single endpoint
+type FederationCollector struct {
+	deps   *CollectorDependencies
+	client *http.Client
+
+	// families holds the last successful scrape of each source
+	mu       sync.RWMutex
+	families map[string]map[string]*dto.MetricFamily
+
+	// Prometheus metrics
+	// sourceUp: upstream was scraped successfully (1) or not (0)
+	sourceUp *prometheus.GaugeVec
+}
+
+// NewFederationCollector creates a new FederationCollector
+// Args:
+// - deps: CollectorDependencies
+// Returns:
+// - *FederationCollector: new FederationCollector instance
+func NewFederationCollector(deps *CollectorDependencies) *FederationCollector {
+	return &FederationCollector{
+		deps:     deps,
+		client:   &http.Client{},
+		families: make(map[string]map[string]*dto.MetricFamily),
+		sourceUp: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "federation_source_up",
+				Help: "Upstream metrics endpoint was scraped successfully (1) or not (0)",
+			},
+			[]string{"source"},
+		),
+	}
+}
+
+func (c *FederationCollector) Name() string {
+	return "federation"
+}
+
+// RequiredCommands is empty since upstreams are scraped over HTTP
+func (c *FederationCollector) RequiredCommands() []string {
+	return nil
+}
+
+// Describe only describes the fixed metrics, the re-exposed series are not known in advance
+// Describing anything makes this a checked collector, but the registry only compares collected series with the
+// described ones when it is pedantic, which the harvester's isn't, so the undescribed series are still accepted
+func (c *FederationCollector) Describe(ch chan<- *prometheus.Desc) {
+	c.sourceUp.Describe(ch)
+}
+
+// Collect sends the fixed metrics and the last scraped series of every source
+func (c *FederationCollector) Collect(ch chan<- prometheus.Metric) {
+	c.sourceUp.Collect(ch)
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	for source, families := range c.families {
+		for _, family := range families {
+			c.collectFamily(ch, source, family)
+		}
+	}
+}
+
+// CollectMetrics scrapes all configured upstreams
+// A failed upstream is marked down and its series are dropped until it recovers
+func (c *FederationCollector) CollectMetrics(ctx context.Context) error {
+	c.deps.Logger.Debug("Collecting federated metrics")
+
+	result := NewCollectionError(c.Name())
+
+	for _, upstream := range c.deps.Config.Federation.Upstreams {
+		source := federationSourceName(upstream)
+
+		families, err := c.scrape(ctx, upstream)
+		if result.Record(source, err) != nil {
+			c.deps.Logger.Warn("Failed to scrape upstream metrics",
+				zap.String("upstream", upstream),
+				zap.Error(err))
+			c.sourceUp.WithLabelValues(source).Set(0)
+
+			c.mu.Lock()
+			delete(c.families, source)
+			c.mu.Unlock()
+			continue
+		}
+
+		c.sourceUp.WithLabelValues(source).Set(1)
+
+		c.mu.Lock()
+		c.families[source] = families
+		c.mu.Unlock()
+	}
+
+	return result.ErrOrNil()
+}
+
+// scrape fetches and parses an upstream endpoint in the Prometheus text format
+func (c *FederationCollector) scrape(ctx context.Context, upstream string) (map[string]*dto.MetricFamily, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, upstream, nil)
+	if err != nil {
+		return nil, err
+	}
+	// Ask for the text format since that is what the parser understands
+	req.Header.Set("Accept", string(expfmt.FmtText))
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("upstream %s returned %s", upstream, resp.Status)
+	}
+
+	var parser expfmt.TextParser
+	return parser.TextToMetricFamilies(resp.Body)
+}
+
+// collectFamily re-exposes a metric family with the source label and configured name prefix added
+func (c *FederationCollector) collectFamily(ch chan<- prometheus.Metric, source string, family *dto.MetricFamily) {
+	name := c.deps.Config.Federation.MetricPrefix + family.GetName()
+
+	for _, metric := range family.GetMetric() {
+		labelNames := []string{"source"}
+		labelValues := []string{source}
+		for _, label := range metric.GetLabel() {
+			labelNames = append(labelNames, label.GetName())
+			labelValues = append(labelValues, label.GetValue())
+		}
+
+		desc := prometheus.NewDesc(name, family.GetHelp(), labelNames, nil)
+
+		var (
+			m   prometheus.Metric
+			err error
+		)
+		switch family.GetType() {
+		case dto.MetricType_COUNTER:
+			m, err = prometheus.NewConstMetric(desc, prometheus.CounterValue, metric.GetCounter().GetValue(), labelValues...)
+		case dto.MetricType_GAUGE:
+			m, err = prometheus.NewConstMetric(desc, prometheus.GaugeValue, metric.GetGauge().GetValue(), labelValues...)
+		case dto.MetricType_HISTOGRAM:
+			histogram := metric.GetHistogram()
+			buckets := make(map[float64]uint64, len(histogram.GetBucket()))
+			for _, bucket := range histogram.GetBucket() {
+				buckets[bucket.GetUpperBound()] = bucket.GetCumulativeCount()
+			}
+			m, err = prometheus.NewConstHistogram(desc, histogram.GetSampleCount(), histogram.GetSampleSum(), buckets, labelValues...)
+		case dto.MetricType_SUMMARY:
+			summary := metric.GetSummary()
+			quantiles := make(map[float64]float64, len(summary.GetQuantile()))
+			for _, quantile := range summary.GetQuantile() {
+				quantiles[quantile.GetQuantile()] = quantile.GetValue()
+			}
+			m, err = prometheus.NewConstSummary(desc, summary.GetSampleCount(), summary.GetSampleSum(), quantiles, labelValues...)
+		default:
+			m, err = prometheus.NewConstMetric(desc, prometheus.UntypedValue, metric.GetUntyped().GetValue(), labelValues...)
+		}
+
+		if err != nil {
+			c.deps.Logger.Debug("Skipping federated series",
+				zap.String("source", source),
+				zap.String("metric", name),
+				zap.Error(err))
+			continue
+		}
+		ch <- m
+	}
+}
+
+// federationSourceName derives the source label from an upstream URL, e.g. "api-caller:8080"
+func federationSourceName(upstream string) string {
+	parsed, err := url.Parse(upstream)
+	if err != nil || parsed.Host == "" {
+		return upstream
+	}
+	return parsed.Host
+}
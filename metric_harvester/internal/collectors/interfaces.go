@@ -4,6 +4,7 @@ import (
 	"context"
 	"metric_harvester/internal/config"
 	"metric_harvester/internal/utils"
+	"os"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"go.uber.org/zap"
@@ -13,10 +14,62 @@ type Collector interface {
 	prometheus.Collector
 	Name() string
 	CollectMetrics(ctx context.Context) error
+	// RequiredCommands lists the commands the collector runs with the current configuration,
+	// so they can be probed at startup instead of failing every cycle
+	RequiredCommands() []string
 }
 
 type CollectorDependencies struct {
 	Executor *utils.SystemCommandExecutor
 	Logger   *zap.Logger
 	Config   *config.Config
+	// DockerAPI is nil unless containers.docker_socket is configured
+	DockerAPI *utils.DockerAPIExecutor
+	// ParseFailures counts command output the collectors couldn't parse, nil disables counting
+	ParseFailures *prometheus.CounterVec
+}
+
+// NewParseFailures creates harvester_parse_failures_total, shared by the collectors of a target
+// It measures how often the parsers miss on real output, e.g. a podman version changing its stats format
+func NewParseFailures() *prometheus.CounterVec {
+	return prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "harvester_parse_failures_total",
+			Help: "Number of lines or outputs of commands that a collector failed to parse",
+		},
+		[]string{"collector", "kind"},
+	)
+}
+
+// Named returns a copy of the dependencies whose logger is named after the collector,
+// so its level can be set on its own in logging.levels
+func (d *CollectorDependencies) Named(name string) *CollectorDependencies {
+	named := *d
+	named.Logger = d.Logger.Named(name)
+	return &named
+}
+
+// parseFailed counts a line or output of the given kind, e.g. stats_line, that the collector failed to parse
+// The collector passes its own name, so the collector label is set however its dependencies were built
+func (d *CollectorDependencies) parseFailed(collector, kind string) {
+	if d.ParseFailures == nil {
+		return
+	}
+	d.ParseFailures.WithLabelValues(collector, kind).Inc()
+}
+
+// remote reports whether the commands run on an ssh target, whose filesystem isn't the harvester's
+func (d *CollectorDependencies) remote() bool {
+	return d.Config != nil && d.Config.Executor.SSHHost != ""
+}
+
+// readProcFile reads a file of the collected host's /proc
+// The local /proc is read in-process rather than by starting cat every cycle
+// The command it runs on an ssh target is:
+// - cat path
+func (d *CollectorDependencies) readProcFile(ctx context.Context, path string) ([]byte, error) {
+	if d.remote() {
+		return d.Executor.Execute(ctx, "cat", path)
+	}
+	return os.ReadFile(path)
 }
@@ -0,0 +1,157 @@
+package collectors
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+// Socket states in /proc/net/{tcp,udp}
+const (
+	socketStateListen = "0A" // TCP_LISTEN
+	socketStateClose  = "07" // TCP_CLOSE, used by unconnected (bound) UDP sockets
+)
+
+// ListeningPortsCollector collects the inventory of listening TCP and UDP ports
+// It confirms the api_caller and harvester are bound where expected in each namespace configuration
+type ListeningPortsCollector struct {
+	deps *CollectorDependencies
+
+	// Prometheus metrics
+	// listeningPort: always 1 for each listening port
+	listeningPort *prometheus.GaugeVec
+
+	// procfs checks whether the collected host has /proc, procUnsupported warns once when it hasn't, e.g. on macOS
+	procfs          hostFileCheck
+	procUnsupported unsupportedWarning
+}
+
+// NewListeningPortsCollector creates a new ListeningPortsCollector
+// Args:
+// - deps: CollectorDependencies
+// Returns:
+// - *ListeningPortsCollector: new ListeningPortsCollector instance
+func NewListeningPortsCollector(deps *CollectorDependencies) *ListeningPortsCollector {
+	return &ListeningPortsCollector{
+		deps: deps,
+		listeningPort: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "network_listening_port",
+				Help: "Port has a listening socket (always 1)",
+			},
+			[]string{"proto", "port"}, // tcp, udp
+		),
+	}
+}
+
+func (c *ListeningPortsCollector) Name() string {
+	return "listening_ports"
+}
+
+func (c *ListeningPortsCollector) RequiredCommands() []string {
+	return []string{"cat"}
+}
+
+func (c *ListeningPortsCollector) Describe(ch chan<- *prometheus.Desc) {
+	c.listeningPort.Describe(ch)
+}
+
+func (c *ListeningPortsCollector) Collect(ch chan<- prometheus.Metric) {
+	c.listeningPort.Collect(ch)
+}
+
+// CollectMetrics collects the listening ports
+// IPv4 and IPv6 sockets on the same port are reported once
+// The commands it runs are:
+// - cat /proc/net/tcp /proc/net/tcp6
+// - cat /proc/net/udp /proc/net/udp6
+func (c *ListeningPortsCollector) CollectMetrics(ctx context.Context) error {
+	c.deps.Logger.Debug("Collecting listening ports")
+
+	if !c.procUnsupported.check(c.procfs.check(ctx, c.deps, procfsProbe), c.deps.Logger, c.Name()) {
+		return nil
+	}
+
+	result := NewCollectionError(c.Name())
+	ports := make(map[string]map[string]bool)
+
+	for _, proto := range []string{"tcp", "udp"} {
+		state := socketStateListen
+		if proto == "udp" {
+			state = socketStateClose
+		}
+
+		ports[proto] = make(map[string]bool)
+		err := c.collectListeningPorts(ctx, "/proc/net/"+proto, state, ports[proto])
+		if result.Record(proto, err) != nil {
+			c.deps.Logger.Error("Failed to collect listening ports",
+				zap.String("proto", proto),
+				zap.Error(err))
+			continue
+		}
+
+		// IPv6 may be disabled, in which case the file doesn't exist
+		if err := c.collectListeningPorts(ctx, "/proc/net/"+proto+"6", state, ports[proto]); err != nil {
+			c.deps.Logger.Debug("Skipping IPv6 listening ports",
+				zap.String("proto", proto),
+				zap.Error(err))
+		}
+	}
+
+	// Ports that closed since the last cycle are dropped
+	c.listeningPort.Reset()
+	for proto, protoPorts := range ports {
+		for port := range protoPorts {
+			c.listeningPort.WithLabelValues(proto, port).Set(1)
+		}
+	}
+
+	return result.ErrOrNil()
+}
+
+// collectListeningPorts reads a /proc/net socket table and adds the local ports of sockets in the given state
+func (c *ListeningPortsCollector) collectListeningPorts(ctx context.Context, path, state string, ports map[string]bool) error {
+	output, err := c.deps.Executor.Execute(ctx, "cat", path)
+	if err != nil {
+		return err
+	}
+
+	for port := range parseListeningPorts(string(output), state) {
+		ports[port] = true
+	}
+	return nil
+}
+
+// parseListeningPorts parses a /proc/net/{tcp,udp}[6] socket table into the set of local ports in the given state
+// Example:
+// "  sl  local_address rem_address   st tx_queue rx_queue ..."
+// "   0: 00000000:1F90 00000000:0000 0A 00000000:00000000 ..."
+// The local port is the hex number after the colon, 1F90 = 8080
+func parseListeningPorts(output, state string) map[string]bool {
+	ports := make(map[string]bool)
+
+	for i, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		// Skip header and short lines
+		if i == 0 || len(fields) < 4 || fields[3] != state {
+			continue
+		}
+
+		localAddress := fields[1]
+		colon := strings.LastIndex(localAddress, ":")
+		if colon < 0 {
+			continue
+		}
+
+		port, err := strconv.ParseUint(localAddress[colon+1:], 16, 16)
+		if err != nil {
+			continue
+		}
+		ports[strconv.FormatUint(port, 10)] = true
+	}
+
+	return ports
+}
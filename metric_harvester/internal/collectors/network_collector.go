@@ -2,9 +2,16 @@ package collectors
 
 import (
 	"context"
+	"metric_harvester/internal/config"
+	"net"
+	"net/http"
+	"path/filepath"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"go.uber.org/zap"
@@ -25,10 +32,65 @@ type NetworkCollector struct {
 	interfaceTxDropped *prometheus.GaugeVec
 	interfaceUp        *prometheus.GaugeVec
 
+	// Prometheus metrics for the sum over all monitored interfaces, a single host-wide throughput line
+	totalRxBytes prometheus.Gauge
+	totalTxBytes prometheus.Gauge
+
+	// Prometheus metrics for interface throughput averaged over the last rate_window cycles
+	interfaceRxRate *prometheus.GaugeVec
+	interfaceTxRate *prometheus.GaugeVec
+	rates           *rateTracker
+
+	// Prometheus metrics for qdiscs, whose shaping drops don't show up in /proc/net/dev
+	qdiscDrops      *prometheus.GaugeVec
+	qdiscOverlimits *prometheus.GaugeVec
+
+	// Prometheus metrics for rx/tx queues, veth typically has one of each where a physical NIC has many
+	queueCount *prometheus.GaugeVec
+	queueBytes *prometheus.GaugeVec
+
+	// interfaceCapWarning warns once when metrics.max_interfaces is first hit, later cycles log at debug
+	interfaceCapWarning sync.Once
+
+	// ethtoolWarning warns once when ethtool -S fails for every interface, e.g. when ethtool isn't installed
+	ethtoolWarning sync.Once
+
+	// interfaces are the monitored interfaces seen in the last /proc/net/dev or ip -s link read
+	interfaces []string
+
+	// ignoredInterfacePatterns are the compiled network.ignored_interface_patterns
+	ignoredInterfacePatterns []*regexp.Regexp
+
+	// procfs checks whether the collected host has /proc, procUnsupported warns once when it hasn't, e.g. on macOS
+	procfs          hostFileCheck
+	procUnsupported unsupportedWarning
+
+	// noInterfacesWarning warns once when no monitored interface is found, which otherwise silently empties the
+	// interface metrics, e.g. inside a rootless network namespace
+	noInterfacesWarning sync.Once
+
+	// linkFallbackWarning warns once when network.interface_source auto first falls back to ip -s link
+	linkFallbackWarning sync.Once
+
+	// fpingMissing is set once network.ping_backend fping found no fping, later cycles use ping right away
+	fpingMissing bool
+
+	// noDefaultRoute is whether the last cycle skipped external ping targets for lack of a default route,
+	// so the skip is logged when it starts and ends rather than every cycle
+	noDefaultRoute bool
+
 	// Prometheus metrics for connectivity tests
 	pingLatency    *prometheus.GaugeVec
 	pingPacketLoss *prometheus.GaugeVec
 	pingReachable  *prometheus.GaugeVec
+	pingRTT        *prometheus.HistogramVec
+
+	// Prometheus metrics for the connection phases of HTTP targets, in milliseconds
+	httpDNS       *prometheus.GaugeVec
+	httpConnect   *prometheus.GaugeVec
+	httpTLS       *prometheus.GaugeVec
+	httpFirstByte *prometheus.GaugeVec
+	httpClient    *http.Client
 }
 
 // NewNetworkCollector creates a new NetworkCollector
@@ -38,7 +100,9 @@ type NetworkCollector struct {
 // - *NetworkCollector: new NetworkCollector instance
 func NewNetworkCollector(deps *CollectorDependencies) *NetworkCollector {
 	return &NetworkCollector{
-		deps: deps,
+		deps:                     deps,
+		rates:                    newRateTracker(deps.Config.Metrics.RateWindow),
+		ignoredInterfacePatterns: compilePatterns(deps.Config.Network.IgnoredInterfacePatterns),
 		interfaceRxBytes: prometheus.NewGaugeVec(
 			prometheus.GaugeOpts{
 				Name: "network_interface_rx_bytes_total",
@@ -95,13 +159,67 @@ func NewNetworkCollector(deps *CollectorDependencies) *NetworkCollector {
 			},
 			[]string{"interface"},
 		),
+		interfaceRxRate: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "network_interface_rx_bytes_per_second",
+				Help: "Receive rate on network interface in bytes per second, averaged over the rate window",
+			},
+			[]string{"interface"},
+		),
+		interfaceTxRate: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "network_interface_tx_bytes_per_second",
+				Help: "Transmit rate on network interface in bytes per second, averaged over the rate window",
+			},
+			[]string{"interface"},
+		),
 		interfaceUp: prometheus.NewGaugeVec(
 			prometheus.GaugeOpts{
 				Name: "network_interface_up",
-				Help: "Network interface is up (1) or down (0)",
+				Help: "Network interface is up (1) or down (0), from /sys/class/net or inferred from traffic",
 			},
 			[]string{"interface"},
 		),
+		totalRxBytes: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "network_total_rx_bytes",
+				Help: "Total received bytes summed over all monitored network interfaces",
+			},
+		),
+		totalTxBytes: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "network_total_tx_bytes",
+				Help: "Total transmitted bytes summed over all monitored network interfaces",
+			},
+		),
+		qdiscDrops: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "network_qdisc_drops_total",
+				Help: "Total packets dropped by the qdisc",
+			},
+			[]string{"interface", "qdisc", "handle"}, // qdisc kind, e.g. fq_codel, and its handle, e.g. 0:
+		),
+		qdiscOverlimits: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "network_qdisc_overlimits_total",
+				Help: "Total times the qdisc was over its rate limit",
+			},
+			[]string{"interface", "qdisc", "handle"},
+		),
+		queueCount: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "network_interface_queue_count",
+				Help: "Number of rx or tx queues of the network interface",
+			},
+			[]string{"interface", "direction"}, // rx, tx
+		),
+		queueBytes: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "network_interface_queue_bytes_total",
+				Help: "Total bytes through a single rx or tx queue of the network interface, as reported by the driver",
+			},
+			[]string{"interface", "direction", "queue"},
+		),
 		pingLatency: prometheus.NewGaugeVec(
 			prometheus.GaugeOpts{
 				Name: "network_ping_latency_milliseconds",
@@ -123,6 +241,43 @@ func NewNetworkCollector(deps *CollectorDependencies) *NetworkCollector {
 			},
 			[]string{"target"},
 		),
+		pingRTT: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "network_ping_rtt_seconds",
+				Help:    "Round-trip time of individual ping packets to target host in seconds",
+				Buckets: deps.Config.Metrics.LatencyBuckets,
+			},
+			[]string{"target"},
+		),
+		httpDNS: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "network_http_dns_milliseconds",
+				Help: "DNS lookup time of a request to the HTTP target in milliseconds",
+			},
+			[]string{"target"},
+		),
+		httpConnect: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "network_http_connect_milliseconds",
+				Help: "TCP connect time of a request to the HTTP target in milliseconds",
+			},
+			[]string{"target"},
+		),
+		httpTLS: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "network_http_tls_milliseconds",
+				Help: "TLS handshake time of a request to the HTTP target in milliseconds",
+			},
+			[]string{"target"},
+		),
+		httpFirstByte: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "network_http_ttfb_milliseconds",
+				Help: "Time from sending a request to the HTTP target to the first response byte in milliseconds",
+			},
+			[]string{"target"},
+		),
+		httpClient: newHTTPTimingClient(),
 	}
 }
 
@@ -130,6 +285,26 @@ func (c *NetworkCollector) Name() string {
 	return "network"
 }
 
+func (c *NetworkCollector) RequiredCommands() []string {
+	commands := []string{"cat"}
+	// With auto, ip is an optional fallback
+	if c.deps.Config.Network.InterfaceSource == config.InterfaceSourceIP {
+		commands = append(commands, "ip")
+	}
+	// fping falls back to ping, so ping is required either way
+	if len(c.deps.Config.Network.PingTargets) > 0 {
+		commands = append(commands, c.deps.Config.Network.PingPath)
+	}
+	if c.deps.Config.Network.EnableQdiscStats {
+		commands = append(commands, "tc")
+	}
+	// ethtool is optional, without it only the queue counts are collected
+	if c.deps.Config.Network.EnableQueueStats {
+		commands = append(commands, "ls")
+	}
+	return commands
+}
+
 func (c *NetworkCollector) Describe(ch chan<- *prometheus.Desc) {
 	c.interfaceRxBytes.Describe(ch)
 	c.interfaceTxBytes.Describe(ch)
@@ -140,9 +315,22 @@ func (c *NetworkCollector) Describe(ch chan<- *prometheus.Desc) {
 	c.interfaceRxDropped.Describe(ch)
 	c.interfaceTxDropped.Describe(ch)
 	c.interfaceUp.Describe(ch)
+	c.totalRxBytes.Describe(ch)
+	c.totalTxBytes.Describe(ch)
+	c.interfaceRxRate.Describe(ch)
+	c.interfaceTxRate.Describe(ch)
+	c.qdiscDrops.Describe(ch)
+	c.qdiscOverlimits.Describe(ch)
+	c.queueCount.Describe(ch)
+	c.queueBytes.Describe(ch)
 	c.pingLatency.Describe(ch)
 	c.pingPacketLoss.Describe(ch)
 	c.pingReachable.Describe(ch)
+	c.pingRTT.Describe(ch)
+	c.httpDNS.Describe(ch)
+	c.httpConnect.Describe(ch)
+	c.httpTLS.Describe(ch)
+	c.httpFirstByte.Describe(ch)
 }
 
 func (c *NetworkCollector) Collect(ch chan<- prometheus.Metric) {
@@ -155,56 +343,219 @@ func (c *NetworkCollector) Collect(ch chan<- prometheus.Metric) {
 	c.interfaceRxDropped.Collect(ch)
 	c.interfaceTxDropped.Collect(ch)
 	c.interfaceUp.Collect(ch)
+	c.totalRxBytes.Collect(ch)
+	c.totalTxBytes.Collect(ch)
+	c.interfaceRxRate.Collect(ch)
+	c.interfaceTxRate.Collect(ch)
+	c.qdiscDrops.Collect(ch)
+	c.qdiscOverlimits.Collect(ch)
+	c.queueCount.Collect(ch)
+	c.queueBytes.Collect(ch)
 	c.pingLatency.Collect(ch)
 	c.pingPacketLoss.Collect(ch)
 	c.pingReachable.Collect(ch)
+	c.pingRTT.Collect(ch)
+	c.httpDNS.Collect(ch)
+	c.httpConnect.Collect(ch)
+	c.httpTLS.Collect(ch)
+	c.httpFirstByte.Collect(ch)
 }
 
 // CollectMetrics collects network metrics
 // This is the main function that collects all the network metrics
 // The commands it runs are:
-// - cat /proc/net/dev
+// - cat /proc/net/dev, or ip -s link depending on network.interface_source
 // - ping -c 3 target
+// - cat /proc/net/route, with network.skip_ping_without_route
+// - tc -s qdisc show dev <iface>, when network.enable_qdisc_stats is set
+// - ls /sys/class/net/<iface>/queues and ethtool -S <iface>, when network.enable_queue_stats is set
+// HTTP targets are requested by the harvester itself, without running a command
+// It returns a *CollectionError describing which sub-collections failed, if any
 func (c *NetworkCollector) CollectMetrics(ctx context.Context) error {
 	c.deps.Logger.Debug("Collecting network metrics")
 
+	result := NewCollectionError(c.Name())
+
 	// Collect network interface statistics
-	if err := c.collectInterfaceMetrics(ctx); err != nil {
+	if err := result.Record("interfaces", c.collectInterfaceMetrics(ctx)); err != nil {
 		c.deps.Logger.Error("Failed to collect network interface metrics", zap.Error(err))
 	}
+	c.rates.Prune(nil)
+
+	// Collect qdisc statistics of the interfaces found above
+	if c.deps.Config.Network.EnableQdiscStats {
+		if err := result.Record("qdisc", c.collectQdiscMetrics(ctx)); err != nil {
+			c.deps.Logger.Error("Failed to collect qdisc metrics", zap.Error(err))
+		}
+	}
+
+	// Collect rx/tx queue statistics of the interfaces found above
+	if c.deps.Config.Network.EnableQueueStats {
+		if err := result.Record("queues", c.collectQueueMetrics(ctx)); err != nil {
+			c.deps.Logger.Error("Failed to collect interface queue metrics", zap.Error(err))
+		}
+	}
 
 	// Collect ping metrics for configured targets
-	if err := c.collectPingMetrics(ctx); err != nil {
+	if err := result.Record("ping", c.collectPingMetrics(ctx)); err != nil {
 		c.deps.Logger.Error("Failed to collect ping metrics", zap.Error(err))
 	}
 
-	return nil
+	// Time the connection phases of configured HTTP targets
+	if len(c.deps.Config.Network.HTTPTargets) > 0 {
+		if err := result.Record("http", c.collectHTTPMetrics(ctx)); err != nil {
+			c.deps.Logger.Error("Failed to collect HTTP target metrics", zap.Error(err))
+		}
+	}
+
+	return result.ErrOrNil()
 }
 
 // collectInterfaceMetrics collects network interface statistics
 // This is the main function that collects all the network interface statistics
-// The command it runs is:
+// network.interface_source picks /proc/net/dev, ip -s link, or /proc/net/dev falling back to ip -s link when
+// it can't be read or shows no monitored interface
+// The commands it runs are:
 // - cat /proc/net/dev
+// - ip -s link
 func (c *NetworkCollector) collectInterfaceMetrics(ctx context.Context) error {
-	// Get network interface statistics from /proc/net/dev on Linux
-	output, err := c.deps.Executor.Execute(ctx, "cat", "/proc/net/dev")
+	source := c.deps.Config.Network.InterfaceSource
+
+	if source != config.InterfaceSourceIP {
+		if !c.procUnsupported.check(c.procfs.check(ctx, c.deps, procfsProbe), c.deps.Logger, "network interfaces") {
+			return nil
+		}
+
+		// Get network interface statistics from /proc/net/dev on Linux
+		output, err := c.deps.Executor.Execute(ctx, "cat", "/proc/net/dev")
+		var candidates []interfaceLine
+		var listed int
+		if err == nil {
+			candidates, listed = c.parseInterfaceStats(string(output))
+		}
+		if source == config.InterfaceSourceProc || (err == nil && len(candidates) > 0) {
+			if err != nil {
+				return err
+			}
+			c.setInterfaceStats(candidates, listed, "/proc/net/dev")
+			c.setInterfaceUpStates(ctx)
+			return nil
+		}
+
+		c.linkFallbackWarning.Do(func() {
+			c.deps.Logger.Warn("No monitored interface in /proc/net/dev, falling back to ip -s link",
+				zap.Int("interfaces", listed),
+				zap.Error(err))
+		})
+	}
+
+	output, err := c.deps.Executor.GetInterfaceLinkStats(ctx)
 	if err != nil {
 		return err
 	}
+	candidates, listed := c.parseLinkStats(string(output))
+	c.setInterfaceStats(candidates, listed, "ip -s link")
+	c.setInterfaceUpStates(ctx)
+	return nil
+}
+
+// setInterfaceUpStates sets network_interface_up of every monitored interface in /sys/class/net, with
+// network.sysfs_up_state. Interfaces without traffic, e.g. an idle rootless bridge, are up rather than inferred down,
+// and interfaces missing from the statistics still get a sample. With metrics.max_interfaces only the interfaces
+// kept by the cap get one. When sysfs can't be read, the up state inferred from traffic stays
+// The commands it runs are:
+// - ls /sys/class/net
+// - grep -H . /sys/class/net/<iface>/operstate /sys/class/net/<iface>/flags ...
+func (c *NetworkCollector) setInterfaceUpStates(ctx context.Context) {
+	if !c.deps.Config.Network.SysfsUpState {
+		return
+	}
+
+	output, err := c.deps.Executor.Execute(ctx, "ls", "/sys/class/net")
+	if err != nil {
+		c.deps.Logger.Debug("Failed to list /sys/class/net, inferring interface up state from traffic", zap.Error(err))
+		return
+	}
+
+	var ifaces []string
+	if c.deps.Config.Metrics.MaxInterfaces > 0 {
+		ifaces = c.interfaces
+	} else {
+		for _, name := range strings.Fields(string(output)) {
+			// bonding_masters is a file of the bonding driver, not an interface
+			if name != "bonding_masters" && c.isInterfaceReported(name) {
+				ifaces = append(ifaces, name)
+			}
+		}
+	}
+	if len(ifaces) == 0 {
+		return
+	}
+
+	// An interface that vanished since the listing is left out, the others are still read
+	output, err = c.deps.Executor.GetInterfaceStates(ctx, ifaces)
+	if err != nil {
+		c.deps.Logger.Debug("Failed to read interface states from /sys/class/net", zap.Error(err))
+		return
+	}
+	for name, up := range parseInterfaceStates(string(output)) {
+		value := 0.0
+		if up {
+			value = 1.0
+		}
+		c.interfaceUp.WithLabelValues(name).Set(value)
+	}
+}
+
+// parseInterfaceStates parses grep -H output of operstate and flags files into interface -> up
+// An interface is up when its operstate is up, or unknown with IFF_UP set in its flags, as for loopback and tun devices
+// Example:
+// "/sys/class/net/eth0/operstate:up"
+// "/sys/class/net/lo/operstate:unknown"
+// "/sys/class/net/lo/flags:0x9"
+func parseInterfaceStates(output string) map[string]bool {
+	operstates := make(map[string]string)
+	flags := make(map[string]int64)
+	for _, line := range strings.Split(output, "\n") {
+		path, value, ok := strings.Cut(strings.TrimSpace(line), ":")
+		if !ok {
+			continue
+		}
+		dir, file := filepath.Split(path)
+		name := filepath.Base(dir)
+		switch file {
+		case "operstate":
+			operstates[name] = value
+		case "flags":
+			if parsed, err := strconv.ParseInt(value, 0, 64); err == nil {
+				flags[name] = parsed
+			}
+		}
+	}
 
-	return c.parseInterfaceStats(string(output))
+	const iffUp = 0x1
+	states := make(map[string]bool, len(operstates))
+	for name, operstate := range operstates {
+		states[name] = operstate == "up" || (operstate == "unknown" && flags[name]&iffUp != 0)
+	}
+	return states
 }
 
 // collectPingMetrics collects ping metrics
 // This is the main function that collects all the ping metrics
+// With network.skip_ping_without_route, external targets are marked unreachable without pinging them while
+// there is no default route, see markPingSkipped; loopback, private and link-local addresses are still pinged
 // The commands it runs are:
-// - ping -c 3 target
+// With network.ping_backend fping, all targets are pinged by a single fping, see collectFPingMetrics
+// The commands it runs are:
+// - cat /proc/net/route and /proc/net/ipv6_route, with network.skip_ping_without_route on Linux
+// - ping -c 3 target, or fping -c 3 target...
 func (c *NetworkCollector) collectPingMetrics(ctx context.Context) error {
 	// Default ping targets - these could be made configurable
-	targets := []string{
-		"8.8.8.8",    // Google DNS
-		"1.1.1.1",    // Cloudflare DNS
-		"google.com", // External connectivity test
+	targets := []config.PingTarget{
+		{Name: "8.8.8.8", Address: "8.8.8.8"},       // Google DNS
+		{Name: "1.1.1.1", Address: "1.1.1.1"},       // Cloudflare DNS
+		{Name: "google.com", Address: "google.com"}, // External connectivity test
 	}
 
 	// Add configured ping targets if available
@@ -212,19 +563,135 @@ func (c *NetworkCollector) collectPingMetrics(ctx context.Context) error {
 		targets = c.deps.Config.Network.PingTargets
 	}
 
+	skipExternal := c.deps.Config.Network.SkipPingWithoutRoute && !c.hasDefaultRoute(ctx)
+	if skipExternal != c.noDefaultRoute {
+		if skipExternal {
+			c.deps.Logger.Info("No default route, skipping external ping targets until one appears")
+		} else {
+			c.deps.Logger.Info("Default route found, pinging external targets again")
+		}
+		c.noDefaultRoute = skipExternal
+	}
+
+	var pinged []config.PingTarget
 	for _, target := range targets {
+		if skipExternal && !isLocalAddress(target.Address) {
+			c.markPingSkipped(target.Name)
+			continue
+		}
+		pinged = append(pinged, target)
+	}
+
+	if c.deps.Config.Network.PingBackend == config.PingBackendFPing && !c.fpingMissing && len(pinged) > 0 {
+		err := c.collectFPingMetrics(ctx, pinged)
+		if !isCommandNotFound(err) {
+			return err
+		}
+		c.deps.Logger.Warn("fping is not installed, falling back to ping", zap.Error(err))
+		c.fpingMissing = true
+	}
+
+	for _, target := range pinged {
 		if err := c.collectPingMetricsForTarget(ctx, target); err != nil {
 			c.deps.Logger.Warn("Failed to ping target",
-				zap.String("target", target),
+				zap.String("target", target.Name),
+				zap.String("address", target.Address),
 				zap.Error(err))
 			// Mark as unreachable
-			c.pingReachable.WithLabelValues(target).Set(0)
+			c.pingReachable.WithLabelValues(target.Name).Set(0)
 		}
 	}
 
 	return nil
 }
 
+// markPingSkipped reports a target that isn't pinged for lack of a default route
+// It is unreachable with all packets lost, and its latency is removed, so none of its series keeps the value
+// from before the route vanished
+func (c *NetworkCollector) markPingSkipped(target string) {
+	c.pingReachable.WithLabelValues(target).Set(0)
+	c.pingPacketLoss.WithLabelValues(target).Set(100)
+	c.pingLatency.DeleteLabelValues(target)
+}
+
+// hasDefaultRoute reports whether /proc/net/route has an IPv4 or /proc/net/ipv6_route an IPv6 default route
+// so IPv6-only hosts aren't taken for isolated ones
+// It reports true when that can't be determined, e.g. on macOS or when the file can't be read, so pings aren't
+// skipped by mistake
+// The commands it runs are:
+// - cat /proc/net/route
+// - cat /proc/net/ipv6_route, when there is no IPv4 default route
+func (c *NetworkCollector) hasDefaultRoute(ctx context.Context) bool {
+	if !c.procfs.check(ctx, c.deps, procfsProbe) {
+		return true
+	}
+
+	output, err := c.deps.Executor.Execute(ctx, "cat", "/proc/net/route")
+	if err != nil {
+		c.deps.Logger.Debug("Failed to read /proc/net/route, assuming a default route", zap.Error(err))
+		return true
+	}
+	if hasIPv4DefaultRoute(string(output)) {
+		return true
+	}
+
+	// The file is missing when IPv6 is disabled, which leaves the IPv4 answer
+	output, err = c.deps.Executor.Execute(ctx, "cat", "/proc/net/ipv6_route")
+	if err != nil {
+		c.deps.Logger.Debug("Failed to read /proc/net/ipv6_route", zap.Error(err))
+		return false
+	}
+	return hasIPv6DefaultRoute(string(output))
+}
+
+// hasIPv4DefaultRoute reports whether /proc/net/route output has a route with destination and mask 0
+// Example:
+// "Iface	Destination	Gateway 	Flags	RefCnt	Use	Metric	Mask		MTU	Window	IRTT"
+// "eth0	00000000	0100A8C0	0003	0	0	0	00000000	0	0	0"
+func hasIPv4DefaultRoute(output string) bool {
+	for _, line := range strings.Split(output, "\n")[1:] {
+		fields := strings.Fields(line)
+		if len(fields) >= 8 && fields[1] == "00000000" && fields[7] == "00000000" {
+			return true
+		}
+	}
+	return false
+}
+
+// ipv6DefaultDestination is the ::/0 destination of /proc/net/ipv6_route
+var ipv6DefaultDestination = strings.Repeat("0", 32)
+
+// hasIPv6DefaultRoute reports whether /proc/net/ipv6_route output has a usable route to ::/0
+// The kernel always lists an unreachable ::/0 route on lo, it has the RTF_REJECT flag (0x200) and is skipped
+// Fields: destination, prefix length, source, source prefix length, next hop, metric, refcnt, use, flags, interface
+// Example:
+// "00000000000000000000000000000000 00 00000000000000000000000000000000 00 fd000000000000000000000000000001 00000400 00000001 00000000 00000003     eth0"
+// "00000000000000000000000000000000 00 00000000000000000000000000000000 00 00000000000000000000000000000000 ffffffff 00000001 00000000 00200200       lo"
+func hasIPv6DefaultRoute(output string) bool {
+	const rtfUp, rtfReject = 0x1, 0x200
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 10 || fields[0] != ipv6DefaultDestination || fields[1] != "00" {
+			continue
+		}
+		flags, err := strconv.ParseUint(fields[8], 16, 32)
+		if err == nil && flags&rtfUp != 0 && flags&rtfReject == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// isLocalAddress reports whether a ping target is a loopback, private or link-local IP address,
+// which is reachable without a default route. Host names count as external
+func isLocalAddress(address string) bool {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return false
+	}
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast()
+}
+
 // parseInterfaceStats parses network interface statistics
 // This is the main function that parses the network interface statistics
 // The command it runs is:
@@ -243,9 +710,12 @@ func (c *NetworkCollector) collectPingMetrics(ctx context.Context) error {
 // fields[13] is the received dropped
 // fields[14] is the transmitted errors
 // fields[15] is the transmitted dropped
-func (c *NetworkCollector) parseInterfaceStats(output string) error {
+// It returns the monitored interfaces and the number of interfaces listed before filtering
+func (c *NetworkCollector) parseInterfaceStats(output string) ([]interfaceLine, int) {
 	lines := strings.Split(output, "\n")
 
+	var candidates []interfaceLine
+	var listed int
 	for i, line := range lines {
 		// Skip first two header lines
 		if i < 2 || strings.TrimSpace(line) == "" {
@@ -255,6 +725,7 @@ func (c *NetworkCollector) parseInterfaceStats(output string) error {
 		// Parse interface line: "eth0: 1234567 8901 0 0 0 0 0 0 2345678 9012 0 0 0 0 0 0"
 		parts := strings.Split(line, ":")
 		if len(parts) != 2 {
+			c.deps.parseFailed(c.Name(), "interface_line")
 			continue
 		}
 
@@ -263,21 +734,140 @@ func (c *NetworkCollector) parseInterfaceStats(output string) error {
 		fields := strings.Fields(statsStr)
 
 		if len(fields) < 16 {
+			c.deps.parseFailed(c.Name(), "interface_line")
 			continue
 		}
 
-		// Skip loopback interface unless specifically configured
-		if interfaceName == "lo" && !c.deps.Config.Network.MonitorLoopback {
+		listed++
+		if c.isInterfaceReported(interfaceName) {
+			candidates = append(candidates, interfaceLine{name: interfaceName, fields: fields})
+		}
+	}
+
+	return candidates, listed
+}
+
+// parseLinkStats parses the output of ip -s link into /proc/net/dev style fields, so both sources share
+// setInterfaceStats. Only the bytes, packets, errors and dropped counters are filled in, the rest are 0
+// The command it runs is:
+// - ip -s link
+// Example:
+// "2: eth0@if7: <BROADCAST,MULTICAST,UP,LOWER_UP> mtu 1500 qdisc noqueue state UP mode DEFAULT group default"
+// "    link/ether 02:42:ac:11:00:02 brd ff:ff:ff:ff:ff:ff link-netnsid 0"
+// "    RX:  bytes packets errors dropped  missed   mcast"
+// "      1234567    8901      0       0       0       0"
+// "    TX:  bytes packets errors dropped carrier collsns"
+// "      2345678    9012      0       0       0       0"
+// The @if7 suffix names the peer of a veth and is dropped, so the name matches /sys/class/net
+// It returns the monitored interfaces and the number of interfaces listed before filtering
+func (c *NetworkCollector) parseLinkStats(output string) ([]interfaceLine, int) {
+	var candidates []interfaceLine
+	var listed int
+	var current *interfaceLine
+	var counters string // "RX" or "TX" when the next line holds their values
+
+	finish := func() {
+		if current == nil {
+			return
+		}
+		listed++
+		if c.isInterfaceReported(current.name) {
+			candidates = append(candidates, *current)
+		}
+		current = nil
+	}
+
+	for _, line := range strings.Split(output, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" {
 			continue
 		}
 
-		// Skip ignored interfaces
-		if c.isInterfaceIgnored(interfaceName) {
+		// An interface starts with an unindented "<index>: <name>: <flags> ..." line
+		if line[0] != ' ' && line[0] != '\t' {
+			finish()
+			parts := strings.SplitN(trimmed, ":", 3)
+			if len(parts) < 3 {
+				c.deps.parseFailed(c.Name(), "link_line")
+				continue
+			}
+			name, _, _ := strings.Cut(strings.TrimSpace(parts[1]), "@")
+			fields := make([]string, 16)
+			for i := range fields {
+				fields[i] = "0"
+			}
+			current = &interfaceLine{name: name, fields: fields}
+			counters = ""
+			continue
+		}
+		if current == nil {
 			continue
 		}
 
+		switch {
+		case strings.HasPrefix(trimmed, "RX:"):
+			counters = "RX"
+		case strings.HasPrefix(trimmed, "TX:"):
+			counters = "TX"
+		case counters != "":
+			values := strings.Fields(trimmed)
+			if len(values) < 4 {
+				c.deps.parseFailed(c.Name(), "link_line")
+				counters = ""
+				continue
+			}
+			offset := 0
+			if counters == "TX" {
+				offset = 8
+			}
+			copy(current.fields[offset:offset+4], values[:4])
+			counters = ""
+		}
+	}
+	finish()
+
+	return candidates, listed
+}
+
+// isInterfaceReported reports whether an interface gets metrics, loopback only when network.monitor_loopback is set
+func (c *NetworkCollector) isInterfaceReported(interfaceName string) bool {
+	// Skip loopback interface unless specifically configured
+	if interfaceName == "lo" && !c.deps.Config.Network.MonitorLoopback {
+		return false
+	}
+
+	// Skip interfaces outside the allowlist and ignored interfaces
+	return c.isInterfaceMonitored(interfaceName) && !c.isInterfaceIgnored(interfaceName)
+}
+
+// setInterfaceStats sets the metrics of the monitored interfaces read from source
+// The totals sum the same interfaces that get per-interface metrics, so loopback, ignored and capped interfaces
+// are left out
+func (c *NetworkCollector) setInterfaceStats(candidates []interfaceLine, listed int, source string) {
+	now := time.Now()
+	var totalRx, totalTx float64
+	c.interfaces = c.interfaces[:0]
+
+	if len(candidates) == 0 {
+		c.noInterfacesWarning.Do(func() {
+			c.deps.Logger.Warn("No monitored network interface found, interface metrics will be empty. "+
+				"Inside a rootless network namespace only the namespace's interfaces are listed; "+
+				"check network.monitored_interfaces and the ignore lists, or try network.interface_source ip or auto",
+				zap.String("source", source),
+				zap.Int("interfaces", listed))
+		})
+	}
+
+	for _, candidate := range c.capInterfaces(candidates) {
+		interfaceName, fields := candidate.name, candidate.fields
+		c.interfaces = append(c.interfaces, interfaceName)
+
 		if rxBytes, err := strconv.ParseFloat(fields[0], 64); err == nil {
 			c.interfaceRxBytes.WithLabelValues(interfaceName).Set(rxBytes)
+			totalRx += rxBytes
+			if rate, ok := c.rates.Observe(interfaceName+"/rx", rxBytes, now); ok {
+				c.interfaceRxRate.WithLabelValues(interfaceName).Set(rate)
+			}
 		}
 		if rxPackets, err := strconv.ParseFloat(fields[1], 64); err == nil {
 			c.interfaceRxPackets.WithLabelValues(interfaceName).Set(rxPackets)
@@ -292,6 +882,10 @@ func (c *NetworkCollector) parseInterfaceStats(output string) error {
 		// Parse transmitted stats (fields 8-15)
 		if txBytes, err := strconv.ParseFloat(fields[8], 64); err == nil {
 			c.interfaceTxBytes.WithLabelValues(interfaceName).Set(txBytes)
+			totalTx += txBytes
+			if rate, ok := c.rates.Observe(interfaceName+"/tx", txBytes, now); ok {
+				c.interfaceTxRate.WithLabelValues(interfaceName).Set(rate)
+			}
 		}
 		if txPackets, err := strconv.ParseFloat(fields[9], 64); err == nil {
 			c.interfaceTxPackets.WithLabelValues(interfaceName).Set(txPackets)
@@ -303,7 +897,7 @@ func (c *NetworkCollector) parseInterfaceStats(output string) error {
 			c.interfaceTxDropped.WithLabelValues(interfaceName).Set(txDropped)
 		}
 
-		// Check if interface is up by checking if it has any activity
+		// Check if interface is up by checking if it has any activity, overridden by setInterfaceUpStates
 		isUp := 0.0
 		if rxBytes, _ := strconv.ParseFloat(fields[0], 64); rxBytes > 0 {
 			isUp = 1.0
@@ -313,67 +907,148 @@ func (c *NetworkCollector) parseInterfaceStats(output string) error {
 		c.interfaceUp.WithLabelValues(interfaceName).Set(isUp)
 	}
 
-	return nil
+	c.totalRxBytes.Set(totalRx)
+	c.totalTxBytes.Set(totalTx)
+}
+
+// interfaceLine is the name and stats fields of an interface, in /proc/net/dev order
+type interfaceLine struct {
+	name   string
+	fields []string
+}
+
+// capInterfaces keeps the metrics.max_interfaces interfaces with the most traffic, a safety valve for rootless hosts
+// with hundreds of veth interfaces. The series of the interfaces left out are deleted so the cap holds across cycles.
+// Traffic is the received plus transmitted bytes since the interface was created
+func (c *NetworkCollector) capInterfaces(candidates []interfaceLine) []interfaceLine {
+	maxInterfaces := c.deps.Config.Metrics.MaxInterfaces
+	if maxInterfaces <= 0 || len(candidates) <= maxInterfaces {
+		return candidates
+	}
+
+	traffic := func(candidate interfaceLine) float64 {
+		rx, _ := strconv.ParseFloat(candidate.fields[0], 64)
+		tx, _ := strconv.ParseFloat(candidate.fields[8], 64)
+		return rx + tx
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return traffic(candidates[i]) > traffic(candidates[j])
+	})
+
+	for _, dropped := range candidates[maxInterfaces:] {
+		labels := prometheus.Labels{"interface": dropped.name}
+		for _, vec := range []*prometheus.GaugeVec{
+			c.interfaceRxBytes, c.interfaceTxBytes, c.interfaceRxPackets, c.interfaceTxPackets,
+			c.interfaceRxErrors, c.interfaceTxErrors, c.interfaceRxDropped, c.interfaceTxDropped,
+			c.interfaceUp, c.interfaceRxRate, c.interfaceTxRate,
+		} {
+			vec.DeletePartialMatch(labels)
+		}
+	}
+
+	c.interfaceCapWarning.Do(func() {
+		c.deps.Logger.Warn("More interfaces than metrics.max_interfaces, reporting those with the most traffic",
+			zap.Int("interfaces", len(candidates)),
+			zap.Int("max_interfaces", maxInterfaces))
+	})
+	c.deps.Logger.Debug("Capped reported interfaces",
+		zap.Int("interfaces", len(candidates)),
+		zap.Int("max_interfaces", maxInterfaces))
+
+	return candidates[:maxInterfaces]
 }
 
 // collectPingMetricsForTarget collects ping metrics for a target
 // This is the main function that collects all the ping metrics for a target
 // The command it runs is:
-// - ping -c 3 target
+// - ping -c 3 <address>
+// The metrics are labeled with the target's name, which is its address unless configured otherwise
 // Example: "64 bytes from 8.8.8.8: icmp_seq=1 ttl=118 time=12.3 ms"
-func (c *NetworkCollector) collectPingMetricsForTarget(ctx context.Context, target string) error {
-	output, err := c.deps.Executor.PingHost(ctx, target, 3) // Send 3 pings
+func (c *NetworkCollector) collectPingMetricsForTarget(ctx context.Context, target config.PingTarget) error {
+	output, err := c.deps.Executor.PingHost(ctx, target.Address, 3) // Send 3 pings
 	if err != nil {
 		return err
 	}
 
-	return c.parsePingOutput(string(output), target)
+	return c.parsePingOutput(string(output), target.Name)
 }
 
 // parsePingOutput parses ping output
 // This is the main function that parses the ping output
-// Example: "64 bytes from 8.8.8.8: icmp_seq=1 ttl=118 time=12.3 ms"
+// Both GNU (iputils) and BusyBox (Alpine) ping formats are supported:
+// GNU:
+// "64 bytes from 8.8.8.8: icmp_seq=1 ttl=118 time=12.3 ms"
+// "3 packets transmitted, 3 received, 0% packet loss, time 2002ms"
+// "rtt min/avg/max/mdev = 10.100/12.300/14.500/1.796 ms"
+// BusyBox:
+// "64 bytes from 8.8.8.8: seq=0 ttl=55 time=12.300 ms"
+// "3 packets transmitted, 3 packets received, 0% packet loss"
+// "round-trip min/avg/max = 10.100/12.300/14.500 ms"
+// The latency is the avg of the rtt summary, which ping computes over every reply; the mean of the time= lines is
+// only the fallback, as lines can be lost to buffering when ping is killed at the timeout. E.g. with replies of
+// 10.1, 12.3 and 14.5 ms where the 14.5 line was lost, the summary still says 12.3 where the mean would say 11.2
 func (c *NetworkCollector) parsePingOutput(output, target string) error {
 	lines := strings.Split(output, "\n")
 
 	var latencies []float64
 	var packetsSent, packetsReceived int
+	summaryAvg, hasSummaryAvg := 0.0, false
 
 	// Parse ping output
 	for _, line := range lines {
 		line = strings.TrimSpace(line)
 
 		// Parse individual ping lines: "64 bytes from 8.8.8.8: icmp_seq=1 ttl=118 time=12.3 ms"
+		// BusyBox uses "seq=" instead of "icmp_seq=", which doesn't matter since only time= is read
 		if strings.Contains(line, "time=") {
 			timeRegex := regexp.MustCompile(`time=([\d.]+)\s*ms`)
 			matches := timeRegex.FindStringSubmatch(line)
 			if len(matches) == 2 {
 				if latency, err := strconv.ParseFloat(matches[1], 64); err == nil {
 					latencies = append(latencies, latency)
+					c.pingRTT.WithLabelValues(target).Observe(latency / 1000)
 				}
+			} else {
+				c.deps.parseFailed(c.Name(), "ping_reply")
 			}
 			packetsReceived++
 		}
 
 		// Parse summary line: "3 packets transmitted, 3 received, 0% packet loss, time 2002ms"
+		// BusyBox says "3 packets received" instead of "3 received"
 		if strings.Contains(line, "packets transmitted") {
-			summaryRegex := regexp.MustCompile(`(\d+) packets transmitted, (\d+) received`)
+			summaryRegex := regexp.MustCompile(`(\d+) packets transmitted, (\d+) (?:packets )?received`)
 			matches := summaryRegex.FindStringSubmatch(line)
 			if len(matches) == 3 {
 				packetsSent, _ = strconv.Atoi(matches[1])
 				packetsReceived, _ = strconv.Atoi(matches[2])
+			} else {
+				c.deps.parseFailed(c.Name(), "ping_summary")
+			}
+		}
+
+		// Parse rtt summary line: "rtt min/avg/max/mdev = 10.100/12.300/14.500/1.796 ms"
+		// BusyBox and macOS say "round-trip" instead of "rtt"
+		if strings.Contains(line, "min/avg/max") {
+			if avg, ok := parseRTTSummaryAvg(line); ok {
+				summaryAvg, hasSummaryAvg = avg, true
+			} else {
+				c.deps.parseFailed(c.Name(), "ping_rtt_summary")
 			}
 		}
 	}
 
 	// Calculate metrics
-	if len(latencies) > 0 {
-		// Use average latency
-		var totalLatency float64
-		for _, lat := range latencies {
-			totalLatency += lat
+	if hasSummaryAvg || len(latencies) > 0 {
+		avgLatency := summaryAvg
+		if !hasSummaryAvg {
+			// Fall back to the mean of the replies that were printed
+			var totalLatency float64
+			for _, lat := range latencies {
+				totalLatency += lat
+			}
+			avgLatency = totalLatency / float64(len(latencies))
 		}
-		avgLatency := totalLatency / float64(len(latencies))
 		c.pingLatency.WithLabelValues(target).Set(avgLatency)
 
 		// Host is reachable
@@ -392,11 +1067,213 @@ func (c *NetworkCollector) parsePingOutput(output, target string) error {
 	return nil
 }
 
+// rttSummaryRe matches the values of a ping or fping rtt summary, of which the second is the average
+var rttSummaryRe = regexp.MustCompile(`min/avg/max\S* = ([\d.]+)/([\d.]+)/`)
+
+// parseRTTSummaryAvg parses the average of an rtt summary in milliseconds
+// Example: "rtt min/avg/max/mdev = 10.100/12.300/14.500/1.796 ms"
+// Example: "8.8.8.8 : xmt/rcv/%loss = 3/3/0%, min/avg/max = 10.1/12.3/14.5"
+func parseRTTSummaryAvg(line string) (float64, bool) {
+	matches := rttSummaryRe.FindStringSubmatch(line)
+	if matches == nil {
+		return 0, false
+	}
+	avg, err := strconv.ParseFloat(matches[2], 64)
+	return avg, err == nil
+}
+
+// isInterfaceMonitored checks if an interface is in the allowlist
+// An empty allowlist monitors every interface
+func (c *NetworkCollector) isInterfaceMonitored(interfaceName string) bool {
+	if len(c.deps.Config.Network.MonitoredInterfaces) == 0 {
+		return true
+	}
+	for _, monitored := range c.deps.Config.Network.MonitoredInterfaces {
+		if interfaceName == monitored {
+			return true
+		}
+	}
+	return false
+}
+
+// isInterfaceIgnored checks if an interface should be ignored, by exact name or pattern
 func (c *NetworkCollector) isInterfaceIgnored(interfaceName string) bool {
 	for _, ignored := range c.deps.Config.Network.IgnoredInterfaces {
 		if interfaceName == ignored {
 			return true
 		}
 	}
-	return false
+	return matchesAny(c.ignoredInterfacePatterns, interfaceName)
+}
+
+// qdiscStats are the counters of a single qdisc
+type qdiscStats struct {
+	kind       string
+	handle     string
+	drops      float64
+	overlimits float64
+}
+
+// collectQdiscMetrics collects the drops and overlimits of the qdiscs of each monitored interface
+// Interfaces that vanished since they were read from /proc/net/dev, e.g. a veth of a stopped container, are skipped
+// The command it runs is:
+// - tc -s qdisc show dev <iface>
+func (c *NetworkCollector) collectQdiscMetrics(ctx context.Context) error {
+	c.qdiscDrops.Reset()
+	c.qdiscOverlimits.Reset()
+
+	var lastErr error
+	succeeded := 0
+	for _, interfaceName := range c.interfaces {
+		output, err := c.deps.Executor.GetQdiscStats(ctx, interfaceName)
+		if err != nil {
+			c.deps.Logger.Debug("Failed to get qdisc stats",
+				zap.String("interface", interfaceName),
+				zap.Error(err))
+			lastErr = err
+			continue
+		}
+		succeeded++
+
+		for _, qdisc := range parseQdiscStats(string(output)) {
+			c.qdiscDrops.WithLabelValues(interfaceName, qdisc.kind, qdisc.handle).Set(qdisc.drops)
+			c.qdiscOverlimits.WithLabelValues(interfaceName, qdisc.kind, qdisc.handle).Set(qdisc.overlimits)
+		}
+	}
+
+	// Every interface failing means tc itself is broken or missing rather than an interface going away
+	if succeeded == 0 {
+		return lastErr
+	}
+	return nil
+}
+
+// qdiscCountersRe matches the counters on the "Sent" line of a qdisc
+var qdiscCountersRe = regexp.MustCompile(`dropped (\d+), overlimits (\d+)`)
+
+// parseQdiscStats parses the output of tc -s qdisc show into the counters of each qdisc
+// Example:
+// "qdisc fq_codel 0: root refcnt 2 limit 10240p flows 1024 quantum 1514 target 5ms interval 100ms"
+// " Sent 217256 bytes 2083 pkt (dropped 3, overlimits 12 requeues 0)"
+// " backlog 0b 0p requeues 0"
+func parseQdiscStats(output string) []qdiscStats {
+	var qdiscs []qdiscStats
+
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 3 && fields[0] == "qdisc" {
+			qdiscs = append(qdiscs, qdiscStats{kind: fields[1], handle: fields[2]})
+			continue
+		}
+
+		matches := qdiscCountersRe.FindStringSubmatch(line)
+		if matches == nil || len(qdiscs) == 0 {
+			continue
+		}
+		current := &qdiscs[len(qdiscs)-1]
+		current.drops, _ = strconv.ParseFloat(matches[1], 64)
+		current.overlimits, _ = strconv.ParseFloat(matches[2], 64)
+	}
+
+	return qdiscs
+}
+
+// collectQueueMetrics collects the rx/tx queue counts and per-queue byte counters of each monitored interface
+// Queue counts come from sysfs and show the parallelism an interface allows, e.g. one queue pair on a rootless veth.
+// Per-queue bytes come from ethtool -S and are skipped where ethtool is missing or the driver doesn't report them.
+// Interfaces that vanished since they were read from /proc/net/dev are skipped
+// The commands it runs are:
+// - ls /sys/class/net/<iface>/queues
+// - ethtool -S <iface>
+func (c *NetworkCollector) collectQueueMetrics(ctx context.Context) error {
+	c.queueCount.Reset()
+	c.queueBytes.Reset()
+
+	var lastErr error
+	succeeded, ethtoolSucceeded := 0, 0
+	for _, interfaceName := range c.interfaces {
+		output, err := c.deps.Executor.GetInterfaceQueues(ctx, interfaceName)
+		if err != nil {
+			c.deps.Logger.Debug("Failed to list interface queues",
+				zap.String("interface", interfaceName),
+				zap.Error(err))
+			lastErr = err
+			continue
+		}
+		succeeded++
+
+		rx, tx := countInterfaceQueues(string(output))
+		c.queueCount.WithLabelValues(interfaceName, "rx").Set(rx)
+		c.queueCount.WithLabelValues(interfaceName, "tx").Set(tx)
+
+		output, err = c.deps.Executor.GetInterfaceDriverStats(ctx, interfaceName)
+		if err != nil {
+			c.deps.Logger.Debug("Failed to get interface driver stats",
+				zap.String("interface", interfaceName),
+				zap.Error(err))
+			continue
+		}
+		ethtoolSucceeded++
+
+		for _, queue := range parseQueueBytes(string(output)) {
+			c.queueBytes.WithLabelValues(interfaceName, queue.direction, queue.queue).Set(queue.bytes)
+		}
+	}
+
+	if succeeded > 0 && ethtoolSucceeded == 0 {
+		c.ethtoolWarning.Do(func() {
+			c.deps.Logger.Warn("ethtool -S failed for every interface, skipping per-queue byte counters")
+		})
+	}
+
+	// Every interface failing means sysfs can't be read rather than an interface going away
+	if succeeded == 0 {
+		return lastErr
+	}
+	return nil
+}
+
+// countInterfaceQueues counts the rx-<n> and tx-<n> entries of a sysfs queues directory listing
+// Example: "rx-0\nrx-1\ntx-0\ntx-1"
+func countInterfaceQueues(output string) (rx, tx float64) {
+	for _, name := range strings.Fields(output) {
+		switch {
+		case strings.HasPrefix(name, "rx-"):
+			rx++
+		case strings.HasPrefix(name, "tx-"):
+			tx++
+		}
+	}
+	return rx, tx
+}
+
+// queueBytes is the byte counter of a single rx or tx queue
+type queueBytes struct {
+	direction string
+	queue     string
+	bytes     float64
+}
+
+// queueBytesRe matches the per-queue byte counters of ethtool -S, whose names differ by driver
+// virtio_net, ixgbe and ena: "rx_queue_0_bytes", mlx5: "rx0_bytes", i40e and ice: "rx-0.bytes"
+var queueBytesRe = regexp.MustCompile(`^(rx|tx)[_-]?(?:queue_)?(\d+)[_.]bytes:\s*(\d+)$`)
+
+// parseQueueBytes parses the per-queue byte counters of ethtool -S output
+// Example:
+// "NIC statistics:"
+// "     rx_queue_0_packets: 1024"
+// "     rx_queue_0_bytes: 654321"
+// Drivers without per-queue counters, like veth, yield none
+func parseQueueBytes(output string) []queueBytes {
+	var queues []queueBytes
+	for _, line := range strings.Split(output, "\n") {
+		matches := queueBytesRe.FindStringSubmatch(strings.TrimSpace(line))
+		if matches == nil {
+			continue
+		}
+		if bytes, err := strconv.ParseFloat(matches[3], 64); err == nil {
+			queues = append(queues, queueBytes{direction: matches[1], queue: matches[2], bytes: bytes})
+		}
+	}
+	return queues
 }
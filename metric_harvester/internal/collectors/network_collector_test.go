@@ -0,0 +1,210 @@
+package collectors
+
+import (
+	"math"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// newPingTestCollector returns a NetworkCollector with only the ping metrics, for parsePingOutput
+func newPingTestCollector() *NetworkCollector {
+	return &NetworkCollector{
+		deps:           &CollectorDependencies{},
+		pingLatency:    prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "ping_latency"}, []string{"target"}),
+		pingPacketLoss: prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "ping_loss"}, []string{"target"}),
+		pingReachable:  prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "ping_reachable"}, []string{"target"}),
+		pingRTT:        prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: "ping_rtt"}, []string{"target"}),
+	}
+}
+
+// gaugeValue returns the value of the gauge of a GaugeVec for the label values
+func gaugeValue(t *testing.T, vec *prometheus.GaugeVec, labels ...string) float64 {
+	t.Helper()
+	var metric dto.Metric
+	if err := vec.WithLabelValues(labels...).Write(&metric); err != nil {
+		t.Fatalf("writing gauge: %v", err)
+	}
+	return metric.GetGauge().GetValue()
+}
+
+func TestParsePingOutput(t *testing.T) {
+	tests := []struct {
+		name          string
+		output        string
+		wantLatency   float64
+		wantLoss      float64
+		wantReachable float64
+		wantReplies   uint64
+	}{
+		{
+			name: "GNU iputils",
+			output: "PING 8.8.8.8 (8.8.8.8) 56(84) bytes of data.\n" +
+				"64 bytes from 8.8.8.8: icmp_seq=1 ttl=118 time=10.1 ms\n" +
+				"64 bytes from 8.8.8.8: icmp_seq=2 ttl=118 time=12.3 ms\n" +
+				"64 bytes from 8.8.8.8: icmp_seq=3 ttl=118 time=14.5 ms\n" +
+				"\n" +
+				"--- 8.8.8.8 ping statistics ---\n" +
+				"3 packets transmitted, 3 received, 0% packet loss, time 2002ms\n" +
+				"rtt min/avg/max/mdev = 10.100/12.300/14.500/1.796 ms\n",
+			wantLatency:   12.3,
+			wantLoss:      0,
+			wantReachable: 1,
+			wantReplies:   3,
+		},
+		{
+			name: "BusyBox",
+			output: "PING 8.8.8.8 (8.8.8.8): 56 data bytes\n" +
+				"64 bytes from 8.8.8.8: seq=0 ttl=55 time=10.100 ms\n" +
+				"64 bytes from 8.8.8.8: seq=1 ttl=55 time=12.300 ms\n" +
+				"\n" +
+				"--- 8.8.8.8 ping statistics ---\n" +
+				"3 packets transmitted, 2 packets received, 33% packet loss\n" +
+				"round-trip min/avg/max = 10.100/11.200/12.300 ms\n",
+			wantLatency:   11.2,
+			wantLoss:      100.0 / 3,
+			wantReachable: 1,
+			wantReplies:   2,
+		},
+		{
+			name: "GNU iputils unreachable",
+			output: "PING 10.255.255.1 (10.255.255.1) 56(84) bytes of data.\n" +
+				"\n" +
+				"--- 10.255.255.1 ping statistics ---\n" +
+				"3 packets transmitted, 0 received, 100% packet loss, time 2031ms\n",
+			wantLoss:      100,
+			wantReachable: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newPingTestCollector()
+			if err := c.parsePingOutput(tt.output, "google"); err != nil {
+				t.Fatalf("parsePingOutput: %v", err)
+			}
+			if got := gaugeValue(t, c.pingLatency, "google"); got != tt.wantLatency {
+				t.Errorf("latency = %v, want %v", got, tt.wantLatency)
+			}
+			if got := gaugeValue(t, c.pingPacketLoss, "google"); math.Abs(got-tt.wantLoss) > 1e-9 {
+				t.Errorf("packet loss = %v, want %v", got, tt.wantLoss)
+			}
+			if got := gaugeValue(t, c.pingReachable, "google"); got != tt.wantReachable {
+				t.Errorf("reachable = %v, want %v", got, tt.wantReachable)
+			}
+
+			var rtt dto.Metric
+			if err := c.pingRTT.WithLabelValues("google").(prometheus.Histogram).Write(&rtt); err != nil {
+				t.Fatalf("writing histogram: %v", err)
+			}
+			if got := rtt.GetHistogram().GetSampleCount(); got != tt.wantReplies {
+				t.Errorf("rtt observations = %d, want %d", got, tt.wantReplies)
+			}
+		})
+	}
+}
+
+func TestParsePingOutputPrefersSummaryAvg(t *testing.T) {
+	tests := []struct {
+		name        string
+		output      string
+		wantLatency float64
+	}{
+		{
+			// The 14.5 ms reply was lost to buffering, the mean of the printed replies would be 11.2
+			name: "summary avg over the mean of the replies",
+			output: "64 bytes from 8.8.8.8: icmp_seq=1 ttl=118 time=10.1 ms\n" +
+				"64 bytes from 8.8.8.8: icmp_seq=2 ttl=118 time=12.3 ms\n" +
+				"3 packets transmitted, 3 received, 0% packet loss, time 2002ms\n" +
+				"rtt min/avg/max/mdev = 10.100/12.300/14.500/1.796 ms\n",
+			wantLatency: 12.3,
+		},
+		{
+			name: "mean of the replies without a summary",
+			output: "64 bytes from 8.8.8.8: icmp_seq=1 ttl=118 time=10.1 ms\n" +
+				"64 bytes from 8.8.8.8: icmp_seq=2 ttl=118 time=12.3 ms\n",
+			wantLatency: 11.2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newPingTestCollector()
+			if err := c.parsePingOutput(tt.output, "google"); err != nil {
+				t.Fatalf("parsePingOutput: %v", err)
+			}
+			if got := gaugeValue(t, c.pingLatency, "google"); math.Abs(got-tt.wantLatency) > 1e-9 {
+				t.Errorf("latency = %v, want %v", got, tt.wantLatency)
+			}
+		})
+	}
+}
+
+func TestParseRTTSummaryAvg(t *testing.T) {
+	tests := []struct {
+		name   string
+		line   string
+		want   float64
+		wantOK bool
+	}{
+		{name: "GNU iputils", line: "rtt min/avg/max/mdev = 10.100/12.300/14.500/1.796 ms", want: 12.3, wantOK: true},
+		{name: "BusyBox", line: "round-trip min/avg/max = 10.100/11.200/12.300 ms", want: 11.2, wantOK: true},
+		{name: "fping", line: "8.8.8.8 : xmt/rcv/%loss = 3/3/0%, min/avg/max = 10.1/12.3/14.5", want: 12.3, wantOK: true},
+		{name: "not a summary", line: "3 packets transmitted, 3 received, 0% packet loss", wantOK: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseRTTSummaryAvg(tt.line)
+			if ok != tt.wantOK || got != tt.want {
+				t.Errorf("parseRTTSummaryAvg(%q) = %v, %v, want %v, %v", tt.line, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestHasIPv6DefaultRoute(t *testing.T) {
+	const (
+		prefixRoute = "fd000000000000000000000000000000 40 00000000000000000000000000000000 00 00000000000000000000000000000000 00000100 00000001 00000000 00000001     eth0\n"
+		unreachable = "00000000000000000000000000000000 00 00000000000000000000000000000000 00 00000000000000000000000000000000 ffffffff 00000001 00000000 00200200       lo\n"
+		viaGateway  = "00000000000000000000000000000000 00 00000000000000000000000000000000 00 fd000000000000000000000000000001 00000400 00000001 00000000 00000003     eth0\n"
+	)
+
+	tests := []struct {
+		name   string
+		output string
+		want   bool
+	}{
+		{name: "default via gateway", output: prefixRoute + viaGateway + unreachable, want: true},
+		{name: "only the unreachable default on lo", output: prefixRoute + unreachable, want: false},
+		{name: "empty", output: "", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := hasIPv6DefaultRoute(tt.output); got != tt.want {
+				t.Errorf("hasIPv6DefaultRoute() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMarkPingSkipped(t *testing.T) {
+	c := newPingTestCollector()
+	c.pingLatency.WithLabelValues("8.8.8.8").Set(12.5)
+	c.pingPacketLoss.WithLabelValues("8.8.8.8").Set(0)
+	c.pingReachable.WithLabelValues("8.8.8.8").Set(1)
+
+	c.markPingSkipped("8.8.8.8")
+
+	if got := gaugeValue(t, c.pingReachable, "8.8.8.8"); got != 0 {
+		t.Errorf("reachable = %v, want 0", got)
+	}
+	if got := gaugeValue(t, c.pingPacketLoss, "8.8.8.8"); got != 100 {
+		t.Errorf("packet loss = %v, want 100", got)
+	}
+	if c.pingLatency.DeleteLabelValues("8.8.8.8") {
+		t.Error("the latency from before the skip is still reported")
+	}
+}
@@ -0,0 +1,147 @@
+package collectors
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"metric_harvester/internal/config"
+
+	"go.uber.org/zap"
+)
+
+// fpingReplyRe matches an fping reply line
+// Example: "8.8.8.8 : [0], 64 bytes, 12.3 ms (12.3 avg, 0% loss)"
+var fpingReplyRe = regexp.MustCompile(`^(\S+)\s*: \[\d+\], \d+ bytes, ([\d.]+) ms`)
+
+// fpingSummaryRe matches an fping per-host summary line, which has no min/avg/max when nothing was received
+// Example: "8.8.8.8 : xmt/rcv/%loss = 3/3/0%, min/avg/max = 10.1/12.3/14.5"
+var fpingSummaryRe = regexp.MustCompile(`^(\S+)\s*: xmt/rcv/%loss = (\d+)/(\d+)/`)
+
+// fpingResult is what fping reported for a host
+type fpingResult struct {
+	latencies             []float64
+	packetsSent, received int
+	hasSummary            bool
+	summaryAvg            float64
+	hasSummaryAvg         bool
+}
+
+// collectFPingMetrics pings all targets in parallel with one fping and sets the same metrics as ping does
+// Targets fping printed nothing for, e.g. unresolvable names, are marked unreachable
+// When fping fails altogether every target is marked unreachable and the error returned,
+// for collectPingMetrics to fall back to ping when fping isn't installed
+// The command it runs is:
+// - fping -c 3 -t <network.ping_timeout in ms> target...
+func (c *NetworkCollector) collectFPingMetrics(ctx context.Context, targets []config.PingTarget) error {
+	addresses := make([]string, 0, len(targets))
+	seen := make(map[string]bool, len(targets))
+	for _, target := range targets {
+		if !seen[target.Address] {
+			seen[target.Address] = true
+			addresses = append(addresses, target.Address)
+		}
+	}
+
+	output, err := c.deps.Executor.PingHosts(ctx, addresses, 3) // Send 3 pings
+	if err != nil {
+		for _, target := range targets {
+			c.pingReachable.WithLabelValues(target.Name).Set(0)
+		}
+		return err
+	}
+
+	results := c.parseFPingOutput(string(output))
+	for _, target := range targets {
+		result := results[target.Address]
+		if result == nil {
+			c.deps.Logger.Warn("fping reported nothing for target",
+				zap.String("target", target.Name),
+				zap.String("address", target.Address))
+			c.pingReachable.WithLabelValues(target.Name).Set(0)
+			continue
+		}
+		c.setPingMetrics(target.Name, result)
+	}
+	return nil
+}
+
+// parseFPingOutput parses the reply and summary lines of fping -c into the results of each host
+// fping names hosts as they were given, so the results are keyed by target address
+// Lines of other kinds, e.g. "nope.invalid: Name or service not known", are ignored
+func (c *NetworkCollector) parseFPingOutput(output string) map[string]*fpingResult {
+	results := make(map[string]*fpingResult)
+	result := func(host string) *fpingResult {
+		if results[host] == nil {
+			results[host] = &fpingResult{}
+		}
+		return results[host]
+	}
+
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+
+		if matches := fpingReplyRe.FindStringSubmatch(line); matches != nil {
+			if latency, err := strconv.ParseFloat(matches[2], 64); err == nil {
+				result(matches[1]).latencies = append(result(matches[1]).latencies, latency)
+			} else {
+				c.deps.parseFailed(c.Name(), "fping_reply")
+			}
+			continue
+		}
+
+		if strings.Contains(line, "xmt/rcv/%loss") {
+			matches := fpingSummaryRe.FindStringSubmatch(line)
+			if matches == nil {
+				c.deps.parseFailed(c.Name(), "fping_summary")
+				continue
+			}
+			summary := result(matches[1])
+			summary.packetsSent, _ = strconv.Atoi(matches[2])
+			summary.received, _ = strconv.Atoi(matches[3])
+			summary.hasSummary = true
+			// Hosts that didn't reply have no min/avg/max
+			summary.summaryAvg, summary.hasSummaryAvg = parseRTTSummaryAvg(line)
+		}
+	}
+	return results
+}
+
+// setPingMetrics sets the ping metrics of a target from an fping result, as parsePingOutput does for ping
+// The latency is the avg of the summary, falling back to the mean of the reply lines
+func (c *NetworkCollector) setPingMetrics(target string, result *fpingResult) {
+	var totalLatency float64
+	for _, latency := range result.latencies {
+		totalLatency += latency
+		c.pingRTT.WithLabelValues(target).Observe(latency / 1000)
+	}
+
+	if result.hasSummaryAvg || len(result.latencies) > 0 {
+		avgLatency := result.summaryAvg
+		if !result.hasSummaryAvg {
+			avgLatency = totalLatency / float64(len(result.latencies))
+		}
+		c.pingLatency.WithLabelValues(target).Set(avgLatency)
+		c.pingReachable.WithLabelValues(target).Set(1)
+	} else {
+		c.pingReachable.WithLabelValues(target).Set(0)
+	}
+
+	if result.hasSummary && result.packetsSent > 0 {
+		packetLoss := float64(result.packetsSent-result.received) / float64(result.packetsSent) * 100
+		c.pingPacketLoss.WithLabelValues(target).Set(packetLoss)
+	}
+}
+
+// isCommandNotFound reports whether err means the command isn't installed,
+// locally or on an ssh target, where the remote shell exits 127
+func isCommandNotFound(err error) bool {
+	if errors.Is(err, exec.ErrNotFound) {
+		return true
+	}
+	var exitErr *exec.ExitError
+	return errors.As(err, &exitErr) && exitErr.ExitCode() == 127
+}
@@ -0,0 +1,73 @@
+package collectors
+
+import "testing"
+
+func TestParseFPingOutput(t *testing.T) {
+	c := &NetworkCollector{deps: &CollectorDependencies{}}
+
+	tests := []struct {
+		name          string
+		output        string
+		host          string
+		wantReplies   int
+		wantReceived  int
+		wantAvg       float64
+		wantAvgParsed bool
+		wantMissing   []string
+	}{
+		{
+			name: "all replies",
+			output: "8.8.8.8 : [0], 64 bytes, 10.1 ms (10.1 avg, 0% loss)\n" +
+				"8.8.8.8 : [1], 64 bytes, 12.3 ms (11.2 avg, 0% loss)\n" +
+				"8.8.8.8 : [2], 64 bytes, 14.5 ms (12.3 avg, 0% loss)\n" +
+				"8.8.8.8 : xmt/rcv/%loss = 3/3/0%, min/avg/max = 10.1/12.3/14.5\n",
+			host:          "8.8.8.8",
+			wantReplies:   3,
+			wantReceived:  3,
+			wantAvg:       12.3,
+			wantAvgParsed: true,
+		},
+		{
+			name: "unresolvable name next to a replying host",
+			output: "nope.invalid: Name or service not known\n" +
+				"10.0.0.1 : [0], 64 bytes, 1.5 ms (1.5 avg, 0% loss)\n" +
+				"10.0.0.1 : xmt/rcv/%loss = 3/1/66%, min/avg/max = 1.5/1.5/1.5\n",
+			host:          "10.0.0.1",
+			wantReplies:   1,
+			wantReceived:  1,
+			wantAvg:       1.5,
+			wantAvgParsed: true,
+			wantMissing:   []string{"nope.invalid"},
+		},
+		{
+			name:         "no replies",
+			output:       "10.0.0.2 : xmt/rcv/%loss = 3/0/100%\n",
+			host:         "10.0.0.2",
+			wantReceived: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results := c.parseFPingOutput(tt.output)
+			result := results[tt.host]
+			if result == nil {
+				t.Fatalf("no result for %s in %v", tt.host, results)
+			}
+			if len(result.latencies) != tt.wantReplies {
+				t.Errorf("replies = %d, want %d", len(result.latencies), tt.wantReplies)
+			}
+			if !result.hasSummary || result.received != tt.wantReceived || result.packetsSent != 3 {
+				t.Errorf("summary = %+v, want 3 sent and %d received", result, tt.wantReceived)
+			}
+			if result.hasSummaryAvg != tt.wantAvgParsed || result.summaryAvg != tt.wantAvg {
+				t.Errorf("summary avg = %v (parsed %v), want %v (parsed %v)", result.summaryAvg, result.hasSummaryAvg, tt.wantAvg, tt.wantAvgParsed)
+			}
+			for _, host := range tt.wantMissing {
+				if results[host] != nil {
+					t.Errorf("unexpected result for %s", host)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package collectors
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+
+	"metric_harvester/internal/config"
+
+	"go.uber.org/zap"
+)
+
+// httpTiming is the breakdown of a single HTTP request into its connection phases
+// The trace callbacks can run on other goroutines, e.g. when dialing several addresses at once, hence the mutex
+type httpTiming struct {
+	mu                                  sync.Mutex
+	dnsStart, connectStart, tlsStart    time.Time
+	dns, connect, tls, firstByte        time.Duration
+	hasDNS, hasConnect, hasTLS, hasTTFB bool
+}
+
+// trace returns the httptrace hooks that fill in the timing, with start as the time the request was sent
+// The first successful connection wins when several addresses are dialed
+func (t *httpTiming) trace(start time.Time) *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.dnsStart = time.Now()
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.dns, t.hasDNS = time.Since(t.dnsStart), true
+		},
+		ConnectStart: func(_, _ string) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			if t.connectStart.IsZero() {
+				t.connectStart = time.Now()
+			}
+		},
+		ConnectDone: func(_, _ string, err error) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			if err == nil && !t.hasConnect {
+				t.connect, t.hasConnect = time.Since(t.connectStart), true
+			}
+		},
+		TLSHandshakeStart: func() {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.tlsStart = time.Now()
+		},
+		TLSHandshakeDone: func(_ tls.ConnectionState, err error) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			if err == nil {
+				t.tls, t.hasTLS = time.Since(t.tlsStart), true
+			}
+		},
+		GotFirstResponseByte: func() {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.firstByte, t.hasTTFB = time.Since(start), true
+		},
+	}
+}
+
+// newHTTPTimingClient creates the client of the HTTP targets
+// Keep-alives are disabled so every request opens a new connection and the connect and TLS phases are measured
+func newHTTPTimingClient() *http.Client {
+	return &http.Client{
+		Transport: &http.Transport{
+			Proxy:             http.ProxyFromEnvironment,
+			DisableKeepAlives: true,
+		},
+	}
+}
+
+// collectHTTPMetrics times a GET of each of network.http_targets, broken down into DNS lookup, TCP connect,
+// TLS handshake and time to first byte, which localizes where the rootless network stack adds latency.
+// The requests are made by the harvester itself, also for ssh targets.
+// A target that fails is logged and its series are dropped, it doesn't fail the network collection
+func (c *NetworkCollector) collectHTTPMetrics(ctx context.Context) error {
+	for _, target := range c.deps.Config.Network.HTTPTargets {
+		if err := c.collectHTTPMetricsForTarget(ctx, target); err != nil {
+			c.deps.Logger.Warn("Failed to time HTTP target",
+				zap.String("target", target.Name),
+				zap.String("url", target.Address),
+				zap.Error(err))
+			c.deleteHTTPMetrics(target.Name)
+		}
+	}
+	return nil
+}
+
+// collectHTTPMetricsForTarget times a single GET of a target and sets its phase durations
+// Phases that didn't happen, e.g. DNS for an IP address or TLS for plain HTTP, are left out
+func (c *NetworkCollector) collectHTTPMetricsForTarget(ctx context.Context, target config.PingTarget) error {
+	timing := &httpTiming{}
+	start := time.Now()
+
+	request, err := http.NewRequestWithContext(httptrace.WithClientTrace(ctx, timing.trace(start)), http.MethodGet, target.Address, nil)
+	if err != nil {
+		return err
+	}
+	response, err := c.httpClient.Do(request)
+	if err != nil {
+		return err
+	}
+	io.Copy(io.Discard, response.Body)
+	response.Body.Close()
+
+	timing.mu.Lock()
+	defer timing.mu.Unlock()
+
+	c.deleteHTTPMetrics(target.Name)
+	milliseconds := func(d time.Duration) float64 { return float64(d) / float64(time.Millisecond) }
+	if timing.hasDNS {
+		c.httpDNS.WithLabelValues(target.Name).Set(milliseconds(timing.dns))
+	}
+	if timing.hasConnect {
+		c.httpConnect.WithLabelValues(target.Name).Set(milliseconds(timing.connect))
+	}
+	if timing.hasTLS {
+		c.httpTLS.WithLabelValues(target.Name).Set(milliseconds(timing.tls))
+	}
+	if timing.hasTTFB {
+		c.httpFirstByte.WithLabelValues(target.Name).Set(milliseconds(timing.firstByte))
+	}
+	return nil
+}
+
+// deleteHTTPMetrics drops the phase durations of a target, so a failed request doesn't leave the last ones behind
+func (c *NetworkCollector) deleteHTTPMetrics(target string) {
+	c.httpDNS.DeleteLabelValues(target)
+	c.httpConnect.DeleteLabelValues(target)
+	c.httpTLS.DeleteLabelValues(target)
+	c.httpFirstByte.DeleteLabelValues(target)
+}
@@ -0,0 +1,25 @@
+package collectors
+
+import "regexp"
+
+// compilePatterns compiles regular expressions from the configuration once, when a collector is created
+// Invalid patterns are skipped here since config validation already rejects them
+func compilePatterns(patterns []string) []*regexp.Regexp {
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		if re, err := regexp.Compile(pattern); err == nil {
+			compiled = append(compiled, re)
+		}
+	}
+	return compiled
+}
+
+// matchesAny checks if a name matches any of the patterns
+func matchesAny(patterns []*regexp.Regexp, name string) bool {
+	for _, re := range patterns {
+		if re.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
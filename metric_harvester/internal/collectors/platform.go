@@ -0,0 +1,71 @@
+package collectors
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"runtime"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// isLinux reports whether the harvester runs on Linux, where all collection paths are supported
+var isLinux = runtime.GOOS == "linux"
+
+// isMacOS reports whether the harvester runs on macOS, where system metrics have a native path
+var isMacOS = runtime.GOOS == "darwin"
+
+// procfsProbe is the file read to tell whether the Linux procfs is mounted
+const procfsProbe = "/proc/self/stat"
+
+// hostFileCheck caches whether a file of the collected host can be read, e.g. procfsProbe for the Linux procfs
+// The local host is checked in-process and an ssh target with cat through the executor, since the harvester's
+// filesystem says nothing about the target. The answer is kept as such files don't come and go while the harvester
+// runs, unless ssh itself failed (exit status 255), so an unreachable target is checked again next time
+type hostFileCheck struct {
+	mu       sync.Mutex
+	checked  bool
+	readable bool
+}
+
+// check reports whether path can be read on the host collected with deps
+// The command it runs on an ssh target is:
+// - cat path
+func (h *hostFileCheck) check(ctx context.Context, deps *CollectorDependencies, path string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.checked {
+		return h.readable
+	}
+
+	_, err := deps.readProcFile(ctx, path)
+	var exitErr *exec.ExitError
+	if err != nil && deps.remote() && (!errors.As(err, &exitErr) || exitErr.ExitCode() == 255) {
+		// The target didn't answer, collect as usual and let that fail instead
+		return true
+	}
+	h.checked, h.readable = true, err == nil
+	return h.readable
+}
+
+// unsupportedWarning disables a collection path the platform doesn't support
+// It warns only the first time so unsupported paths don't log an error every cycle, e.g. when
+// developing on macOS
+type unsupportedWarning struct {
+	once sync.Once
+}
+
+// check returns supported, logging a single warning the first time it is false
+func (w *unsupportedWarning) check(supported bool, logger *zap.Logger, collection string) bool {
+	if !supported {
+		w.once.Do(func() {
+			logger.Warn("Collection is not supported on this platform, skipping it",
+				zap.String("collection", collection),
+				zap.String("os", runtime.GOOS),
+			)
+		})
+	}
+	return supported
+}
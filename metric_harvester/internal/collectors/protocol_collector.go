@@ -0,0 +1,217 @@
+package collectors
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ProtocolCollector collects TCP and UDP protocol statistics like retransmits and segment counts
+type ProtocolCollector struct {
+	deps *CollectorDependencies
+
+	// Prometheus metrics for TCP
+	// tcpRetransmits: retransmitted segments. A strong signal of user-space networking overhead.
+	tcpRetransmits  prometheus.Gauge
+	tcpInSegments   prometheus.Gauge
+	tcpOutSegments  prometheus.Gauge
+	tcpInErrors     prometheus.Gauge
+	tcpCurrEstab    prometheus.Gauge
+	tcpActiveOpens  prometheus.Gauge
+	tcpPassiveOpens prometheus.Gauge
+
+	// Prometheus metrics for UDP
+	udpInDatagrams  prometheus.Gauge
+	udpOutDatagrams prometheus.Gauge
+	udpInErrors     prometheus.Gauge
+	udpRcvbufErrors prometheus.Gauge
+	udpSndbufErrors prometheus.Gauge
+
+	// procfs checks whether the collected host has /proc, procUnsupported warns once when it hasn't, e.g. on macOS
+	procfs          hostFileCheck
+	procUnsupported unsupportedWarning
+}
+
+// NewProtocolCollector creates a new ProtocolCollector
+// Args:
+// - deps: CollectorDependencies
+// Returns:
+// - *ProtocolCollector: new ProtocolCollector instance
+func NewProtocolCollector(deps *CollectorDependencies) *ProtocolCollector {
+	return &ProtocolCollector{
+		deps: deps,
+		tcpRetransmits: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "network_tcp_retransmits_total",
+			Help: "Total TCP segments retransmitted",
+		}),
+		tcpInSegments: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "network_tcp_segments_received_total",
+			Help: "Total TCP segments received",
+		}),
+		tcpOutSegments: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "network_tcp_segments_sent_total",
+			Help: "Total TCP segments sent",
+		}),
+		tcpInErrors: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "network_tcp_receive_errors_total",
+			Help: "Total TCP segments received in error",
+		}),
+		tcpCurrEstab: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "network_tcp_connections_established",
+			Help: "Number of TCP connections currently established",
+		}),
+		tcpActiveOpens: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "network_tcp_active_opens_total",
+			Help: "Total TCP connections opened actively",
+		}),
+		tcpPassiveOpens: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "network_tcp_passive_opens_total",
+			Help: "Total TCP connections opened passively",
+		}),
+		udpInDatagrams: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "network_udp_datagrams_received_total",
+			Help: "Total UDP datagrams received",
+		}),
+		udpOutDatagrams: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "network_udp_datagrams_sent_total",
+			Help: "Total UDP datagrams sent",
+		}),
+		udpInErrors: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "network_udp_receive_errors_total",
+			Help: "Total UDP datagrams received in error",
+		}),
+		udpRcvbufErrors: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "network_udp_receive_buffer_errors_total",
+			Help: "Total UDP datagrams dropped because the receive buffer was full",
+		}),
+		udpSndbufErrors: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "network_udp_send_buffer_errors_total",
+			Help: "Total UDP datagrams dropped because the send buffer was full",
+		}),
+	}
+}
+
+func (c *ProtocolCollector) Name() string {
+	return "protocol"
+}
+
+func (c *ProtocolCollector) RequiredCommands() []string {
+	return []string{"cat"}
+}
+
+func (c *ProtocolCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, gauge := range c.gauges() {
+		gauge.Describe(ch)
+	}
+}
+
+func (c *ProtocolCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, gauge := range c.gauges() {
+		gauge.Collect(ch)
+	}
+}
+
+// gauges returns all the gauges of the collector
+func (c *ProtocolCollector) gauges() []prometheus.Gauge {
+	return []prometheus.Gauge{
+		c.tcpRetransmits,
+		c.tcpInSegments,
+		c.tcpOutSegments,
+		c.tcpInErrors,
+		c.tcpCurrEstab,
+		c.tcpActiveOpens,
+		c.tcpPassiveOpens,
+		c.udpInDatagrams,
+		c.udpOutDatagrams,
+		c.udpInErrors,
+		c.udpRcvbufErrors,
+		c.udpSndbufErrors,
+	}
+}
+
+// CollectMetrics collects protocol metrics
+// This is the main function that collects all the protocol metrics
+// The command it runs is:
+// - cat /proc/net/snmp
+func (c *ProtocolCollector) CollectMetrics(ctx context.Context) error {
+	c.deps.Logger.Debug("Collecting protocol metrics")
+
+	if !c.procUnsupported.check(c.procfs.check(ctx, c.deps, procfsProbe), c.deps.Logger, c.Name()) {
+		return nil
+	}
+
+	// /proc/net/snmp is read directly to avoid depending on netstat being installed
+	output, err := c.deps.Executor.Execute(ctx, "cat", "/proc/net/snmp")
+	if err != nil {
+		return err
+	}
+
+	stats := parseSNMPStats(string(output))
+
+	tcp := stats["Tcp"]
+	setFromStats(c.tcpRetransmits, tcp, "RetransSegs")
+	setFromStats(c.tcpInSegments, tcp, "InSegs")
+	setFromStats(c.tcpOutSegments, tcp, "OutSegs")
+	setFromStats(c.tcpInErrors, tcp, "InErrs")
+	setFromStats(c.tcpCurrEstab, tcp, "CurrEstab")
+	setFromStats(c.tcpActiveOpens, tcp, "ActiveOpens")
+	setFromStats(c.tcpPassiveOpens, tcp, "PassiveOpens")
+
+	udp := stats["Udp"]
+	setFromStats(c.udpInDatagrams, udp, "InDatagrams")
+	setFromStats(c.udpOutDatagrams, udp, "OutDatagrams")
+	setFromStats(c.udpInErrors, udp, "InErrors")
+	setFromStats(c.udpRcvbufErrors, udp, "RcvbufErrors")
+	setFromStats(c.udpSndbufErrors, udp, "SndbufErrors")
+
+	return nil
+}
+
+// parseSNMPStats parses /proc/net/snmp into protocol -> field -> value
+// Each protocol is described by a header line followed by a value line with the same prefix
+// Example:
+// "Tcp: RtoAlgorithm RtoMin RtoMax MaxConn ActiveOpens PassiveOpens ..."
+// "Tcp: 1 200 120000 -1 1234 567 ..."
+func parseSNMPStats(output string) map[string]map[string]float64 {
+	stats := make(map[string]map[string]float64)
+	headers := make(map[string][]string)
+
+	for _, line := range strings.Split(output, "\n") {
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+
+		protocol := strings.TrimSpace(parts[0])
+		fields := strings.Fields(parts[1])
+
+		// The first line for a protocol holds the field names, the second the values
+		names, ok := headers[protocol]
+		if !ok {
+			headers[protocol] = fields
+			continue
+		}
+
+		values := make(map[string]float64)
+		for i, field := range fields {
+			if i >= len(names) {
+				break
+			}
+			if value, err := strconv.ParseFloat(field, 64); err == nil {
+				values[names[i]] = value
+			}
+		}
+		stats[protocol] = values
+	}
+
+	return stats
+}
+
+// setFromStats sets the gauge to the named field if it is present
+func setFromStats(gauge prometheus.Gauge, stats map[string]float64, field string) {
+	if value, ok := stats[field]; ok {
+		gauge.Set(value)
+	}
+}
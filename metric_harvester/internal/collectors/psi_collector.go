@@ -0,0 +1,142 @@
+package collectors
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+// psiResources are the resources Linux reports pressure stall information for
+var psiResources = []string{"cpu", "memory", "io"}
+
+// psiProbe is the file read to tell whether the kernel exposes pressure stall information
+// /proc/pressure is missing before Linux 4.20, and reading it fails when the kernel is booted with psi=0
+const psiProbe = "/proc/pressure/cpu"
+
+// PSICollector collects Linux pressure stall information (PSI)
+// PSI is the time tasks were stalled waiting for cpu, memory or io, a direct measure of resource contention
+// that makes the extra work of rootless networking and storage visible
+type PSICollector struct {
+	deps *CollectorDependencies
+
+	// Prometheus metrics
+	// pressureSome: time at least one task was stalled on the resource
+	// pressureFull: time all non-idle tasks were stalled on the resource at once
+	pressureSome *prometheus.GaugeVec
+	pressureFull *prometheus.GaugeVec
+
+	// psi checks whether the collected host's kernel exposes PSI, psiUnsupported warns once when it doesn't
+	psi            hostFileCheck
+	psiUnsupported unsupportedWarning
+}
+
+// NewPSICollector creates a new PSICollector
+// Args:
+// - deps: CollectorDependencies
+// Returns:
+// - *PSICollector: new PSICollector instance
+func NewPSICollector(deps *CollectorDependencies) *PSICollector {
+	return &PSICollector{
+		deps: deps,
+		pressureSome: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "system_pressure_some_seconds_total",
+				Help: "Total time in seconds at least one task was stalled on the resource",
+			},
+			[]string{"resource"}, // cpu, memory, io
+		),
+		pressureFull: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "system_pressure_full_seconds_total",
+				Help: "Total time in seconds all non-idle tasks were stalled on the resource at once",
+			},
+			[]string{"resource"}, // cpu, memory, io
+		),
+	}
+}
+
+func (c *PSICollector) Name() string {
+	return "psi"
+}
+
+func (c *PSICollector) RequiredCommands() []string {
+	return []string{"cat"}
+}
+
+func (c *PSICollector) Describe(ch chan<- *prometheus.Desc) {
+	c.pressureSome.Describe(ch)
+	c.pressureFull.Describe(ch)
+}
+
+func (c *PSICollector) Collect(ch chan<- prometheus.Metric) {
+	c.pressureSome.Collect(ch)
+	c.pressureFull.Collect(ch)
+}
+
+// CollectMetrics collects the pressure stall totals of each resource
+// The commands it runs are:
+// - cat /proc/pressure/cpu
+// - cat /proc/pressure/memory
+// - cat /proc/pressure/io
+func (c *PSICollector) CollectMetrics(ctx context.Context) error {
+	c.deps.Logger.Debug("Collecting pressure stall metrics")
+
+	if !c.psiUnsupported.check(c.psi.check(ctx, c.deps, psiProbe), c.deps.Logger, c.Name()) {
+		return nil
+	}
+
+	result := NewCollectionError(c.Name())
+	for _, resource := range psiResources {
+		output, err := c.deps.Executor.Execute(ctx, "cat", "/proc/pressure/"+resource)
+		if result.Record(resource, err) != nil {
+			c.deps.Logger.Error("Failed to collect pressure stall metrics",
+				zap.String("resource", resource),
+				zap.Error(err))
+			continue
+		}
+
+		totals := parsePressure(string(output))
+		if some, ok := totals["some"]; ok {
+			c.pressureSome.WithLabelValues(resource).Set(some)
+		}
+		// The system-wide cpu full line is only reported since Linux 5.13
+		if full, ok := totals["full"]; ok {
+			c.pressureFull.WithLabelValues(resource).Set(full)
+		}
+	}
+
+	return result.ErrOrNil()
+}
+
+// parsePressure parses a /proc/pressure file into the stall total in seconds of each line
+// The avg10/avg60/avg300 fields are the percentage of time stalled over those windows,
+// they are left out since rate() over the total gives the same over any window
+// Example:
+// "some avg10=4.40 avg60=3.59 avg300=3.64 total=67791922"
+// "full avg10=0.00 avg60=0.00 avg300=0.00 total=0"
+// The total is in microseconds
+func parsePressure(output string) map[string]float64 {
+	totals := make(map[string]float64)
+
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		for _, field := range fields[1:] {
+			value, ok := strings.CutPrefix(field, "total=")
+			if !ok {
+				continue
+			}
+			if total, err := strconv.ParseFloat(value, 64); err == nil {
+				totals[fields[0]] = total / 1e6
+			}
+		}
+	}
+
+	return totals
+}
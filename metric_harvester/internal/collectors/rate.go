@@ -0,0 +1,88 @@
+package collectors
+
+import (
+	"sync"
+	"time"
+)
+
+// rateSample is a cumulative counter value observed at a point in time
+type rateSample struct {
+	value float64
+	at    time.Time
+}
+
+// rateTracker computes per-second rates of cumulative counters over the last N collection cycles
+// Keeping a small ring buffer of snapshots per series turns the rate into a moving average,
+// which is much less noisy than the delta of a single short interval
+// It is safe for concurrent use
+type rateTracker struct {
+	mu      sync.Mutex
+	window  int
+	samples map[string][]rateSample
+	// observed are the series observed since the last Prune
+	observed map[string]bool
+}
+
+// newRateTracker creates a new rateTracker
+// Args:
+// - window: number of cycles the rate is computed over, at least 1
+// Returns:
+// - *rateTracker: new rateTracker instance
+func newRateTracker(window int) *rateTracker {
+	if window < 1 {
+		window = 1
+	}
+	return &rateTracker{
+		window:   window,
+		samples:  make(map[string][]rateSample),
+		observed: make(map[string]bool),
+	}
+}
+
+// Observe records the current value of a counter and returns its rate per second over the window
+// The second return value is false until at least two samples of the series have been observed
+// A counter that went backwards (e.g. a restarted container) starts over
+func (r *rateTracker) Observe(key string, value float64, at time.Time) (float64, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	samples := r.samples[key]
+	if len(samples) > 0 && value < samples[len(samples)-1].value {
+		samples = nil
+	}
+
+	samples = append(samples, rateSample{value: value, at: at})
+	// A window of N intervals needs N+1 snapshots
+	if len(samples) > r.window+1 {
+		samples = samples[len(samples)-(r.window+1):]
+	}
+	r.samples[key] = samples
+	r.observed[key] = true
+
+	if len(samples) < 2 {
+		return 0, false
+	}
+
+	oldest, newest := samples[0], samples[len(samples)-1]
+	elapsed := newest.at.Sub(oldest.at).Seconds()
+	if elapsed <= 0 {
+		return 0, false
+	}
+
+	return (newest.value - oldest.value) / elapsed, true
+}
+
+// Prune forgets the series that weren't observed since the last Prune, e.g. of containers and interfaces that are
+// gone, so the tracker doesn't grow on hosts where they come and go. Collectors call it once per cycle
+// keep, when not nil, retains unobserved series it returns true for, e.g. of containers only skipped this cycle
+func (r *rateTracker) Prune(keep func(key string) bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for key := range r.samples {
+		if !r.observed[key] && (keep == nil || !keep(key)) {
+			delete(r.samples, key)
+		}
+	}
+	r.observed = make(map[string]bool, len(r.samples))
+}
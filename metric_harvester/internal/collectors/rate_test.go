@@ -0,0 +1,36 @@
+package collectors
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRateTrackerPrune(t *testing.T) {
+	start := time.Unix(1000, 0)
+	r := newRateTracker(2)
+	r.Observe("eth0/rx", 100, start)
+	r.Observe("veth1/rx", 100, start)
+	r.Observe("web/docker/rx", 100, start)
+	r.Prune(nil)
+
+	// veth1 is gone and web is only outside this cycle's batch
+	r.Observe("eth0/rx", 200, start.Add(10*time.Second))
+	r.Prune(func(key string) bool { return strings.HasPrefix(key, "web/") })
+
+	if _, ok := r.samples["veth1/rx"]; ok {
+		t.Error("series not observed in the cycle was kept")
+	}
+	if _, ok := r.samples["web/docker/rx"]; !ok {
+		t.Error("series kept by keep was pruned")
+	}
+	if rate, ok := r.Observe("eth0/rx", 300, start.Add(20*time.Second)); !ok || rate != 10 {
+		t.Errorf("eth0 rate = %v, %v, want 10 over its kept samples", rate, ok)
+	}
+
+	// Without keep, the series unobserved since the last Prune goes
+	r.Prune(nil)
+	if _, ok := r.samples["web/docker/rx"]; ok {
+		t.Error("series not observed for two cycles was kept without keep")
+	}
+}
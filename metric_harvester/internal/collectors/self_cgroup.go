@@ -0,0 +1,88 @@
+package collectors
+
+import (
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+// cgroupV1Unlimited is the lowest value a cgroup v1 memory.limit_in_bytes without a limit reads as,
+// the page-aligned maximum int64
+const cgroupV1Unlimited = 1 << 62
+
+// SelfCgroupMemory exposes the memory usage and limit of the harvester's own memory cgroup, which is its container's
+// when it runs in one, so its footprint can be subtracted from the comparison of rootful and rootless runs
+// It reads the local /proc/self/cgroup rather than going through the executor, the harvester always runs locally.
+// Outside any memory cgroup, e.g. on macOS, it reports nothing
+type SelfCgroupMemory struct {
+	logger *zap.Logger
+	desc   *prometheus.Desc
+}
+
+// NewSelfCgroupMemory creates harvester_self_cgroup_memory_bytes
+// Args:
+// - logger: logger for cgroup files that can't be read
+// Returns:
+// - *SelfCgroupMemory: new SelfCgroupMemory instance
+func NewSelfCgroupMemory(logger *zap.Logger) *SelfCgroupMemory {
+	return &SelfCgroupMemory{
+		logger: logger,
+		desc: prometheus.NewDesc(
+			"harvester_self_cgroup_memory_bytes",
+			"Memory usage (current) and limit (max) of the harvester's own memory cgroup in bytes",
+			[]string{"type"}, nil,
+		),
+	}
+}
+
+// Describe implements the prometheus.Collector interface
+func (s *SelfCgroupMemory) Describe(ch chan<- *prometheus.Desc) {
+	ch <- s.desc
+}
+
+// Collect implements the prometheus.Collector interface, reading the cgroup whenever the metrics are gathered
+// The limit is left out when there is none
+// The files it reads are:
+// - /proc/self/cgroup
+// - /sys/fs/cgroup/<path>/memory.current and memory.max (cgroup v2)
+// - /sys/fs/cgroup/memory/<path>/memory.usage_in_bytes and memory.limit_in_bytes (cgroup v1)
+func (s *SelfCgroupMemory) Collect(ch chan<- prometheus.Metric) {
+	output, err := os.ReadFile("/proc/self/cgroup")
+	if err != nil {
+		return
+	}
+	path, v2, ok := parseCgroupPath(string(output), "memory")
+	if !ok {
+		return
+	}
+
+	dir, currentFile, maxFile := "/sys/fs/cgroup"+path+"/", "memory.current", "memory.max"
+	if !v2 {
+		dir, currentFile, maxFile = "/sys/fs/cgroup/memory"+path+"/", "memory.usage_in_bytes", "memory.limit_in_bytes"
+	}
+
+	// The root cgroup, i.e. not in a container without a cgroup namespace, has no memory.current
+	current, err := readCgroupValue(dir + currentFile)
+	if err != nil {
+		s.logger.Debug("Failed to read the harvester's own cgroup memory usage", zap.Error(err))
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(s.desc, prometheus.GaugeValue, current, "current")
+
+	// "max" on v2 doesn't parse, leaving the limit out
+	if limit, err := readCgroupValue(dir + maxFile); err == nil && limit < cgroupV1Unlimited {
+		ch <- prometheus.MustNewConstMetric(s.desc, prometheus.GaugeValue, limit, "max")
+	}
+}
+
+// readCgroupValue reads a cgroup file holding a single number
+func readCgroupValue(file string) (float64, error) {
+	output, err := os.ReadFile(file)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseFloat(strings.TrimSpace(string(output)), 64)
+}
@@ -2,6 +2,7 @@ package collectors
 
 import (
 	"context"
+	"fmt"
 	"regexp"
 	"strconv"
 	"strings"
@@ -18,11 +19,34 @@ type SystemCollector struct {
 	// cpuUsage: system CPU usage percentage
 	// memoryUsage: system memory usage in bytes
 	// diskUsage: system disk usage in bytes
+	// diskUsagePercent: used share of the disk's total size
 	// systemUptime: system uptime in seconds. Can be used to calculate system age in days.
-	cpuUsage     *prometheus.GaugeVec
-	memoryUsage  *prometheus.GaugeVec
-	diskUsage    *prometheus.GaugeVec
-	systemUptime prometheus.Gauge
+	cpuUsage         *prometheus.GaugeVec
+	memoryUsage      *prometheus.GaugeVec
+	diskUsage        *prometheus.GaugeVec
+	diskUsagePercent *prometheus.GaugeVec
+	systemUptime     prometheus.Gauge
+
+	// cpuFrequency: current frequency of each core in hertz, collected only when enable_cpu_frequency is set
+	cpuFrequency *prometheus.GaugeVec
+
+	// entropyAvailable: bits in the kernel random pool, collected only when enable_entropy is set
+	entropyAvailable prometheus.Gauge
+
+	// processesTotal and threadsTotal: processes and threads of the system, collected only on Linux
+	processesTotal prometheus.Gauge
+	threadsTotal   prometheus.Gauge
+
+	// cpuSource is the CPU fallback chain source used in the last cycle
+	// procStatPrevious and selfPrevious are the previous samples rates of the /proc fallbacks are computed from
+	cpuSource        string
+	procStatPrevious []float64
+	selfPrevious     rateSample
+
+	// Warnings for platforms with neither the Linux (top -bn1, free) nor the macOS (top -l 1, vm_stat) commands
+	cpuUnsupported       unsupportedWarning
+	memoryUnsupported    unsupportedWarning
+	processesUnsupported unsupportedWarning
 }
 
 // NewSystemCollector creates a new SystemCollector
@@ -54,12 +78,44 @@ func NewSystemCollector(deps *CollectorDependencies) *SystemCollector {
 			},
 			[]string{"device", "type"}, // used, available, total
 		),
+		diskUsagePercent: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "system_disk_usage_percent",
+				Help: "System disk usage as a percentage of the total size",
+			},
+			[]string{"device", "mount"},
+		),
 		systemUptime: prometheus.NewGauge(
 			prometheus.GaugeOpts{
 				Name: "system_uptime_seconds",
 				Help: "System uptime in seconds",
 			},
 		),
+		cpuFrequency: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "system_cpu_frequency_hertz",
+				Help: "Current CPU core frequency in hertz",
+			},
+			[]string{"core"},
+		),
+		entropyAvailable: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "system_entropy_available_bits",
+				Help: "Entropy available in the kernel random pool in bits",
+			},
+		),
+		processesTotal: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "system_processes_total",
+				Help: "Number of processes in the PID namespace the commands run in, e.g. only a container's own when run in one",
+			},
+		),
+		threadsTotal: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "system_threads_total",
+				Help: "Number of threads of the whole kernel from /proc/loadavg, or of the PID namespace like system_processes_total with metrics.enable_thread_walk",
+			},
+		),
 	}
 }
 
@@ -67,6 +123,46 @@ func (c *SystemCollector) Name() string {
 	return "system"
 }
 
+func (c *SystemCollector) RequiredCommands() []string {
+	enabled := c.deps.Config.Metrics.System
+	var commands []string
+	if enabled.CPU {
+		// top is optional on Linux, CPU falls back to reading /proc/stat, which only needs cat on an ssh target
+		if isMacOS {
+			commands = append(commands, "top")
+		} else if c.deps.remote() {
+			commands = append(commands, "cat")
+		}
+	}
+	if enabled.Memory {
+		if isMacOS {
+			commands = append(commands, "vm_stat", "sysctl")
+		} else {
+			commands = append(commands, "free")
+		}
+	}
+	if enabled.Disk {
+		commands = append(commands, "df")
+	}
+	if enabled.Uptime {
+		commands = append(commands, "uptime")
+	}
+	if enabled.Processes && isLinux {
+		if c.deps.Config.Metrics.EnableThreadWalk {
+			commands = append(commands, "ps")
+		} else {
+			commands = append(commands, "cat", "ls")
+		}
+	}
+	if c.deps.Config.Metrics.EnableCPUFrequency {
+		commands = append(commands, "grep")
+	}
+	if c.deps.Config.Metrics.EnableEntropy && isLinux {
+		commands = append(commands, "cat")
+	}
+	return commands
+}
+
 // Following are methods that need to be implemented for the Prometheus server to know which metrics are being collected
 // which are implicitly implemented by the prometheus.systemCollector interface
 
@@ -76,7 +172,12 @@ func (c *SystemCollector) Describe(ch chan<- *prometheus.Desc) {
 	c.cpuUsage.Describe(ch)
 	c.memoryUsage.Describe(ch)
 	c.diskUsage.Describe(ch)
+	c.diskUsagePercent.Describe(ch)
 	c.systemUptime.Describe(ch)
+	c.cpuFrequency.Describe(ch)
+	c.entropyAvailable.Describe(ch)
+	c.processesTotal.Describe(ch)
+	c.threadsTotal.Describe(ch)
 }
 
 // Collect implements the prometheus.systemCollector interface
@@ -85,119 +186,343 @@ func (c *SystemCollector) Collect(ch chan<- prometheus.Metric) {
 	c.cpuUsage.Collect(ch)
 	c.memoryUsage.Collect(ch)
 	c.diskUsage.Collect(ch)
+	c.diskUsagePercent.Collect(ch)
 	c.systemUptime.Collect(ch)
+	c.cpuFrequency.Collect(ch)
+	if c.deps.Config.Metrics.EnableEntropy && isLinux {
+		c.entropyAvailable.Collect(ch)
+	}
+	if c.deps.Config.Metrics.System.Processes && isLinux {
+		c.processesTotal.Collect(ch)
+		c.threadsTotal.Collect(ch)
+	}
 }
 
 // CollectMetrics collects system metrics
 // This is the main function that collects all the system metrics
+// It returns a *CollectionError describing which sub-collections failed, if any
 func (c *SystemCollector) CollectMetrics(ctx context.Context) error {
 	c.deps.Logger.Debug("Collecting system metrics")
 
-	// Collect CPU metrics
-	if err := c.collectCPUMetrics(ctx); err != nil {
-		c.deps.Logger.Error("Failed to collect CPU metrics", zap.Error(err))
+	result := NewCollectionError(c.Name())
+
+	enabled := c.deps.Config.Metrics.System
+
+	// Collect CPU metrics if enabled
+	if enabled.CPU {
+		if err := result.Record("cpu", c.collectCPUMetrics(ctx)); err != nil {
+			c.deps.Logger.Error("Failed to collect CPU metrics", zap.Error(err))
+		}
+	}
+
+	// Collect memory metrics if enabled
+	if enabled.Memory {
+		if err := result.Record("memory", c.collectMemoryMetrics(ctx)); err != nil {
+			c.deps.Logger.Error("Failed to collect memory metrics", zap.Error(err))
+		}
+	}
+
+	// Collect disk metrics if enabled
+	if enabled.Disk {
+		if err := result.Record("disk", c.collectDiskMetrics(ctx)); err != nil {
+			c.deps.Logger.Error("Failed to collect disk metrics", zap.Error(err))
+		}
 	}
 
-	// Collect memory metrics
-	if err := c.collectMemoryMetrics(ctx); err != nil {
-		c.deps.Logger.Error("Failed to collect memory metrics", zap.Error(err))
+	// Collect uptime if enabled
+	if enabled.Uptime {
+		if err := result.Record("uptime", c.collectUptimeMetrics(ctx)); err != nil {
+			c.deps.Logger.Error("Failed to collect uptime metrics", zap.Error(err))
+		}
 	}
 
-	// Collect disk metrics
-	if err := c.collectDiskMetrics(ctx); err != nil {
-		c.deps.Logger.Error("Failed to collect disk metrics", zap.Error(err))
+	// Collect process and thread counts if enabled
+	if enabled.Processes && c.processesUnsupported.check(isLinux, c.deps.Logger, "processes") {
+		if err := result.Record("processes", c.collectProcessMetrics(ctx)); err != nil {
+			c.deps.Logger.Error("Failed to collect process metrics", zap.Error(err))
+		}
 	}
 
-	// Collect uptime
-	if err := c.collectUptimeMetrics(ctx); err != nil {
-		c.deps.Logger.Error("Failed to collect uptime metrics", zap.Error(err))
+	// Collect CPU frequency if enabled
+	if c.deps.Config.Metrics.EnableCPUFrequency {
+		if err := result.Record("cpu_frequency", c.collectCPUFrequencyMetrics(ctx)); err != nil {
+			c.deps.Logger.Error("Failed to collect CPU frequency metrics", zap.Error(err))
+		}
 	}
 
-	return nil
+	// Collect available entropy if enabled, /proc/sys/kernel/random only exists on Linux
+	if c.deps.Config.Metrics.EnableEntropy && isLinux {
+		if err := result.Record("entropy", c.collectEntropyMetrics(ctx)); err != nil {
+			c.deps.Logger.Error("Failed to collect entropy metrics", zap.Error(err))
+		}
+	}
+
+	return result.ErrOrNil()
 }
 
 // collectCPUMetrics collects CPU metrics
 // This is the main function that collects all the CPU metrics
+// On Linux it goes through a fallback chain, see collectLinuxCPUMetrics
 // The commands it runs are:
-// - top -l 1 -n 0
+// - top -bn1, cat /proc/stat or cat /proc/<pid>/stat on Linux
+// - top -l 1 -n 0 on macOS
 func (c *SystemCollector) collectCPUMetrics(ctx context.Context) error {
-	output, err := c.deps.Executor.GetCPUUsage(ctx)
+	if isMacOS {
+		return c.collectMacCPUMetrics(ctx)
+	}
+	if !c.cpuUnsupported.check(isLinux, c.deps.Logger, "cpu") {
+		return nil
+	}
+
+	return c.collectLinuxCPUMetrics(ctx)
+}
+
+// collectCPUFrequencyMetrics collects the current frequency of each core
+// Frequency scaling skews throughput comparisons, so this explains variance across repeated runs
+// The command it runs is:
+// - grep -H . /sys/devices/system/cpu/cpu*/cpufreq/scaling_cur_freq
+func (c *SystemCollector) collectCPUFrequencyMetrics(ctx context.Context) error {
+	output, err := c.deps.Executor.GetCPUFrequencies(ctx)
 	if err != nil {
 		return err
 	}
 
-	// Parse top -bn1 output for Linux
-	lines := strings.Split(string(output), "\n")
-	for _, line := range lines {
-		if strings.Contains(line, "%Cpu(s):") {
-			// Linux format: "%Cpu(s):  3.2 us,  1.1 sy,  0.0 ni, 95.6 id,  0.0 wa,  0.0 hi,  0.1 si,  0.0 st"
-			re := regexp.MustCompile(`(\d+\.?\d*)\s+(\w+)`)
-			matches := re.FindAllStringSubmatch(line, -1)
-
-			for _, match := range matches {
-				if len(match) == 3 {
-					value, err := strconv.ParseFloat(match[1], 64)
-					if err == nil {
-						switch match[2] {
-						case "us":
-							c.cpuUsage.WithLabelValues("user").Set(value)
-						case "sy":
-							c.cpuUsage.WithLabelValues("system").Set(value)
-						case "id":
-							c.cpuUsage.WithLabelValues("idle").Set(value)
-						}
-					}
-				}
-			}
-			break
+	frequencies := parseCPUFrequencies(string(output))
+	if len(frequencies) == 0 {
+		c.deps.Logger.Debug("No cores with cpufreq, skipping CPU frequency")
+		return nil
+	}
+
+	for core, hertz := range frequencies {
+		c.cpuFrequency.WithLabelValues(core).Set(hertz)
+	}
+	return nil
+}
+
+// collectEntropyMetrics collects the entropy available in the kernel random pool
+// TLS-heavy api_caller endpoints draw on it, so pressure on the pool can show up as latency differences
+// The command it runs is:
+// - cat /proc/sys/kernel/random/entropy_avail
+func (c *SystemCollector) collectEntropyMetrics(ctx context.Context) error {
+	output, err := c.deps.Executor.Execute(ctx, "cat", "/proc/sys/kernel/random/entropy_avail")
+	if err != nil {
+		return err
+	}
+
+	bits, err := strconv.ParseFloat(strings.TrimSpace(string(output)), 64)
+	if err != nil {
+		return fmt.Errorf("failed to parse entropy_avail: %w", err)
+	}
+
+	c.entropyAvailable.Set(bits)
+	return nil
+}
+
+// collectProcessMetrics collects the number of processes and threads
+// Rootless containers run into PID limits differently, which shows up here before forks start failing.
+// By default the threads are the kernel's scheduling entities from /proc/loadavg and the processes the PID directories
+// of /proc. With metrics.enable_thread_walk both come from ps, which reads every process's thread count; in a PID
+// namespace, e.g. a container, it counts the namespace's threads only, while /proc/loadavg counts the whole kernel's
+// The commands it runs are:
+// - cat /proc/loadavg and ls /proc
+// - ps -e -o nlwp= with metrics.enable_thread_walk
+func (c *SystemCollector) collectProcessMetrics(ctx context.Context) error {
+	if c.deps.Config.Metrics.EnableThreadWalk {
+		output, err := c.deps.Executor.Execute(ctx, "ps", "-e", "-o", "nlwp=")
+		if err != nil {
+			return err
+		}
+		processes, threads, ok := parseThreadCounts(string(output))
+		if !ok {
+			c.deps.parseFailed(c.Name(), "ps_threads")
+			return fmt.Errorf("failed to parse thread counts from ps")
 		}
+		c.processesTotal.Set(processes)
+		c.threadsTotal.Set(threads)
+		return nil
 	}
 
+	output, err := c.deps.Executor.Execute(ctx, "cat", "/proc/loadavg")
+	if err != nil {
+		return err
+	}
+	threads, ok := parseLoadavgThreads(string(output))
+	if !ok {
+		c.deps.parseFailed(c.Name(), "loadavg")
+		return fmt.Errorf("failed to parse /proc/loadavg")
+	}
+	c.threadsTotal.Set(threads)
+
+	output, err = c.deps.Executor.Execute(ctx, "ls", "/proc")
+	if err != nil {
+		return err
+	}
+	c.processesTotal.Set(countPIDEntries(string(output)))
 	return nil
 }
 
+// parseLoadavgThreads parses the total of the running/total field of /proc/loadavg
+// Example: "0.52 0.58 0.59 3/1215 40412"
+func parseLoadavgThreads(output string) (float64, bool) {
+	fields := strings.Fields(output)
+	if len(fields) < 4 {
+		return 0, false
+	}
+	_, total, ok := strings.Cut(fields[3], "/")
+	if !ok {
+		return 0, false
+	}
+	threads, err := strconv.ParseFloat(total, 64)
+	if err != nil {
+		return 0, false
+	}
+	return threads, true
+}
+
+// countPIDEntries counts the entries of a /proc listing that are process IDs
+func countPIDEntries(output string) float64 {
+	count := 0.0
+	for _, name := range strings.Fields(output) {
+		if _, err := strconv.Atoi(name); err == nil {
+			count++
+		}
+	}
+	return count
+}
+
+// parseThreadCounts parses ps -o nlwp= output, one thread count per process, into processes and threads
+// Example:
+// "   1"
+// "  12"
+func parseThreadCounts(output string) (processes, threads float64, ok bool) {
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		count, err := strconv.ParseFloat(line, 64)
+		if err != nil {
+			return 0, 0, false
+		}
+		processes++
+		threads += count
+	}
+	return processes, threads, processes > 0
+}
+
+// cpuFrequencyPathRe extracts the core number from a cpufreq path
+var cpuFrequencyPathRe = regexp.MustCompile(`/cpu(\d+)/cpufreq/`)
+
+// parseCPUFrequencies parses grep -H output of scaling_cur_freq files into core -> hertz
+// Example: "/sys/devices/system/cpu/cpu0/cpufreq/scaling_cur_freq:2400000"
+// The files hold kHz, which is converted to Hz
+func parseCPUFrequencies(output string) map[string]float64 {
+	frequencies := make(map[string]float64)
+	for _, line := range strings.Split(output, "\n") {
+		path, value, ok := strings.Cut(strings.TrimSpace(line), ":")
+		if !ok {
+			continue
+		}
+
+		matches := cpuFrequencyPathRe.FindStringSubmatch(path)
+		if len(matches) != 2 {
+			continue
+		}
+
+		if kilohertz, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+			frequencies[matches[1]] = kilohertz * 1000
+		}
+	}
+	return frequencies
+}
+
 // collectMemoryMetrics collects memory metrics
 // This is the main function that collects all the memory metrics
-// The command it runs is:
-// - vm_stat
+// The commands it runs are:
+// - free -b on Linux
+// - vm_stat and sysctl -n hw.memsize on macOS
 func (c *SystemCollector) collectMemoryMetrics(ctx context.Context) error {
+	if isMacOS {
+		return c.collectMacMemoryMetrics(ctx)
+	}
+	if !c.memoryUnsupported.check(isLinux, c.deps.Logger, "memory") {
+		return nil
+	}
+
 	output, err := c.deps.Executor.GetMemoryUsage(ctx)
 	if err != nil {
 		return err
 	}
 
-	// Parse free -b output for Linux
-	lines := strings.Split(string(output), "\n")
-	for i, line := range lines {
-		if i == 0 || strings.TrimSpace(line) == "" {
-			continue // Skip header and empty lines
+	memory, err := parseFreeOutput(string(output))
+	if err != nil {
+		c.deps.parseFailed(c.Name(), "free")
+		return err
+	}
+
+	for _, column := range []string{"total", "used", "free", "available"} {
+		if value, ok := memory[column]; ok {
+			c.memoryUsage.WithLabelValues(column).Set(value)
 		}
+	}
+
+	return nil
+}
 
+// parseFreeOutput parses free -b output into column name -> bytes for the Mem: row
+// Columns are located by their header rather than fixed positions since the layout varies between versions
+// procps-ng 3.3+ and recent BusyBox:
+// "               total        used        free      shared  buff/cache   available"
+// "Mem:     16384000000  8192000000  4096000000   409600000  4096000000 12288000000"
+// procps 3.2 and older BusyBox, which have no available column:
+// "             total       used       free     shared    buffers     cached"
+// "Mem:    16384000000 8192000000 4096000000  409600000  204800000 3891200000"
+// Without an available column, it is approximated as free + buffers + cached
+func parseFreeOutput(output string) (map[string]float64, error) {
+	var header []string
+	for _, line := range strings.Split(output, "\n") {
 		fields := strings.Fields(line)
-		if len(fields) >= 7 && i == 1 { // Memory line (skip header)
-			// Format: "Mem: 16384000 8192000 4096000 4096000 4096000 12288000"
-			if total, err := strconv.ParseFloat(fields[1], 64); err == nil {
-				c.memoryUsage.WithLabelValues("total").Set(total)
-			}
-			if used, err := strconv.ParseFloat(fields[2], 64); err == nil {
-				c.memoryUsage.WithLabelValues("used").Set(used)
+		if len(fields) == 0 {
+			continue
+		}
+
+		// The header is the first non-empty line, it has no row label
+		if header == nil {
+			header = fields
+			continue
+		}
+
+		if fields[0] != "Mem:" {
+			continue
+		}
+
+		// The row label shifts the values one position to the right of the header
+		memory := make(map[string]float64)
+		for i, column := range header {
+			if i+1 >= len(fields) {
+				break
 			}
-			if free, err := strconv.ParseFloat(fields[3], 64); err == nil {
-				c.memoryUsage.WithLabelValues("free").Set(free)
+			if value, err := strconv.ParseFloat(fields[i+1], 64); err == nil {
+				memory[column] = value
 			}
-			if available, err := strconv.ParseFloat(fields[6], 64); err == nil {
-				c.memoryUsage.WithLabelValues("available").Set(available)
+		}
+
+		if _, ok := memory["available"]; !ok {
+			if free, ok := memory["free"]; ok {
+				memory["available"] = free + memory["buffers"] + memory["cached"]
 			}
 		}
+
+		return memory, nil
 	}
 
-	return nil
+	return nil, fmt.Errorf("no Mem: row in free output")
 }
 
 // collectDiskMetrics collects disk metrics
 // This is the main function that collects all the disk metrics
 // The command it runs is:
-// - df -h /
+// - df -k /
 func (c *SystemCollector) collectDiskMetrics(ctx context.Context) error {
 	output, err := c.deps.Executor.GetDiskUsage(ctx, "/")
 	if err != nil {
@@ -212,19 +537,30 @@ func (c *SystemCollector) collectDiskMetrics(ctx context.Context) error {
 		}
 
 		fields := strings.Fields(line)
-		if len(fields) >= 6 {
-			device := fields[0]
+		if len(fields) < 6 {
+			c.deps.parseFailed(c.Name(), "df_line")
+			continue
+		}
 
-			// Convert sizes from KB to bytes (df typically shows 1K blocks)
-			if total, err := strconv.ParseFloat(fields[1], 64); err == nil {
-				c.diskUsage.WithLabelValues(device, "total").Set(total * 1024)
-			}
-			if used, err := strconv.ParseFloat(fields[2], 64); err == nil {
-				c.diskUsage.WithLabelValues(device, "used").Set(used * 1024)
-			}
-			if available, err := strconv.ParseFloat(fields[3], 64); err == nil {
-				c.diskUsage.WithLabelValues(device, "available").Set(available * 1024)
-			}
+		device := fields[0]
+		// The mount point is the last column, macOS df -k has inode columns before it
+		mount := fields[len(fields)-1]
+
+		// Convert sizes from KB to bytes (df -k shows 1K blocks on both Linux and macOS)
+		total, totalErr := strconv.ParseFloat(fields[1], 64)
+		if totalErr == nil {
+			c.diskUsage.WithLabelValues(device, "total").Set(total * 1024)
+		}
+		used, usedErr := strconv.ParseFloat(fields[2], 64)
+		if usedErr == nil {
+			c.diskUsage.WithLabelValues(device, "used").Set(used * 1024)
+		}
+		// Some pseudo filesystems report a total of 0, which has no meaningful percentage
+		if totalErr == nil && usedErr == nil && total > 0 {
+			c.diskUsagePercent.WithLabelValues(device, mount).Set(used / total * 100)
+		}
+		if available, err := strconv.ParseFloat(fields[3], 64); err == nil {
+			c.diskUsage.WithLabelValues(device, "available").Set(available * 1024)
 		}
 	}
 
@@ -246,6 +582,7 @@ func (c *SystemCollector) collectUptimeMetrics(ctx context.Context) error {
 
 	// Extract uptime in seconds from uptime command output
 	// Example: "up 2 days, 10:30" or "up 10:30"
+	// macOS uses the same format: "10:30  up 2 days,  3:04, 2 users, load averages: ..."
 	re := regexp.MustCompile(`up\s+(?:(\d+)\s+days?,\s+)?(\d+):(\d+)`)
 	if matches := re.FindStringSubmatch(uptimeStr); len(matches) >= 4 {
 		days, _ := strconv.ParseFloat(matches[1], 64)
@@ -254,6 +591,8 @@ func (c *SystemCollector) collectUptimeMetrics(ctx context.Context) error {
 
 		totalSeconds := days*24*3600 + hours*3600 + minutes*60
 		c.systemUptime.Set(totalSeconds)
+	} else {
+		c.deps.parseFailed(c.Name(), "uptime")
 	}
 
 	return nil
@@ -0,0 +1,63 @@
+package collectors
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseFreeOutput(t *testing.T) {
+	tests := []struct {
+		name    string
+		output  string
+		want    map[string]float64
+		wantErr bool
+	}{
+		{
+			name: "procps-ng with available column",
+			output: "               total        used        free      shared  buff/cache   available\n" +
+				"Mem:     16384000000  8192000000  4096000000   409600000  4096000000 12288000000\n" +
+				"Swap:     2147483648           0  2147483648\n",
+			want: map[string]float64{
+				"total":      16384000000,
+				"used":       8192000000,
+				"free":       4096000000,
+				"shared":     409600000,
+				"buff/cache": 4096000000,
+				"available":  12288000000,
+			},
+		},
+		{
+			name: "procps 3.2 without available column",
+			output: "             total       used       free     shared    buffers     cached\n" +
+				"Mem:    16384000000 8192000000 4096000000  409600000  204800000 3891200000\n" +
+				"-/+ buffers/cache:  4096000000 12288000000\n" +
+				"Swap:    2147483648          0 2147483648\n",
+			want: map[string]float64{
+				"total":     16384000000,
+				"used":      8192000000,
+				"free":      4096000000,
+				"shared":    409600000,
+				"buffers":   204800000,
+				"cached":    3891200000,
+				"available": 4096000000 + 204800000 + 3891200000,
+			},
+		},
+		{
+			name:    "no Mem: row",
+			output:  "               total        used        free\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseFreeOutput(tt.output)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want error %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,261 @@
+package collectors
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// clockTicksPerSecond is USER_HZ, the unit of the /proc CPU times, which is 100 on all mainstream Linux architectures
+const clockTicksPerSecond = 100
+
+// cpuSource is one step of the Linux CPU fallback chain
+// collect returns CPU usage percentages by type
+type cpuSource struct {
+	name    string
+	collect func(ctx context.Context) (map[string]float64, error)
+}
+
+// collectLinuxCPUMetrics collects CPU metrics from the first source of the fallback chain that works:
+// 1. top -bn1
+// 2. /proc/stat, for minimal images without top
+// 3. /proc/self/stat of the harvester itself, when even /proc/stat can't be read, except on ssh targets. This is reported
+// as type="self" so it isn't mistaken for host CPU, but at least the CPU gauge isn't silently empty.
+// A change of source is logged along with why the earlier sources failed
+func (c *SystemCollector) collectLinuxCPUMetrics(ctx context.Context) error {
+	sources := []cpuSource{
+		{name: "top", collect: c.collectTopCPU},
+		{name: "/proc/stat", collect: c.collectProcStatCPU},
+		{name: "/proc/self/stat", collect: c.collectSelfCPU},
+	}
+
+	var errs []error
+	for _, source := range sources {
+		usage, err := source.collect(ctx)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", source.name, err))
+			continue
+		}
+
+		// Drop the types of the previous source, e.g. "self" once top is back
+		if source.name != c.cpuSource {
+			log := c.deps.Logger.Warn
+			if len(errs) == 0 {
+				log = c.deps.Logger.Info
+			}
+			log("CPU metrics source changed",
+				zap.String("previous", c.cpuSource),
+				zap.String("source", source.name),
+				zap.Errors("skipped", errs),
+			)
+			c.cpuUsage.Reset()
+			c.cpuSource = source.name
+		}
+
+		for cpuType, value := range usage {
+			c.cpuUsage.WithLabelValues(cpuType).Set(value)
+		}
+		return nil
+	}
+
+	return errors.Join(errs...)
+}
+
+// topCPURe matches the values of the top CPU line
+var topCPURe = regexp.MustCompile(`(\d+\.?\d*)\s+(\w+)`)
+
+// collectTopCPU gets CPU usage from top
+// The command it runs is:
+// - top -bn1
+func (c *SystemCollector) collectTopCPU(ctx context.Context) (map[string]float64, error) {
+	output, err := c.deps.Executor.GetCPUUsage(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return parseTopCPU(string(output))
+}
+
+// parseTopCPU parses the last CPU line of top -bn1 or top -bn2 output
+// With two iterations the last one is used, the first covers the time since boot
+// Example: "%Cpu(s):  3.2 us,  1.1 sy,  0.0 ni, 95.6 id,  0.0 wa,  0.0 hi,  0.1 si,  0.0 st"
+func parseTopCPU(output string) (map[string]float64, error) {
+	lines := strings.Split(output, "\n")
+	for i := len(lines) - 1; i >= 0; i-- {
+		line := lines[i]
+		if !strings.Contains(line, "%Cpu(s):") {
+			continue
+		}
+
+		usage := make(map[string]float64)
+		for _, match := range topCPURe.FindAllStringSubmatch(line, -1) {
+			value, err := strconv.ParseFloat(match[1], 64)
+			if err != nil {
+				continue
+			}
+			switch match[2] {
+			case "us":
+				usage["user"] = value
+			case "sy":
+				usage["system"] = value
+			case "id":
+				usage["idle"] = value
+			case "wa":
+				usage["iowait"] = value
+			case "st":
+				usage["steal"] = value
+			}
+		}
+		return usage, nil
+	}
+
+	return nil, fmt.Errorf("no %%Cpu(s) line in top output")
+}
+
+// collectProcStatCPU gets CPU usage from the aggregate cpu line of /proc/stat
+// The times are cumulative, so usage is computed over the time since the previous cycle,
+// or since boot on the first one. With metrics.cpu_sample_window, it is computed over that window instead.
+// /proc/stat is read twice with metrics.cpu_sample_window, see readProcStatCPU
+func (c *SystemCollector) collectProcStatCPU(ctx context.Context) (map[string]float64, error) {
+	if window := c.deps.Config.Metrics.CPUSampleWindow.Duration; window > 0 {
+		first, err := c.readProcStatCPU(ctx)
+		if err != nil {
+			return nil, err
+		}
+		c.procStatPrevious = first
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(window):
+		}
+	}
+
+	times, err := c.readProcStatCPU(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	deltas := times
+	if len(c.procStatPrevious) == len(times) {
+		deltas = make([]float64, len(times))
+		for i := range times {
+			deltas[i] = times[i] - c.procStatPrevious[i]
+		}
+	}
+	c.procStatPrevious = times
+
+	// user nice system idle iowait irq softirq steal, guest time is already part of user
+	var total float64
+	for _, delta := range deltas {
+		total += delta
+	}
+	if total <= 0 {
+		return nil, fmt.Errorf("no CPU time elapsed in /proc/stat")
+	}
+
+	return map[string]float64{
+		"user":   deltas[0] / total * 100,
+		"system": deltas[2] / total * 100,
+		"idle":   deltas[3] / total * 100,
+		"iowait": deltas[4] / total * 100,
+		"steal":  deltas[7] / total * 100,
+	}, nil
+}
+
+// readProcStatCPU reads the times of the aggregate cpu line of /proc/stat
+// The command it runs on an ssh target is:
+// - cat /proc/stat
+func (c *SystemCollector) readProcStatCPU(ctx context.Context) ([]float64, error) {
+	output, err := c.deps.readProcFile(ctx, "/proc/stat")
+	if err != nil {
+		return nil, err
+	}
+	return parseProcStatCPU(string(output))
+}
+
+// parseProcStatCPU parses the first 8 times of the aggregate cpu line of /proc/stat
+// Example: "cpu  10132153 290696 3084719 46828483 16683 0 25195 0 0 0"
+func parseProcStatCPU(output string) ([]float64, error) {
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 9 || fields[0] != "cpu" {
+			continue
+		}
+
+		times := make([]float64, 8)
+		for i := range times {
+			value, err := strconv.ParseFloat(fields[i+1], 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid /proc/stat cpu line %q: %w", line, err)
+			}
+			times[i] = value
+		}
+		return times, nil
+	}
+
+	return nil, fmt.Errorf("no cpu line in /proc/stat")
+}
+
+// collectSelfCPU gets the CPU usage of the harvester process itself, as a percentage of one core
+// Usage is computed over the time since the previous cycle, so the first cycle reports nothing
+// It is read in-process and unavailable on ssh targets, where the harvester's own CPU says nothing about the host
+func (c *SystemCollector) collectSelfCPU(_ context.Context) (map[string]float64, error) {
+	if c.deps.remote() {
+		return nil, fmt.Errorf("the harvester's own CPU isn't collected for ssh targets")
+	}
+	output, err := os.ReadFile("/proc/self/stat")
+	if err != nil {
+		return nil, err
+	}
+
+	ticks, err := parseProcessCPUTicks(string(output))
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	previous := c.selfPrevious
+	c.selfPrevious = rateSample{value: ticks, at: now}
+
+	elapsed := now.Sub(previous.at).Seconds()
+	if previous.at.IsZero() || elapsed <= 0 {
+		return map[string]float64{}, nil
+	}
+
+	cpuSeconds := (ticks - previous.value) / clockTicksPerSecond
+	return map[string]float64{"self": cpuSeconds / elapsed * 100}, nil
+}
+
+// parseProcessCPUTicks parses the user + system CPU time in clock ticks from /proc/<pid>/stat
+// The command name in parentheses may contain spaces, so fields are counted from the closing parenthesis
+// Example: "1234 (metric_harvester) S 1 1234 1234 0 -1 4194560 2290 0 0 0 152 37 0 0 20 0 9 0 ..."
+// utime and stime are the 14th and 15th fields
+func parseProcessCPUTicks(output string) (float64, error) {
+	end := strings.LastIndex(output, ")")
+	if end < 0 {
+		return 0, fmt.Errorf("invalid process stat %q", output)
+	}
+
+	// fields[0] is the state, the 3rd field
+	fields := strings.Fields(output[end+1:])
+	if len(fields) < 13 {
+		return 0, fmt.Errorf("invalid process stat %q", output)
+	}
+
+	utime, err := strconv.ParseFloat(fields[11], 64)
+	if err != nil {
+		return 0, err
+	}
+	stime, err := strconv.ParseFloat(fields[12], 64)
+	if err != nil {
+		return 0, err
+	}
+	return utime + stime, nil
+}
@@ -0,0 +1,135 @@
+package collectors
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// macOS system metrics, used when the harvester runs directly on the host rather than in the Linux VM
+// The file isn't named *_darwin.go so it builds on every platform and the parsers stay checkable on Linux
+
+// macCPUUsageRe matches the CPU line of top -l 1
+// Example: "CPU usage: 5.12% user, 10.25% sys, 84.61% idle"
+var macCPUUsageRe = regexp.MustCompile(`(\d+\.?\d*)%\s+(user|sys|idle)`)
+
+// vmStatPageSizeRe matches the page size in the vm_stat header
+// Example: "Mach Virtual Memory Statistics: (page size of 16384 bytes)"
+var vmStatPageSizeRe = regexp.MustCompile(`page size of (\d+) bytes`)
+
+// collectMacCPUMetrics collects CPU metrics on macOS
+// The command it runs is:
+// - top -l 1 -n 0
+func (c *SystemCollector) collectMacCPUMetrics(ctx context.Context) error {
+	output, err := c.deps.Executor.GetMacCPUUsage(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, line := range strings.Split(string(output), "\n") {
+		if !strings.HasPrefix(strings.TrimSpace(line), "CPU usage:") {
+			continue
+		}
+
+		for _, match := range macCPUUsageRe.FindAllStringSubmatch(line, -1) {
+			value, err := strconv.ParseFloat(match[1], 64)
+			if err != nil {
+				continue
+			}
+			switch match[2] {
+			case "user":
+				c.cpuUsage.WithLabelValues("user").Set(value)
+			case "sys":
+				c.cpuUsage.WithLabelValues("system").Set(value)
+			case "idle":
+				c.cpuUsage.WithLabelValues("idle").Set(value)
+			}
+		}
+		return nil
+	}
+
+	return fmt.Errorf("no CPU usage line in top output")
+}
+
+// collectMacMemoryMetrics collects memory metrics on macOS
+// Available memory is free + inactive + speculative pages, which the kernel can hand out without swapping
+// The commands it runs are:
+// - vm_stat
+// - sysctl -n hw.memsize
+func (c *SystemCollector) collectMacMemoryMetrics(ctx context.Context) error {
+	output, err := c.deps.Executor.GetMacVMStat(ctx)
+	if err != nil {
+		return err
+	}
+
+	pages, err := parseVmStat(string(output))
+	if err != nil {
+		return err
+	}
+
+	free := pages["Pages free"]
+	available := free + pages["Pages inactive"] + pages["Pages speculative"]
+	c.memoryUsage.WithLabelValues("free").Set(free)
+	c.memoryUsage.WithLabelValues("available").Set(available)
+
+	output, err = c.deps.Executor.GetMacPhysicalMemory(ctx)
+	if err != nil {
+		return err
+	}
+
+	total, err := strconv.ParseFloat(strings.TrimSpace(string(output)), 64)
+	if err != nil {
+		return fmt.Errorf("failed to parse hw.memsize: %w", err)
+	}
+	c.memoryUsage.WithLabelValues("total").Set(total)
+	c.memoryUsage.WithLabelValues("used").Set(total - available)
+
+	return nil
+}
+
+// parseVmStat parses vm_stat output into statistic name -> bytes
+// Only page counts are returned, converted to bytes using the page size from the header
+// Example:
+// "Mach Virtual Memory Statistics: (page size of 16384 bytes)"
+// "Pages free:                               12345."
+// "Pages active:                            234567."
+// "File-backed pages:                       111111."
+// "Translation faults:                   123456789."  (not a page count, skipped)
+func parseVmStat(output string) (map[string]float64, error) {
+	lines := strings.Split(output, "\n")
+	if len(lines) == 0 {
+		return nil, fmt.Errorf("empty vm_stat output")
+	}
+
+	matches := vmStatPageSizeRe.FindStringSubmatch(lines[0])
+	if len(matches) != 2 {
+		return nil, fmt.Errorf("no page size in vm_stat header: %q", lines[0])
+	}
+	pageSize, err := strconv.ParseFloat(matches[1], 64)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := make(map[string]float64)
+	for _, line := range lines[1:] {
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+
+		name = strings.Trim(strings.TrimSpace(name), `"`)
+		if !strings.Contains(strings.ToLower(name), "pages") {
+			continue
+		}
+
+		count, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(value), "."), 64)
+		if err != nil {
+			continue
+		}
+		stats[name] = count * pageSize
+	}
+
+	return stats, nil
+}
@@ -1,9 +1,17 @@
 package config
 
 import (
+	"bytes"
 	"encoding/json"
+	"fmt"
+	"net/url"
 	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
 	"time"
+
+	"go.uber.org/zap/zapcore"
 )
 
 // Duration is a custom type that can unmarshal from JSON strings
@@ -25,12 +33,154 @@ func (d *Duration) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+// MarshalJSON implements the json.Marshaler interface, writing the same string format UnmarshalJSON reads
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.Duration.String())
+}
+
+// PingTarget is a host pinged by the network collector
+// It is either a plain address or {"name": "gateway", "address": "10.0.0.1"}, whose name is then used as the
+// target label so dashboards don't show raw IPs
+type PingTarget struct {
+	Name    string `yaml:"name" json:"name"`
+	Address string `yaml:"address" json:"address"`
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface, accepting a plain address string or an object
+// Without a name, the address is used as the name
+func (t *PingTarget) UnmarshalJSON(data []byte) error {
+	var address string
+	if err := json.Unmarshal(data, &address); err == nil {
+		*t = PingTarget{Name: address, Address: address}
+		return nil
+	}
+
+	// A distinct type keeps the decoder from calling this method again. The outer decoder's DisallowUnknownFields
+	// doesn't reach this method, so it is set again and a misspelled key like adress fails instead of being dropped
+	type pingTarget PingTarget
+	var target pingTarget
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&target); err != nil {
+		return fmt.Errorf("ping target must be an address or an object with name and address: %w", err)
+	}
+	if target.Name == "" {
+		target.Name = target.Address
+	}
+	*t = PingTarget(target)
+	return nil
+}
+
+// Executor modes of a target
+const (
+	ExecutorLocal = "local"
+	ExecutorSSH   = "ssh"
+)
+
+// Sources of the network interface statistics, see Network.InterfaceSource
+const (
+	InterfaceSourceProc = "proc"
+	InterfaceSourceIP   = "ip"
+	InterfaceSourceAuto = "auto"
+)
+
+// Ping backends, see Network.PingBackend
+const (
+	PingBackendPing  = "ping"
+	PingBackendFPing = "fping"
+)
+
+// Profiles selecting defaults for a harvester instance, see Config.Profile
+const (
+	ProfileRootful  = "rootful"
+	ProfileRootless = "rootless"
+)
+
+// Log formats, see Logging.Format
+const (
+	LogFormatJSON    = "json"
+	LogFormatConsole = "console"
+)
+
+// Target is a host collected by its own set of collectors
+type Target struct {
+	// Name is set as the host label on every metric collected from the host
+	Name string `yaml:"name" json:"name"`
+	// Executor is local to run commands on the harvester's host or ssh to run them on SSHHost
+	Executor string `yaml:"executor" json:"executor" default:"local"`
+	// SSHHost is the ssh destination, e.g. bench@rootless-host; keys and host keys come from the ssh config
+	SSHHost string `yaml:"ssh_host" json:"ssh_host"`
+	// Config overrides the top-level settings for this target, e.g. {"containers": {"podman_user": "bench"}}
+	// A profile in it is applied over the inherited settings, replacing the keys the profile sets even where the
+	// top-level file set them; keys set in Config itself override the profile
+	Config json.RawMessage `yaml:"config" json:"config"`
+}
+
+// Relabel actions
+const (
+	RelabelRename = "rename"
+	RelabelDrop   = "drop"
+)
+
+// labelNameRe matches valid Prometheus label names
+var labelNameRe = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// metricNameRe matches valid Prometheus metric names
+var metricNameRe = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+
+// RelabelRule renames or drops a label on the served metrics, e.g. to match node_exporter-based dashboards
+type RelabelRule struct {
+	// Action is rename to move SourceLabel's value to TargetLabel, or drop to remove SourceLabel
+	Action string `yaml:"action" json:"action" default:"rename"`
+	// SourceLabel is the label the rule applies to
+	SourceLabel string `yaml:"source_label" json:"source_label"`
+	// TargetLabel is the new name of SourceLabel, only for rename
+	TargetLabel string `yaml:"target_label" json:"target_label"`
+	// MetricPattern restricts the rule to metric names matching this regular expression, all metrics when empty
+	MetricPattern string `yaml:"metric_pattern" json:"metric_pattern"`
+}
+
+// UnmarshalJSON decodes a relabel rule over the default action, rename, since rules in a list get no defaults
+// from setDefaults
+func (r *RelabelRule) UnmarshalJSON(data []byte) error {
+	// A distinct type keeps the decoder from calling this method again, and DisallowUnknownFields is set again
+	// as for PingTarget
+	type relabelRule RelabelRule
+	rule := relabelRule{Action: RelabelRename}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&rule); err != nil {
+		return fmt.Errorf("relabel rule: %w", err)
+	}
+	*r = RelabelRule(rule)
+	return nil
+}
+
 type Config struct {
+	// Profile is rootful or rootless and selects defaults suited to that mode, see applyProfile
+	// Keys set in the file override the profile's defaults; empty keeps the plain defaults
+	Profile string `yaml:"profile" json:"profile"`
+
 	Server struct {
 		Port            string   `yaml:"port" json:"port" default:":8080"`
 		ReadTimeout     Duration `yaml:"read_timeout" json:"read_timeout" default:"10s"`
 		WriteTimeout    Duration `yaml:"write_timeout" json:"write_timeout" default:"10s"`
 		ShutdownTimeout Duration `yaml:"shutdown_timeout" json:"shutdown_timeout" default:"30s"`
+		// IdleTimeout closes keep-alive connections that sit idle this long
+		IdleTimeout Duration `yaml:"idle_timeout" json:"idle_timeout" default:"60s"`
+		// ReadHeaderTimeout bounds how long a client may take to send the request headers, guarding against slow-loris
+		ReadHeaderTimeout Duration `yaml:"read_header_timeout" json:"read_header_timeout" default:"5s"`
+		// HistorySize is the number of recent cycle summaries kept for /history, 0 disables it
+		HistorySize int `yaml:"history_size" json:"history_size" default:"100"`
+		// BindRetries retries binding Port this many times with backoff while it is in use, e.g. during a quick restart
+		BindRetries int `yaml:"bind_retries" json:"bind_retries" default:"3"`
+		// AllowedUserAgents and DeniedUserAgents are regular expressions matched against the User-Agent of /metrics
+		// requests, which get 403 when denied or when allowed patterns are set and none matches. Empty allows all.
+		AllowedUserAgents []string `yaml:"allowed_user_agents" json:"allowed_user_agents"`
+		DeniedUserAgents  []string `yaml:"denied_user_agents" json:"denied_user_agents"`
+		// InstanceLabels are the target labels of this instance in the /prometheus-config snippet,
+		// e.g. {"mode": "rootless"}, which also becomes part of the job name
+		InstanceLabels map[string]string `yaml:"instance_labels" json:"instance_labels"`
 	} `yaml:"server" json:"server"`
 
 	Metrics struct {
@@ -39,6 +189,52 @@ type Config struct {
 		EnableSystemMetrics    bool     `yaml:"enable_system_metrics" json:"enable_system_metrics" default:"true"`
 		EnableContainerMetrics bool     `yaml:"enable_container_metrics" json:"enable_container_metrics" default:"true"`
 		EnableNetworkMetrics   bool     `yaml:"enable_network_metrics" json:"enable_network_metrics" default:"true"`
+		// OverrunThreshold is the fraction of CollectionInterval a cycle may take before it is reported as an overrun
+		OverrunThreshold float64 `yaml:"overrun_threshold" json:"overrun_threshold" default:"0.8"`
+		// LatencyBuckets are the histogram buckets in seconds used by latency histograms
+		LatencyBuckets []float64 `yaml:"latency_buckets" json:"latency_buckets"`
+		// RateWindow is the number of collection cycles rate metrics are averaged over
+		RateWindow int `yaml:"rate_window" json:"rate_window" default:"1"`
+		// MaxInterfaces caps the interfaces reported per cycle to those with the most traffic, 0 means no cap
+		MaxInterfaces int `yaml:"max_interfaces" json:"max_interfaces" default:"0"`
+		// EnableCPUFrequency collects the current frequency of each core from cpufreq, which explains run-to-run variance
+		EnableCPUFrequency bool `yaml:"enable_cpu_frequency" json:"enable_cpu_frequency" default:"false"`
+		// EnableHostInfo exposes the kernel version, unprivileged user namespace support and cgroup version,
+		// read once at startup, as harvester_host_info
+		EnableHostInfo bool `yaml:"enable_host_info" json:"enable_host_info" default:"true"`
+		// EnableEntropy collects the bits available in the kernel random pool from /proc/sys/kernel/random, Linux only
+		EnableEntropy bool `yaml:"enable_entropy" json:"enable_entropy" default:"false"`
+		// EnablePSIMetrics collects Linux pressure stall information from /proc/pressure, skipped when the kernel lacks it
+		EnablePSIMetrics bool `yaml:"enable_psi_metrics" json:"enable_psi_metrics" default:"false"`
+		// CollectOnScrape collects when /metrics is scraped instead of on a ticker,
+		// with CollectionInterval as the minimum time between collections
+		CollectOnScrape bool `yaml:"collect_on_scrape" json:"collect_on_scrape" default:"false"`
+		// CollectorIntervals overrides CollectionInterval per collector name, e.g. {"system": "1m", "network": "5s"}
+		CollectorIntervals map[string]Duration `yaml:"collector_intervals" json:"collector_intervals"`
+		// CPUSampleWindow, when set, measures Linux CPU usage over this window within each collection
+		// instead of top's instantaneous reading or the time since the previous cycle
+		CPUSampleWindow Duration `yaml:"cpu_sample_window" json:"cpu_sample_window" default:"0s"`
+		// StartupDelay waits before the first collection, giving docker/podman and the network time to come up
+		StartupDelay Duration `yaml:"startup_delay" json:"startup_delay" default:"0s"`
+		// StartupRetries retries each collector's first collection this many times while it doesn't fully succeed
+		StartupRetries int `yaml:"startup_retries" json:"startup_retries" default:"0"`
+		// Relabel renames or drops labels of the served metrics, applied in order after gathering
+		Relabel []RelabelRule `yaml:"relabel" json:"relabel"`
+		// HelpOverrides replaces the help text of metrics by name, e.g. to match an existing metrics catalog
+		// The names must be metrics the harvester emits, which the server checks at startup
+		HelpOverrides map[string]string `yaml:"help_overrides" json:"help_overrides"`
+		// System toggles the system sub-collections, e.g. to skip df where a network mount makes it stall
+		System struct {
+			CPU    bool `yaml:"cpu" json:"cpu" default:"true"`
+			Memory bool `yaml:"memory" json:"memory" default:"true"`
+			Disk   bool `yaml:"disk" json:"disk" default:"true"`
+			Uptime bool `yaml:"uptime" json:"uptime" default:"true"`
+			// Processes counts the processes and threads of the system, Linux only
+			Processes bool `yaml:"processes" json:"processes" default:"true"`
+		} `yaml:"system" json:"system"`
+		// EnableThreadWalk counts processes and threads with ps, reading every process's thread count,
+		// instead of the kernel-wide thread count of /proc/loadavg. It is costly with many processes
+		EnableThreadWalk bool `yaml:"enable_thread_walk" json:"enable_thread_walk" default:"false"`
 	} `yaml:"metrics" json:"metrics"`
 
 	Containers struct {
@@ -46,14 +242,99 @@ type Config struct {
 		PodmanEnabled  bool     `yaml:"podman_enabled" json:"podman_enabled" default:"true"`
 		MonitoredNames []string `yaml:"monitored_names" json:"monitored_names"`
 		IgnoredNames   []string `yaml:"ignored_names" json:"ignored_names"`
+		// IgnoredNamePatterns ignores containers whose name matches any of these regular expressions,
+		// e.g. for names with generated suffixes
+		IgnoredNamePatterns []string `yaml:"ignored_name_patterns" json:"ignored_name_patterns"`
+		// DockerSocket, when set, collects Docker stats through the API on this unix socket instead of the docker CLI
+		DockerSocket string `yaml:"docker_socket" json:"docker_socket"`
+		// MaxPerCycle caps how many containers get stats per cycle when MonitoredNames is empty, 0 means no cap
+		MaxPerCycle int `yaml:"max_per_cycle" json:"max_per_cycle" default:"0"`
+		// StatsDelta gets stats for containers that appeared since the previous cycle ahead of the MaxPerCycle rotation
+		StatsDelta bool `yaml:"stats_delta" json:"stats_delta" default:"false"`
+		// MaxReported caps the containers per runtime whose stats are reported to those with the most network traffic,
+		// 0 means no cap
+		MaxReported int `yaml:"max_reported" json:"max_reported" default:"0"`
+		// StatsConcurrency is how many per-container stats calls may run at the same time
+		StatsConcurrency int `yaml:"stats_concurrency" json:"stats_concurrency" default:"4"`
+		// PodmanUser, when set, runs podman as this user through sudo to see that user's rootless containers
+		PodmanUser string `yaml:"podman_user" json:"podman_user"`
+		// NoTrunc passes --no-trunc to docker and podman stats so IDs in the container label are full and unambiguous
+		NoTrunc bool `yaml:"no_trunc" json:"no_trunc" default:"false"`
+		// DockerPath and PodmanPath are the CLI binaries to run, looked up on PATH unless absolute
+		DockerPath string `yaml:"docker_path" json:"docker_path" default:"docker"`
+		PodmanPath string `yaml:"podman_path" json:"podman_path" default:"podman"`
+		// DockerExtraArgs and PodmanExtraArgs are prepended to every docker/podman command,
+		// e.g. --context, -H or --url to reach a remote daemon or a non-default rootless socket
+		DockerExtraArgs []string `yaml:"docker_extra_args" json:"docker_extra_args"`
+		PodmanExtraArgs []string `yaml:"podman_extra_args" json:"podman_extra_args"`
+		// EnableNetnsStats reads /proc/net/dev inside each monitored container's network namespace with nsenter,
+		// which needs root or CAP_SYS_ADMIN
+		EnableNetnsStats bool `yaml:"enable_netns_stats" json:"enable_netns_stats" default:"false"`
+		// RuntimeRetries retries a docker or podman command this many times with a short backoff while the daemon
+		// or socket refuses connections, e.g. during a restart, 0 disables retrying
+		RuntimeRetries int `yaml:"runtime_retries" json:"runtime_retries" default:"2"`
 	} `yaml:"containers" json:"containers"`
 
 	Network struct {
-		PingTargets       []string `yaml:"ping_targets" json:"ping_targets"`
-		MonitorLoopback   bool     `yaml:"monitor_loopback" json:"monitor_loopback" default:"false"`
-		IgnoredInterfaces []string `yaml:"ignored_interfaces" json:"ignored_interfaces"`
+		// PingTargets are addresses or {"name": ..., "address": ...} objects, see PingTarget
+		PingTargets []PingTarget `yaml:"ping_targets" json:"ping_targets"`
+		// HTTPTargets are URLs timed by connection phase, given like PingTargets with the URL as the address
+		HTTPTargets       []PingTarget `yaml:"http_targets" json:"http_targets"`
+		MonitorLoopback   bool         `yaml:"monitor_loopback" json:"monitor_loopback" default:"false"`
+		IgnoredInterfaces []string     `yaml:"ignored_interfaces" json:"ignored_interfaces"`
+		// IgnoredInterfacePatterns ignores interfaces whose name matches any of these regular expressions,
+		// e.g. ^veth for the numbered veth pairs rootless networking creates
+		IgnoredInterfacePatterns []string `yaml:"ignored_interface_patterns" json:"ignored_interface_patterns"`
+		// MonitoredInterfaces, when non-empty, restricts collection to these interfaces
+		MonitoredInterfaces []string `yaml:"monitored_interfaces" json:"monitored_interfaces"`
+		// PingPath is the ping binary to run, e.g. a capability-enabled copy for unprivileged containers
+		PingPath string `yaml:"ping_path" json:"ping_path" default:"ping"`
+		// PingBackend is ping, one process per target, or fping, which pings every target in parallel in one process
+		// and falls back to ping when it isn't installed
+		PingBackend string `yaml:"ping_backend" json:"ping_backend" default:"ping"`
+		// PingTimeout is how long ping waits for each reply, so dead targets fail fast instead of stretching the cycle
+		PingTimeout Duration `yaml:"ping_timeout" json:"ping_timeout" default:"2s"`
+		// EnableQdiscStats collects drops and overlimits of each monitored interface's qdiscs with tc
+		EnableQdiscStats bool `yaml:"enable_qdisc_stats" json:"enable_qdisc_stats" default:"false"`
+		// EnableQueueStats collects the rx/tx queue count of each monitored interface from /sys/class/net,
+		// and per-queue byte counters with ethtool -S where the driver reports them
+		EnableQueueStats bool `yaml:"enable_queue_stats" json:"enable_queue_stats" default:"false"`
+		// InterfaceSource is where interface statistics are read from: proc (/proc/net/dev), ip (ip -s link),
+		// or auto, which falls back to ip when /proc/net/dev can't be read or shows no monitored interface,
+		// as happens inside some rootless network namespaces
+		InterfaceSource string `yaml:"interface_source" json:"interface_source" default:"proc"`
+		// SysfsUpState takes network_interface_up from /sys/class/net, also reporting idle and down interfaces,
+		// instead of inferring it from traffic
+		SysfsUpState bool `yaml:"sysfs_up_state" json:"sysfs_up_state" default:"true"`
+		// SkipPingWithoutRoute skips external ping targets while neither /proc/net/route nor /proc/net/ipv6_route has a default route,
+		// e.g. in an isolated rootless namespace, instead of waiting out ping_timeout on each of them every cycle
+		SkipPingWithoutRoute bool `yaml:"skip_ping_without_route" json:"skip_ping_without_route" default:"true"`
 	} `yaml:"network" json:"network"`
 
+	Executor struct {
+		// Env holds KEY=VALUE pairs set on every executed command, on top of the harvester's environment
+		// Forcing the C locale keeps df/free/ping output deterministic for the parsers
+		Env []string `yaml:"env" json:"env"`
+		// SSHHost, when set, runs every command on that host over ssh instead of locally, set by ssh targets
+		SSHHost string `yaml:"ssh_host" json:"ssh_host"`
+	} `yaml:"executor" json:"executor"`
+
+	Federation struct {
+		// Upstreams are /metrics URLs scraped every collection interval and re-exposed with a source label
+		Upstreams []string `yaml:"upstreams" json:"upstreams"`
+		// MetricPrefix is prepended to re-exposed metric names so they don't collide with local ones
+		MetricPrefix string `yaml:"metric_prefix" json:"metric_prefix" default:"federated_"`
+	} `yaml:"federation" json:"federation"`
+
+	// Textfile writes the metrics for node_exporter's textfile collector, so hosts already running node_exporter
+	// don't need a second scrape target
+	Textfile struct {
+		// Directory is node_exporter's --collector.textfile.directory, harvester.prom is written there; empty disables it
+		Directory string `yaml:"directory" json:"directory"`
+		// Interval is how often the file is rewritten
+		Interval Duration `yaml:"interval" json:"interval" default:"15s"`
+	} `yaml:"textfile" json:"textfile"`
+
 	Benchmarking struct {
 		WorkloadsPath  string   `yaml:"workloads_path" json:"workloads_path" default:"./workloads"`
 		ResultsPath    string   `yaml:"results_path" json:"results_path" default:"./results"`
@@ -62,35 +343,438 @@ type Config struct {
 	} `yaml:"benchmarking" json:"benchmarking"`
 
 	Logging struct {
-		Level  string `yaml:"level" json:"level" default:"info"`
+		Level string `yaml:"level" json:"level" default:"info"`
+		// Format is json for log collectors or console for human-readable development output
 		Format string `yaml:"format" json:"format" default:"json"`
+		// Levels overrides Level per subsystem: executor, docker_api or a collector name, e.g. {"executor": "debug"}
+		Levels map[string]string `yaml:"levels" json:"levels"`
 	} `yaml:"logging" json:"logging"`
+
+	// Targets collects several hosts in one process, e.g. the rootful and the rootless host of a comparison
+	// Each target runs its own collectors and its metrics get a host label; when empty only the local host is collected
+	Targets []Target `yaml:"targets" json:"targets"`
+
+	// Comparison emits comparison_throughput_ratio = candidate / baseline for each of Metrics,
+	// answering the rootful vs rootless question in a single series
+	Comparison struct {
+		// Baseline and Candidate are target names, e.g. rootful and rootless
+		Baseline  string `yaml:"baseline" json:"baseline"`
+		Candidate string `yaml:"candidate" json:"candidate"`
+		// Metrics are the metric names compared, each summed over all of a target's series
+		Metrics []string `yaml:"metrics" json:"metrics"`
+	} `yaml:"comparison" json:"comparison"`
+}
+
+// ForTarget returns the configuration a target's collectors run with
+// It is a copy of this configuration with the target's overrides applied and its executor mode set
+// Args:
+// - target: Target
+// Returns:
+// - *Config: the target's configuration
+// - error: error if the overrides don't apply or the result is invalid
+func (c *Config) ForTarget(target Target) (*Config, error) {
+	// A JSON round trip deep copies the slices and maps the overrides would otherwise modify in place
+	data, err := json.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+	config := &Config{}
+	if err := json.Unmarshal(data, config); err != nil {
+		return nil, err
+	}
+	config.Targets = nil
+	config.Comparison.Metrics = nil
+
+	if len(target.Config) > 0 {
+		// A target's own profile applies over the inherited settings, also over keys the top-level file set
+		// explicitly, and its own keys over the profile
+		var selected struct {
+			Profile string `json:"profile"`
+		}
+		if err := json.Unmarshal(target.Config, &selected); err == nil && selected.Profile != "" {
+			config.applyProfile(selected.Profile)
+		}
+
+		decoder := json.NewDecoder(strings.NewReader(string(target.Config)))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(config); err != nil {
+			return nil, fmt.Errorf("targets %q config: %w", target.Name, err)
+		}
+	}
+
+	config.Executor.SSHHost = ""
+	if target.Executor == ExecutorSSH {
+		config.Executor.SSHHost = target.SSHHost
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("targets %q config: %w", target.Name, err)
+	}
+	return config, nil
 }
 
+// New creates a new Config populated with default values
 func New() *Config {
 	config := &Config{}
+	config.setDefaults()
 	return config
 }
 
+// setDefaults fills in default values for options that may be omitted from the JSON file
+func (c *Config) setDefaults() {
+	c.Server.IdleTimeout = Duration{60 * time.Second}
+	c.Server.ReadHeaderTimeout = Duration{5 * time.Second}
+	c.Server.HistorySize = 100
+	c.Server.BindRetries = 3
+	c.Metrics.OverrunThreshold = 0.8
+	// Sub-millisecond to seconds, the range seen across rootful and rootless hosts
+	c.Metrics.LatencyBuckets = []float64{0.0001, 0.00025, 0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5}
+	c.Metrics.RateWindow = 1
+	c.Metrics.System.CPU = true
+	c.Metrics.System.Memory = true
+	c.Metrics.System.Disk = true
+	c.Metrics.System.Uptime = true
+	c.Metrics.System.Processes = true
+	c.Metrics.EnableHostInfo = true
+	c.Containers.StatsConcurrency = 4
+	c.Containers.DockerPath = "docker"
+	c.Containers.PodmanPath = "podman"
+	c.Containers.RuntimeRetries = 2
+	c.Network.PingPath = "ping"
+	c.Network.PingTimeout = Duration{2 * time.Second}
+	c.Network.InterfaceSource = InterfaceSourceProc
+	c.Network.SkipPingWithoutRoute = true
+	c.Network.SysfsUpState = true
+	c.Network.PingBackend = PingBackendPing
+	c.Executor.Env = []string{"LC_ALL=C", "LANG=C"}
+	c.Federation.MetricPrefix = "federated_"
+	c.Textfile.Interval = Duration{15 * time.Second}
+	c.Logging.Level = "info"
+	c.Logging.Format = LogFormatJSON
+}
+
+// applyProfile sets the defaults of a profile, before the keys of the configuration file are decoded over them
+// The rootless profile collects podman, which rootless containers run under, and ignores the veth interfaces
+// rootless networking creates per container. The rootful profile collects docker. Cgroup paths, e.g. under
+// user.slice for rootless containers, are read from /proc/<pid>/cgroup and need no setting. Unknown profiles are
+// left to Validate
+func (c *Config) applyProfile(profile string) {
+	switch profile {
+	case ProfileRootful:
+		c.Containers.DockerEnabled = true
+		c.Containers.PodmanEnabled = false
+	case ProfileRootless:
+		c.Containers.DockerEnabled = false
+		c.Containers.PodmanEnabled = true
+		c.Network.IgnoredInterfacePatterns = []string{"^veth"}
+	}
+}
+
+// Validate checks that the configuration values are usable
+func (c *Config) Validate() error {
+	if c.Profile != "" && c.Profile != ProfileRootful && c.Profile != ProfileRootless {
+		return fmt.Errorf("profile must be %s or %s, got %q", ProfileRootful, ProfileRootless, c.Profile)
+	}
+	if c.Server.HistorySize < 0 {
+		return fmt.Errorf("server.history_size must not be negative, got %d", c.Server.HistorySize)
+	}
+	if c.Server.BindRetries < 0 {
+		return fmt.Errorf("server.bind_retries must not be negative, got %d", c.Server.BindRetries)
+	}
+	for _, pattern := range c.Server.AllowedUserAgents {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("server.allowed_user_agents entry %q is not a valid regular expression: %w", pattern, err)
+		}
+	}
+	for _, pattern := range c.Server.DeniedUserAgents {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("server.denied_user_agents entry %q is not a valid regular expression: %w", pattern, err)
+		}
+	}
+	for name := range c.Server.InstanceLabels {
+		if !labelNameRe.MatchString(name) || strings.HasPrefix(name, "__") {
+			return fmt.Errorf("server.instance_labels key %q is not a valid label name", name)
+		}
+	}
+	if c.Metrics.OverrunThreshold <= 0 || c.Metrics.OverrunThreshold > 1 {
+		return fmt.Errorf("metrics.overrun_threshold must be in (0, 1], got %v", c.Metrics.OverrunThreshold)
+	}
+	if len(c.Metrics.LatencyBuckets) == 0 {
+		return fmt.Errorf("metrics.latency_buckets must not be empty")
+	}
+	for i, bucket := range c.Metrics.LatencyBuckets {
+		if bucket <= 0 {
+			return fmt.Errorf("metrics.latency_buckets must be positive, got %v", bucket)
+		}
+		if i > 0 && bucket <= c.Metrics.LatencyBuckets[i-1] {
+			return fmt.Errorf("metrics.latency_buckets must be sorted in increasing order, got %v after %v", bucket, c.Metrics.LatencyBuckets[i-1])
+		}
+	}
+	for name, interval := range c.Metrics.CollectorIntervals {
+		if interval.Duration <= 0 {
+			return fmt.Errorf("metrics.collector_intervals.%s must be positive, got %s", name, interval.Duration)
+		}
+	}
+	if c.Metrics.CPUSampleWindow.Duration < 0 {
+		return fmt.Errorf("metrics.cpu_sample_window must not be negative, got %s", c.Metrics.CPUSampleWindow.Duration)
+	}
+	if c.Metrics.CPUSampleWindow.Duration > 0 && c.Metrics.CPUSampleWindow.Duration >= c.Metrics.CommandTimeout.Duration {
+		return fmt.Errorf("metrics.cpu_sample_window must be shorter than metrics.command_timeout, got %s", c.Metrics.CPUSampleWindow.Duration)
+	}
+	if c.Metrics.StartupDelay.Duration < 0 {
+		return fmt.Errorf("metrics.startup_delay must not be negative, got %s", c.Metrics.StartupDelay.Duration)
+	}
+	if c.Metrics.StartupRetries < 0 {
+		return fmt.Errorf("metrics.startup_retries must not be negative, got %d", c.Metrics.StartupRetries)
+	}
+	for i, rule := range c.Metrics.Relabel {
+		if !labelNameRe.MatchString(rule.SourceLabel) {
+			return fmt.Errorf("metrics.relabel entry %d source_label %q is not a valid label name", i, rule.SourceLabel)
+		}
+		switch rule.Action {
+		case RelabelRename:
+			if !labelNameRe.MatchString(rule.TargetLabel) {
+				return fmt.Errorf("metrics.relabel entry %d target_label %q is not a valid label name", i, rule.TargetLabel)
+			}
+		case RelabelDrop:
+			if rule.TargetLabel != "" {
+				return fmt.Errorf("metrics.relabel entry %d drops %q and must not have a target_label", i, rule.SourceLabel)
+			}
+		default:
+			return fmt.Errorf("metrics.relabel entry %d action must be %s or %s, got %q", i, RelabelRename, RelabelDrop, rule.Action)
+		}
+		if _, err := regexp.Compile(rule.MetricPattern); err != nil {
+			return fmt.Errorf("metrics.relabel entry %d metric_pattern %q is not a valid regular expression: %w", i, rule.MetricPattern, err)
+		}
+	}
+	for name, help := range c.Metrics.HelpOverrides {
+		if !metricNameRe.MatchString(name) {
+			return fmt.Errorf("metrics.help_overrides key %q is not a valid metric name", name)
+		}
+		if strings.TrimSpace(help) == "" {
+			return fmt.Errorf("metrics.help_overrides.%s must not be empty", name)
+		}
+	}
+	if c.Metrics.RateWindow < 1 {
+		return fmt.Errorf("metrics.rate_window must be at least 1, got %d", c.Metrics.RateWindow)
+	}
+	if c.Containers.MaxPerCycle < 0 {
+		return fmt.Errorf("containers.max_per_cycle must not be negative, got %d", c.Containers.MaxPerCycle)
+	}
+	if c.Containers.StatsDelta && c.Containers.MaxPerCycle == 0 {
+		return fmt.Errorf("containers.stats_delta requires containers.max_per_cycle")
+	}
+	if c.Containers.MaxReported < 0 {
+		return fmt.Errorf("containers.max_reported must not be negative, got %d", c.Containers.MaxReported)
+	}
+	if c.Metrics.MaxInterfaces < 0 {
+		return fmt.Errorf("metrics.max_interfaces must not be negative, got %d", c.Metrics.MaxInterfaces)
+	}
+	if c.Containers.RuntimeRetries < 0 {
+		return fmt.Errorf("containers.runtime_retries must not be negative, got %d", c.Containers.RuntimeRetries)
+	}
+	if c.Containers.StatsConcurrency < 1 {
+		return fmt.Errorf("containers.stats_concurrency must be at least 1, got %d", c.Containers.StatsConcurrency)
+	}
+	for _, pattern := range c.Containers.IgnoredNamePatterns {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("containers.ignored_name_patterns entry %q is not a valid regular expression: %w", pattern, err)
+		}
+	}
+	for _, pattern := range c.Network.IgnoredInterfacePatterns {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("network.ignored_interface_patterns entry %q is not a valid regular expression: %w", pattern, err)
+		}
+	}
+	pingNames := make(map[string]bool, len(c.Network.PingTargets))
+	for i, target := range c.Network.PingTargets {
+		if target.Address == "" {
+			return fmt.Errorf("network.ping_targets entry %d must have an address", i)
+		}
+		// The name is the target label, so a duplicate would have two targets overwrite each other's series
+		if pingNames[target.Name] {
+			return fmt.Errorf("network.ping_targets name %q is used more than once", target.Name)
+		}
+		pingNames[target.Name] = true
+	}
+	for i, target := range c.Network.HTTPTargets {
+		if u, err := url.Parse(target.Address); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("network.http_targets entry %d must have an http or https URL as its address, got %q", i, target.Address)
+		}
+	}
+	names := make(map[string]bool, len(c.Targets))
+	for i := range c.Targets {
+		target := &c.Targets[i]
+		if target.Name == "" {
+			return fmt.Errorf("targets entry %d must have a name", i)
+		}
+		if names[target.Name] {
+			return fmt.Errorf("targets name %q is used more than once", target.Name)
+		}
+		names[target.Name] = true
+
+		if target.Executor == "" {
+			target.Executor = ExecutorLocal
+		}
+		if target.Executor != ExecutorLocal && target.Executor != ExecutorSSH {
+			return fmt.Errorf("targets %q executor must be %s or %s, got %q", target.Name, ExecutorLocal, ExecutorSSH, target.Executor)
+		}
+		if target.Executor == ExecutorSSH && target.SSHHost == "" {
+			return fmt.Errorf("targets %q uses the ssh executor but has no ssh_host", target.Name)
+		}
+		if _, err := c.ForTarget(*target); err != nil {
+			return err
+		}
+	}
+	if c.Containers.DockerPath == "" || c.Containers.PodmanPath == "" {
+		return fmt.Errorf("containers.docker_path and containers.podman_path must not be empty")
+	}
+	if c.Network.PingPath == "" {
+		return fmt.Errorf("network.ping_path must not be empty")
+	}
+	if c.Network.PingTimeout.Duration <= 0 {
+		return fmt.Errorf("network.ping_timeout must be positive, got %s", c.Network.PingTimeout.Duration)
+	}
+	if c.Network.PingBackend != PingBackendPing && c.Network.PingBackend != PingBackendFPing {
+		return fmt.Errorf("network.ping_backend must be %s or %s, got %q", PingBackendPing, PingBackendFPing, c.Network.PingBackend)
+	}
+	switch c.Network.InterfaceSource {
+	case InterfaceSourceProc, InterfaceSourceIP, InterfaceSourceAuto:
+	default:
+		return fmt.Errorf("network.interface_source must be %s, %s or %s, got %q", InterfaceSourceProc, InterfaceSourceIP, InterfaceSourceAuto, c.Network.InterfaceSource)
+	}
+	if c.Metrics.CommandTimeout.Duration > 0 && c.Network.PingTimeout.Duration >= c.Metrics.CommandTimeout.Duration {
+		return fmt.Errorf("network.ping_timeout (%s) must be smaller than metrics.command_timeout (%s)", c.Network.PingTimeout.Duration, c.Metrics.CommandTimeout.Duration)
+	}
+	for _, env := range c.Executor.Env {
+		if !strings.Contains(env, "=") || strings.HasPrefix(env, "=") {
+			return fmt.Errorf("executor.env entry %q must be in KEY=VALUE form", env)
+		}
+	}
+	if len(c.Comparison.Metrics) > 0 {
+		if !names[c.Comparison.Baseline] || !names[c.Comparison.Candidate] {
+			return fmt.Errorf("comparison.baseline and comparison.candidate must be names of targets, got %q and %q", c.Comparison.Baseline, c.Comparison.Candidate)
+		}
+		if c.Comparison.Baseline == c.Comparison.Candidate {
+			return fmt.Errorf("comparison.baseline and comparison.candidate must differ, both are %q", c.Comparison.Baseline)
+		}
+	}
+	if _, err := zapcore.ParseLevel(c.Logging.Level); err != nil {
+		return fmt.Errorf("logging.level: %w", err)
+	}
+	for name, level := range c.Logging.Levels {
+		if _, err := zapcore.ParseLevel(level); err != nil {
+			return fmt.Errorf("logging.levels.%s: %w", name, err)
+		}
+	}
+	if c.Logging.Format != LogFormatJSON && c.Logging.Format != LogFormatConsole {
+		return fmt.Errorf("logging.format must be %s or %s, got %q", LogFormatJSON, LogFormatConsole, c.Logging.Format)
+	}
+	if c.Textfile.Directory != "" && c.Textfile.Interval.Duration <= 0 {
+		return fmt.Errorf("textfile.interval must be positive, got %s", c.Textfile.Interval.Duration)
+	}
+	for _, upstream := range c.Federation.Upstreams {
+		if parsed, err := url.Parse(upstream); err != nil || parsed.Scheme == "" || parsed.Host == "" {
+			return fmt.Errorf("federation.upstreams entry %q is not an absolute URL", upstream)
+		}
+	}
+	return nil
+}
+
 // LoadFromJSON loads configuration from a JSON file
+// A file may name a base file in "extends", which is loaded first, see loadJSONSections
 func LoadFromJSON(path string) (*Config, error) {
-	// Create empty config
-	config := &Config{}
+	// Create config with defaults, values from the file override them
+	config := New()
 
-	// Open the JSON file
-	file, err := os.Open(path)
+	sections, err := loadJSONSections(path, nil)
 	if err != nil {
-		return nil, err // Fail if file doesn't exist
+		return nil, err
+	}
+	// The profile's defaults go in before decoding, so keys set in the file still win
+	if raw, ok := sections["profile"]; ok {
+		var profile string
+		if err := json.Unmarshal(raw, &profile); err != nil {
+			return nil, fmt.Errorf("profile must be a string: %w", err)
+		}
+		config.applyProfile(profile)
+	}
+	data, err := json.Marshal(sections)
+	if err != nil {
+		return nil, err
 	}
-	defer file.Close()
 
 	// Decode JSON into config struct
-	decoder := json.NewDecoder(file)
+	decoder := json.NewDecoder(bytes.NewReader(data))
 	decoder.DisallowUnknownFields() // Fail on unknown fields
 
 	if err := decoder.Decode(config); err != nil {
 		return nil, err
 	}
 
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
 	return config, nil
 }
+
+// loadJSONSections reads the top-level sections of a configuration file, merged over those of the file it extends
+// The merge is shallow: a section the file sets replaces the whole section of the base file,
+// e.g. a "containers" object in the file discards every containers key of the base file.
+// The extends path is relative to the directory of the file naming it, and bases may extend further bases
+// Args:
+// - path: string, the file to read
+// - chain: []string, the absolute paths of the files that extend this one, to detect cycles
+// Returns:
+// - map[string]json.RawMessage: the merged sections, without "extends"
+// - error: error if a file can't be read or parsed, or the extends chain has a cycle
+func loadJSONSections(path string, chain []string) (map[string]json.RawMessage, error) {
+	absolute, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, seen := range chain {
+		if seen == absolute {
+			return nil, fmt.Errorf("config extends cycle: %s -> %s", strings.Join(chain, " -> "), absolute)
+		}
+	}
+	chain = append(chain, absolute)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if len(chain) > 1 {
+			return nil, fmt.Errorf("%s extends %s: %w", chain[len(chain)-2], path, err)
+		}
+		return nil, err // Fail if file doesn't exist
+	}
+	var sections map[string]json.RawMessage
+	if err := json.Unmarshal(data, &sections); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if sections == nil {
+		sections = make(map[string]json.RawMessage) // The file is a JSON null
+	}
+
+	raw, ok := sections["extends"]
+	if !ok {
+		return sections, nil
+	}
+	delete(sections, "extends")
+
+	var base string
+	if err := json.Unmarshal(raw, &base); err != nil || base == "" {
+		return nil, fmt.Errorf("%s: extends must be a non-empty file path", path)
+	}
+	if !filepath.IsAbs(base) {
+		base = filepath.Join(filepath.Dir(absolute), base)
+	}
+	merged, err := loadJSONSections(base, chain)
+	if err != nil {
+		return nil, err
+	}
+	for name, section := range sections {
+		merged[name] = section
+	}
+	return merged, nil
+}
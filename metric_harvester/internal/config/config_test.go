@@ -0,0 +1,225 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRelabelRuleUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      string
+		wantAction string
+		wantErr    bool
+	}{
+		{name: "action defaults to rename", input: `{"source_label": "interface", "target_label": "device"}`, wantAction: RelabelRename},
+		{name: "explicit drop", input: `{"action": "drop", "source_label": "runtime"}`, wantAction: RelabelDrop},
+		{name: "unknown key", input: `{"source_lable": "runtime"}`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var rule RelabelRule
+			err := json.Unmarshal([]byte(tt.input), &rule)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want error %v", err, tt.wantErr)
+			}
+			if rule.Action != tt.wantAction {
+				t.Errorf("action = %q, want %q", rule.Action, tt.wantAction)
+			}
+		})
+	}
+}
+
+func TestValidatePingTargetNames(t *testing.T) {
+	tests := []struct {
+		name    string
+		targets []PingTarget
+		wantErr bool
+	}{
+		{name: "unique names", targets: []PingTarget{{Name: "gateway", Address: "10.0.0.1"}, {Name: "8.8.8.8", Address: "8.8.8.8"}}},
+		{name: "duplicate name", targets: []PingTarget{{Name: "dns", Address: "8.8.8.8"}, {Name: "dns", Address: "1.1.1.1"}}, wantErr: true},
+		{name: "name matching another's address", targets: []PingTarget{{Name: "8.8.8.8", Address: "8.8.8.8"}, {Name: "8.8.8.8", Address: "1.1.1.1"}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := New()
+			cfg.Network.PingTargets = tt.targets
+			err := cfg.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// writeConfigFiles writes the files, keyed by path relative to a temporary directory, and returns the directory
+func writeConfigFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestLoadFromJSONExtends(t *testing.T) {
+	base, err := os.ReadFile("configurations.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		files   map[string]string
+		load    string
+		wantErr string
+		check   func(t *testing.T, cfg *Config)
+	}{
+		{
+			name: "chain of bases with a relative path",
+			files: map[string]string{
+				"base.json":           string(base),
+				"rootless.json":       `{"extends": "base.json", "logging": {"level": "debug"}}`,
+				"hosts/vm1/host.json": `{"extends": "../../rootless.json", "server": {"port": ":9090"}}`,
+			},
+			load: "hosts/vm1/host.json",
+			check: func(t *testing.T, cfg *Config) {
+				if cfg.Server.Port != ":9090" {
+					t.Errorf("server.port = %q, want :9090 from the file itself", cfg.Server.Port)
+				}
+				if cfg.Logging.Level != "debug" {
+					t.Errorf("logging.level = %q, want debug from rootless.json", cfg.Logging.Level)
+				}
+				// The server section of host.json replaces base.json's whole section, so its keys fall back to defaults
+				if cfg.Server.ReadTimeout.Duration != 0 {
+					t.Errorf("server.read_timeout = %s, want the base's section replaced", cfg.Server.ReadTimeout.Duration)
+				}
+				if !cfg.Metrics.EnableSystemMetrics {
+					t.Error("metrics.enable_system_metrics not inherited from base.json")
+				}
+			},
+		},
+		{
+			name: "cycle",
+			files: map[string]string{
+				"a.json": `{"extends": "b.json"}`,
+				"b.json": `{"extends": "a.json"}`,
+			},
+			load:    "a.json",
+			wantErr: "config extends cycle",
+		},
+		{
+			name:    "missing base",
+			files:   map[string]string{"a.json": `{"extends": "missing.json"}`},
+			load:    "a.json",
+			wantErr: "missing.json: no such file",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeConfigFiles(t, tt.files)
+			cfg, err := LoadFromJSON(filepath.Join(dir, tt.load))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			tt.check(t, cfg)
+		})
+	}
+}
+
+func TestProfilePrecedence(t *testing.T) {
+	tests := []struct {
+		name string
+		// config is the configuration file, which leaves everything else at the defaults
+		config string
+		// target is the config of a target, empty to check the top-level configuration
+		target      string
+		wantDocker  bool
+		wantPodman  bool
+		wantIgnored []string
+	}{
+		{
+			name:        "file profile",
+			config:      `{"profile": "rootless"}`,
+			wantPodman:  true,
+			wantIgnored: []string{"^veth"},
+		},
+		{
+			name: "file keys win over the file profile",
+			config: `{"profile": "rootless", "containers": {"docker_enabled": true},
+				"network": {"ignored_interface_patterns": ["^tap"]}}`,
+			wantDocker:  true,
+			wantPodman:  true,
+			wantIgnored: []string{"^tap"},
+		},
+		{
+			name:        "target profile wins over inherited file keys",
+			config:      `{"containers": {"docker_enabled": true, "podman_enabled": false}}`,
+			target:      `{"profile": "rootless"}`,
+			wantPodman:  true,
+			wantIgnored: []string{"^veth"},
+		},
+		{
+			name:   "target keys win over the target profile",
+			config: `{"profile": "rootful"}`,
+			target: `{"profile": "rootless", "containers": {"docker_enabled": true},
+				"network": {"ignored_interface_patterns": []}}`,
+			wantDocker:  true,
+			wantPodman:  true,
+			wantIgnored: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sections map[string]json.RawMessage
+			if err := json.Unmarshal([]byte(tt.config), &sections); err != nil {
+				t.Fatal(err)
+			}
+			if tt.target != "" {
+				sections["targets"] = json.RawMessage(`[{"name": "vm", "config": ` + tt.target + `}]`)
+			}
+			config, err := json.Marshal(sections)
+			if err != nil {
+				t.Fatal(err)
+			}
+			dir := writeConfigFiles(t, map[string]string{"host.json": string(config)})
+
+			cfg, err := LoadFromJSON(filepath.Join(dir, "host.json"))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.target != "" {
+				if cfg, err = cfg.ForTarget(cfg.Targets[0]); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			}
+
+			if cfg.Containers.DockerEnabled != tt.wantDocker || cfg.Containers.PodmanEnabled != tt.wantPodman {
+				t.Errorf("docker_enabled, podman_enabled = %v, %v, want %v, %v",
+					cfg.Containers.DockerEnabled, cfg.Containers.PodmanEnabled, tt.wantDocker, tt.wantPodman)
+			}
+			if strings.Join(cfg.Network.IgnoredInterfacePatterns, ",") != strings.Join(tt.wantIgnored, ",") {
+				t.Errorf("ignored_interface_patterns = %q, want %q", cfg.Network.IgnoredInterfacePatterns, tt.wantIgnored)
+			}
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package server
+
+import (
+	"os/exec"
+
+	"metric_harvester/internal/collectors"
+
+	"go.uber.org/zap"
+)
+
+// missingCommands returns the required commands of a collector that aren't found on the PATH
+func missingCommands(collector collectors.Collector) []string {
+	var missing []string
+	for _, command := range collector.RequiredCommands() {
+		if _, err := exec.LookPath(command); err != nil {
+			missing = append(missing, command)
+		}
+	}
+	return missing
+}
+
+// probeRequiredCommands checks that the commands of every collector are installed
+// Collectors with missing commands still run but will be degraded, which is logged once here
+// rather than as a failure every cycle
+func (s *Server) probeRequiredCommands() {
+	for _, collector := range s.collectors {
+		missing := missingCommands(collector)
+		if len(missing) == 0 {
+			continue
+		}
+
+		s.logger.Warn("Collector will be degraded, required commands are missing",
+			zap.String("collector", collector.Name()),
+			zap.Strings("missing", missing),
+		)
+	}
+}
@@ -0,0 +1,124 @@
+package server
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// comparisonGatherer adds comparison_throughput_ratio to what gatherer returns when a comparison is configured
+// The ratio is computed from the gathered families, so it always matches the values served next to it
+func (s *Server) comparisonGatherer(gatherer prometheus.Gatherer) prometheus.Gatherer {
+	if len(s.config.Comparison.Metrics) == 0 {
+		return gatherer
+	}
+
+	return prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+		families, err := gatherer.Gather()
+		if err != nil {
+			return families, err
+		}
+
+		ratios, err := s.compareTargets(families, time.Now())
+		if err != nil {
+			return families, err
+		}
+		families = append(families, ratios...)
+		sort.Slice(families, func(i, j int) bool {
+			return families[i].GetName() < families[j].GetName()
+		})
+		return families, nil
+	})
+}
+
+// compareTargets computes candidate / baseline for each compared metric from the gathered families
+// A ratio is only emitted when both targets collected within two collection intervals and the baseline is non-zero,
+// so a stalled target doesn't freeze a stale ratio on the dashboard
+func (s *Server) compareTargets(families []*dto.MetricFamily, now time.Time) ([]*dto.MetricFamily, error) {
+	baseline, candidate := s.config.Comparison.Baseline, s.config.Comparison.Candidate
+
+	ratios := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "comparison_throughput_ratio",
+			Help: "Candidate target's value of the metric divided by the baseline target's, summed over their series",
+		},
+		[]string{"metric", "baseline", "candidate"},
+	)
+
+	lastCollections := lastTargetCollections(families)
+	maxAge := 2 * s.config.Metrics.CollectionInterval.Duration
+	if now.Sub(lastCollections[baseline]) <= maxAge && now.Sub(lastCollections[candidate]) <= maxAge {
+		byName := make(map[string]*dto.MetricFamily, len(families))
+		for _, family := range families {
+			byName[family.GetName()] = family
+		}
+
+		for _, name := range s.config.Comparison.Metrics {
+			family, ok := byName[name]
+			if !ok {
+				continue
+			}
+			sums := sumByHost(family)
+			if sums[baseline] == 0 {
+				continue
+			}
+			ratios.WithLabelValues(name, baseline, candidate).Set(sums[candidate] / sums[baseline])
+		}
+	}
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(ratios)
+	return registry.Gather()
+}
+
+// lastTargetCollections returns the latest successful collection of any collector of each target
+// It reads harvester_last_collection_timestamp_seconds, whose collector label is <target>/<collector>
+func lastTargetCollections(families []*dto.MetricFamily) map[string]time.Time {
+	last := make(map[string]time.Time)
+	for _, family := range families {
+		if family.GetName() != "harvester_last_collection_timestamp_seconds" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			target, _, ok := strings.Cut(labelValue(metric, "collector"), "/")
+			if !ok {
+				continue
+			}
+			collected := time.Unix(0, int64(metric.GetGauge().GetValue()*float64(time.Second)))
+			if collected.After(last[target]) {
+				last[target] = collected
+			}
+		}
+	}
+	return last
+}
+
+// sumByHost sums the counter, gauge and untyped series of a family per host label
+func sumByHost(family *dto.MetricFamily) map[string]float64 {
+	sums := make(map[string]float64)
+	for _, metric := range family.GetMetric() {
+		host := labelValue(metric, "host")
+		switch family.GetType() {
+		case dto.MetricType_COUNTER:
+			sums[host] += metric.GetCounter().GetValue()
+		case dto.MetricType_GAUGE:
+			sums[host] += metric.GetGauge().GetValue()
+		case dto.MetricType_UNTYPED:
+			sums[host] += metric.GetUntyped().GetValue()
+		}
+	}
+	return sums
+}
+
+// labelValue returns the value of the named label of a metric, or "" when it doesn't have it
+func labelValue(metric *dto.Metric, name string) string {
+	for _, label := range metric.GetLabel() {
+		if label.GetName() == name {
+			return label.GetValue()
+		}
+	}
+	return ""
+}
@@ -0,0 +1,141 @@
+package server
+
+import (
+	"encoding/csv"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"go.uber.org/zap"
+)
+
+// csvTimestampFormat is understood by spreadsheets and keeps millisecond precision
+const csvTimestampFormat = "2006-01-02T15:04:05.000Z07:00"
+
+// csvHandler serves the gathered metrics as CSV
+// A write error, e.g. the client going away, can only be logged since the response has already started
+func csvHandler(w http.ResponseWriter, r *http.Request, gatherer prometheus.Gatherer, logger *zap.Logger) {
+	families, err := gatherer.Gather()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	if err := WriteCSV(w, families, time.Now()); err != nil {
+		logger.Warn("Failed to write /metrics.csv", zap.Error(err))
+	}
+}
+
+// WriteCSV writes the metric families as CSV with one row per sample
+// The columns are name,labels,value,timestamp, e.g.:
+// "system_cpu_usage_percent,type=""user"",12.5,2024-01-02T15:04:05.000Z"
+// Histograms and summaries are flattened into their _bucket, _sum and _count samples like in the text format.
+// Samples without their own timestamp get the gather time.
+func WriteCSV(w io.Writer, families []*dto.MetricFamily, gatheredAt time.Time) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write([]string{"name", "labels", "value", "timestamp"}); err != nil {
+		return err
+	}
+
+	for _, family := range families {
+		for _, metric := range family.GetMetric() {
+			timestamp := gatheredAt
+			if metric.TimestampMs != nil {
+				timestamp = time.UnixMilli(metric.GetTimestampMs())
+			}
+			ts := timestamp.UTC().Format(csvTimestampFormat)
+
+			for _, sample := range flattenMetric(family, metric) {
+				if err := writer.Write([]string{sample.name, sample.labels, formatCSVValue(sample.value), ts}); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// csvSample is a single row of the CSV export
+type csvSample struct {
+	name   string
+	labels string
+	value  float64
+}
+
+// flattenMetric returns the samples of a metric, one for counters and gauges and several for histograms and summaries
+func flattenMetric(family *dto.MetricFamily, metric *dto.Metric) []csvSample {
+	name := family.GetName()
+	labels := metric.GetLabel()
+
+	switch family.GetType() {
+	case dto.MetricType_COUNTER:
+		return []csvSample{{name, formatLabels(labels), metric.GetCounter().GetValue()}}
+	case dto.MetricType_GAUGE:
+		return []csvSample{{name, formatLabels(labels), metric.GetGauge().GetValue()}}
+	case dto.MetricType_UNTYPED:
+		return []csvSample{{name, formatLabels(labels), metric.GetUntyped().GetValue()}}
+	case dto.MetricType_HISTOGRAM:
+		histogram := metric.GetHistogram()
+		buckets := histogram.GetBucket()
+		var samples []csvSample
+		for _, bucket := range buckets {
+			le := formatCSVValue(bucket.GetUpperBound())
+			samples = append(samples, csvSample{name + "_bucket", formatLabels(labels, "le", le), float64(bucket.GetCumulativeCount())})
+		}
+		// The +Inf bucket is implicit in the client's histograms, but federated ones can carry it explicitly
+		if len(buckets) == 0 || !math.IsInf(buckets[len(buckets)-1].GetUpperBound(), 1) {
+			samples = append(samples, csvSample{name + "_bucket", formatLabels(labels, "le", "+Inf"), float64(histogram.GetSampleCount())})
+		}
+		samples = append(samples,
+			csvSample{name + "_sum", formatLabels(labels), histogram.GetSampleSum()},
+			csvSample{name + "_count", formatLabels(labels), float64(histogram.GetSampleCount())},
+		)
+		return samples
+	case dto.MetricType_SUMMARY:
+		summary := metric.GetSummary()
+		var samples []csvSample
+		for _, quantile := range summary.GetQuantile() {
+			q := formatCSVValue(quantile.GetQuantile())
+			samples = append(samples, csvSample{name, formatLabels(labels, "quantile", q), quantile.GetValue()})
+		}
+		samples = append(samples,
+			csvSample{name + "_sum", formatLabels(labels), summary.GetSampleSum()},
+			csvSample{name + "_count", formatLabels(labels), float64(summary.GetSampleCount())},
+		)
+		return samples
+	}
+	return nil
+}
+
+// formatLabels formats labels as name="value" pairs sorted by name, with an optional extra pair like le or quantile
+func formatLabels(labels []*dto.LabelPair, extra ...string) string {
+	pairs := make([]string, 0, len(labels)+1)
+	for _, label := range labels {
+		pairs = append(pairs, label.GetName()+"="+strconv.Quote(label.GetValue()))
+	}
+	if len(extra) == 2 {
+		pairs = append(pairs, extra[0]+"="+strconv.Quote(extra[1]))
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// formatCSVValue formats a sample value the way the Prometheus text format does
+func formatCSVValue(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
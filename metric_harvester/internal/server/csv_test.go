@@ -0,0 +1,54 @@
+package server
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestWriteCSVHistogramInfBucket(t *testing.T) {
+	name, sum := "test_seconds", 1.0
+	histogramType := dto.MetricType_HISTOGRAM
+	histogram := func(bounds ...float64) []*dto.MetricFamily {
+		buckets := make([]*dto.Bucket, 0, len(bounds))
+		for i, bound := range bounds {
+			bound, count := bound, uint64(i+1)
+			buckets = append(buckets, &dto.Bucket{UpperBound: &bound, CumulativeCount: &count})
+		}
+		count := uint64(len(bounds))
+		return []*dto.MetricFamily{{
+			Name: &name,
+			Type: &histogramType,
+			Metric: []*dto.Metric{{Histogram: &dto.Histogram{
+				SampleCount: &count,
+				SampleSum:   &sum,
+				Bucket:      buckets,
+			}}},
+		}}
+	}
+
+	tests := []struct {
+		name     string
+		families []*dto.MetricFamily
+	}{
+		{name: "implicit +Inf bucket", families: histogram(0.1, 1)},
+		{name: "explicit +Inf bucket", families: histogram(0.1, 1, math.Inf(1))},
+		{name: "no buckets", families: histogram()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := WriteCSV(&buf, tt.families, time.Now()); err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.Count(buf.String(), `le=""+Inf""`); got != 1 {
+				t.Errorf("got %d +Inf bucket rows, want 1:\n%s", got, buf.String())
+			}
+		})
+	}
+}
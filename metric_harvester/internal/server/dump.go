@@ -0,0 +1,102 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+)
+
+// writerSink writes the snapshot it receives to a writer in the Prometheus text format or as CSV, used by --once
+type writerSink struct {
+	w      io.Writer
+	format string
+}
+
+// NewWriterSink creates a sink writing to w in the given format, prom or csv
+// Args:
+// - w: the writer, e.g. os.Stdout
+// - format: prom for the Prometheus text format or csv, see WriteCSV
+// Returns:
+// - Sink: new writer sink
+// - error: error if the format is unknown
+func NewWriterSink(w io.Writer, format string) (Sink, error) {
+	if format != "prom" && format != "csv" {
+		return nil, fmt.Errorf("unknown format %q, expected prom or csv", format)
+	}
+	return &writerSink{w: w, format: format}, nil
+}
+
+func (s *writerSink) Name() string {
+	return s.format
+}
+
+// Write implements Sink, writing the snapshot in the sink's format
+func (s *writerSink) Write(_ context.Context, families []*dto.MetricFamily) error {
+	if s.format == "csv" {
+		return WriteCSV(s.w, families, time.Now())
+	}
+	return writeText(s.w, families)
+}
+
+// dumpSink writes the snapshot it receives to a timestamped metrics-<time>.prom file, used on SIGUSR1
+type dumpSink struct {
+	dir  string
+	path string
+}
+
+func (d *dumpSink) Name() string {
+	return "dump"
+}
+
+// Write implements Sink, creating the file and writing the snapshot in the Prometheus text format
+func (d *dumpSink) Write(_ context.Context, families []*dto.MetricFamily) error {
+	if err := os.MkdirAll(d.dir, 0o755); err != nil {
+		return err
+	}
+
+	path := filepath.Join(d.dir, fmt.Sprintf("metrics-%s.prom", time.Now().UTC().Format("20060102T150405Z")))
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if err := writeText(file, families); err != nil {
+		file.Close()
+		return err
+	}
+	// Close reports write errors that were buffered by the filesystem, e.g. a full disk or an NFS results path
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	d.path = path
+	return nil
+}
+
+// DumpMetrics publishes the current metrics to the sinks and to a timestamped file in dir, see dumpSink
+// Returns:
+// - string: path of the written file
+// - error: error if writing the file fails
+func (s *Server) DumpMetrics(ctx context.Context, dir string) (string, error) {
+	sink := &dumpSink{dir: dir}
+	if err := s.publish(ctx, sink); err != nil {
+		return "", err
+	}
+	return sink.path, nil
+}
+
+// writeText writes the metric families in the Prometheus text format
+func writeText(w io.Writer, families []*dto.MetricFamily) error {
+	for _, family := range families {
+		if _, err := expfmt.MetricFamilyToText(w, family); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package server
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"metric_harvester/internal/config"
+	"metric_harvester/internal/utils"
+)
+
+func TestMetricsGzip(t *testing.T) {
+	cfg := config.New()
+	logger := zap.NewNop()
+	s, err := New(&ServerParams{
+		Config:   cfg,
+		Logger:   logger,
+		Executor: utils.NewSystemCommandExecutor(logger, cfg),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		path        string
+		gzip        bool
+		wantContent string
+	}{
+		{name: "metrics plain", path: "/metrics", wantContent: "harvester_collection_cycles_total"},
+		{name: "metrics gzip", path: "/metrics", gzip: true, wantContent: "harvester_collection_cycles_total"},
+		{name: "csv plain", path: "/metrics.csv", wantContent: "name,labels,value,timestamp"},
+		{name: "csv gzip", path: "/metrics.csv", gzip: true, wantContent: "name,labels,value,timestamp"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.gzip {
+				req.Header.Set("Accept-Encoding", "gzip")
+			}
+			rec := httptest.NewRecorder()
+			s.httpServer.Handler.ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("got status %d, want 200", rec.Code)
+			}
+			encoding := rec.Header().Get("Content-Encoding")
+			body := io.Reader(rec.Body)
+			if tt.gzip {
+				if encoding != "gzip" {
+					t.Fatalf("got Content-Encoding %q, want gzip", encoding)
+				}
+				reader, err := gzip.NewReader(rec.Body)
+				if err != nil {
+					t.Fatalf("body isn't gzip: %v", err)
+				}
+				defer reader.Close()
+				body = reader
+			} else if encoding != "" {
+				t.Fatalf("got Content-Encoding %q without Accept-Encoding, want none", encoding)
+			}
+
+			data, err := io.ReadAll(body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(string(data), tt.wantContent) {
+				t.Errorf("body doesn't contain %q:\n%s", tt.wantContent, data)
+			}
+		})
+	}
+}
@@ -0,0 +1,151 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// deepHealthTimeout bounds each dependency check so a hung runtime fails the check instead of the request
+const deepHealthTimeout = 3 * time.Second
+
+// deepHealthCacheTTL is how long a deep health report is served before the dependencies are checked again,
+// so frequent probes don't run docker, podman and ping on every request
+const deepHealthCacheTTL = 10 * time.Second
+
+// healthCheck is the result of checking a single dependency of a target
+type healthCheck struct {
+	Dependency string  `json:"dependency"`
+	Host       string  `json:"host,omitempty"`
+	OK         bool    `json:"ok"`
+	Error      string  `json:"error,omitempty"`
+	Duration   float64 `json:"duration_seconds"`
+}
+
+// healthReport is the /health response, Checks is only filled in deep mode
+type healthReport struct {
+	Status    string        `json:"status"`
+	Timestamp string        `json:"timestamp"`
+	Checks    []healthCheck `json:"checks,omitempty"`
+}
+
+// deepHealth caches the last deep health report
+type deepHealth struct {
+	mu        sync.Mutex
+	targets   []target
+	report    healthReport
+	checkedAt time.Time
+}
+
+// healthHandler serves /health
+// Without parameters it only shows that the process is alive. With ?deep=1 it checks that each target can
+// actually collect: the docker and podman runtimes answer and the first ping target replies. This tells a dead
+// rootless podman socket apart from a dead harvester. It responds with 503 when any check fails
+func healthHandler(w http.ResponseWriter, r *http.Request, deep *deepHealth) {
+	report := healthReport{Status: "healthy", Timestamp: time.Now().UTC().Format(time.RFC3339)}
+	if r.URL.Query().Get("deep") == "1" {
+		report = deep.check(r.Context())
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if report.Status != "healthy" {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(report)
+}
+
+// check returns the cached report, checking the dependencies again once it is older than deepHealthCacheTTL
+// Concurrent requests wait for the check in progress instead of starting their own.
+// The report is shared, so the checks run detached from the request, each bounded by deepHealthTimeout; a probe
+// that disconnects or times out early would otherwise cache failures for every request until the TTL passes.
+// The targets are checked concurrently, so the report takes as long as the slowest target rather than their sum
+func (d *deepHealth) check(ctx context.Context) healthReport {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if time.Since(d.checkedAt) < deepHealthCacheTTL {
+		return d.report
+	}
+
+	ctx = context.WithoutCancel(ctx)
+
+	results := make([][]healthCheck, len(d.targets))
+	var wg sync.WaitGroup
+	for i := range d.targets {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = d.targets[i].checkHealth(ctx)
+		}(i)
+	}
+	wg.Wait()
+
+	report := healthReport{Status: "healthy", Timestamp: time.Now().UTC().Format(time.RFC3339)}
+	for _, checks := range results {
+		for _, check := range checks {
+			if !check.OK {
+				report.Status = "unhealthy"
+			}
+			report.Checks = append(report.Checks, check)
+		}
+	}
+
+	d.report = report
+	d.checkedAt = time.Now()
+	return report
+}
+
+// checkHealth checks the enabled container runtimes and the network of the target, concurrently
+// The commands it runs are:
+// - docker version --format {{.Server.Version}} (or GET /info when the Docker API socket is configured)
+// - podman info --format {{.Version.Version}}
+// - ping -c 1 <first ping target>
+func (t target) checkHealth(ctx context.Context) []healthCheck {
+	cfg := t.deps.Config
+	executor := t.deps.Executor
+
+	type dependencyCheck struct {
+		dependency string
+		check      func(context.Context) ([]byte, error)
+	}
+	var checks []dependencyCheck
+
+	if cfg.Containers.DockerEnabled {
+		check := executor.GetDockerServerVersion
+		if t.deps.DockerAPI != nil {
+			check = t.deps.DockerAPI.GetInfo
+		}
+		checks = append(checks, dependencyCheck{"docker", check})
+	}
+	if cfg.Containers.PodmanEnabled {
+		checks = append(checks, dependencyCheck{"podman", executor.GetPodmanVersion})
+	}
+	if cfg.Metrics.EnableNetworkMetrics && len(cfg.Network.PingTargets) > 0 {
+		checks = append(checks, dependencyCheck{"network", func(ctx context.Context) ([]byte, error) {
+			return executor.PingHost(ctx, cfg.Network.PingTargets[0].Address, 1)
+		}})
+	}
+
+	results := make([]healthCheck, len(checks))
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		go func(i int, c dependencyCheck) {
+			defer wg.Done()
+			checkCtx, cancel := context.WithTimeout(ctx, deepHealthTimeout)
+			defer cancel()
+			start := time.Now()
+			_, err := c.check(checkCtx)
+
+			result := healthCheck{Dependency: c.dependency, Host: t.name, OK: err == nil, Duration: time.Since(start).Seconds()}
+			if err != nil {
+				result.Error = err.Error()
+			}
+			results[i] = result
+		}(i, c)
+	}
+	wg.Wait()
+	return results
+}
@@ -0,0 +1,73 @@
+package server
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// descNameRe extracts the metric name from a Desc's string form, which has no accessor
+var descNameRe = regexp.MustCompile(`fqName: "([^"]+)"`)
+
+// helpGatherer replaces the help text of the metrics named in metrics.help_overrides in what gatherer returns
+// Like relabeling it applies after gathering, so the collectors keep their built-in help strings
+func (s *Server) helpGatherer(gatherer prometheus.Gatherer) prometheus.Gatherer {
+	overrides := s.config.Metrics.HelpOverrides
+	if len(overrides) == 0 {
+		return gatherer
+	}
+
+	return prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+		families, err := gatherer.Gather()
+		for _, family := range families {
+			if help, ok := overrides[family.GetName()]; ok {
+				family.Help = &help
+			}
+		}
+		return families, err
+	})
+}
+
+// checkHelpOverrides checks that every metric named in metrics.help_overrides is one the server emits
+// The names come from the descriptors of the registered collectors, so metrics without samples yet are known too.
+// Federated metrics are only known once an upstream is scraped, so names with the federation prefix aren't checked
+// Args:
+// - registered: the collectors registered with the server's registry
+// Returns:
+// - error: error naming the overrides of unknown metrics
+func (s *Server) checkHelpOverrides(registered []prometheus.Collector) error {
+	if len(s.config.Metrics.HelpOverrides) == 0 {
+		return nil
+	}
+
+	known := map[string]bool{"comparison_throughput_ratio": len(s.config.Comparison.Metrics) > 0}
+	descs := make(chan *prometheus.Desc)
+	go func() {
+		for _, collector := range registered {
+			collector.Describe(descs)
+		}
+		close(descs)
+	}()
+	for desc := range descs {
+		if matches := descNameRe.FindStringSubmatch(desc.String()); matches != nil {
+			known[matches[1]] = true
+		}
+	}
+
+	var unknown []string
+	for name := range s.config.Metrics.HelpOverrides {
+		federated := len(s.config.Federation.Upstreams) > 0 && strings.HasPrefix(name, s.config.Federation.MetricPrefix)
+		if !known[name] && !federated {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("metrics.help_overrides names unknown metrics: %s", strings.Join(unknown, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package server
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+
+	"metric_harvester/internal/config"
+)
+
+func TestCheckHelpOverrides(t *testing.T) {
+	// A vector without samples yet, its name is only known from its descriptor
+	registered := []prometheus.Collector{
+		prometheus.NewGauge(prometheus.GaugeOpts{Name: "system_uptime_seconds", Help: "Uptime"}),
+		prometheus.NewCounterVec(prometheus.CounterOpts{Name: "harvester_commands_total", Help: "Commands"}, []string{"command"}),
+	}
+
+	tests := []struct {
+		name      string
+		overrides map[string]string
+		upstreams bool
+		wantErr   string
+	}{
+		{name: "no overrides"},
+		{
+			name:      "known metrics",
+			overrides: map[string]string{"system_uptime_seconds": "Seconds since boot", "harvester_commands_total": "Commands run"},
+		},
+		{
+			name:      "unknown metrics are listed sorted",
+			overrides: map[string]string{"system_uptime_seconds": "Seconds since boot", "zz_missing": "x", "aa_missing": "y"},
+			wantErr:   "unknown metrics: aa_missing, zz_missing",
+		},
+		{
+			name:      "federated metric with upstreams",
+			overrides: map[string]string{"federated_up": "Upstream up"},
+			upstreams: true,
+		},
+		{
+			name:      "federated metric without upstreams",
+			overrides: map[string]string{"federated_up": "Upstream up"},
+			wantErr:   "unknown metrics: federated_up",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.New()
+			cfg.Metrics.HelpOverrides = tt.overrides
+			cfg.Federation.MetricPrefix = "federated_"
+			if tt.upstreams {
+				cfg.Federation.Upstreams = []string{"http://localhost:9100/metrics"}
+			}
+			s := &Server{config: cfg, logger: zap.NewNop()}
+
+			err := s.checkHelpOverrides(registered)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestHelpGatherer(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(
+		prometheus.NewGauge(prometheus.GaugeOpts{Name: "system_uptime_seconds", Help: "Uptime"}),
+		prometheus.NewGauge(prometheus.GaugeOpts{Name: "system_load", Help: "Load"}),
+	)
+
+	cfg := config.New()
+	cfg.Metrics.HelpOverrides = map[string]string{"system_uptime_seconds": "Seconds since boot"}
+	s := &Server{config: cfg, logger: zap.NewNop()}
+
+	families, err := s.helpGatherer(registry).Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"system_uptime_seconds": "Seconds since boot", "system_load": "Load"}
+	for _, family := range families {
+		if family.GetHelp() != want[family.GetName()] {
+			t.Errorf("%s help = %q, want %q", family.GetName(), family.GetHelp(), want[family.GetName()])
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Collection statuses recorded in the history
+const (
+	statusOK      = "ok"
+	statusPartial = "partial"
+	statusFailed  = "failed"
+)
+
+// cycleSummary is the outcome of a single collection cycle
+// With the ticker each collector runs on its own interval, so a cycle holds a single collector
+type cycleSummary struct {
+	Timestamp  time.Time         `json:"timestamp"`
+	Duration   string            `json:"duration"`
+	Collectors map[string]string `json:"collectors"` // collector name -> ok, partial or failed
+}
+
+// cycleHistory is a bounded ring buffer of the most recent cycle summaries
+// It is written by the collection goroutines and read by /history, so all access is locked
+type cycleHistory struct {
+	mu      sync.Mutex
+	entries []cycleSummary
+	next    int
+	full    bool
+}
+
+// newCycleHistory creates a history keeping the last size cycles, a size of 0 keeps none
+func newCycleHistory(size int) *cycleHistory {
+	return &cycleHistory{entries: make([]cycleSummary, size)}
+}
+
+// add records a cycle, overwriting the oldest one once the buffer is full
+func (h *cycleHistory) add(summary cycleSummary) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if len(h.entries) == 0 {
+		return
+	}
+	h.entries[h.next] = summary
+	h.next = (h.next + 1) % len(h.entries)
+	if h.next == 0 {
+		h.full = true
+	}
+}
+
+// snapshot returns a copy of the recorded cycles, oldest first
+func (h *cycleHistory) snapshot() []cycleSummary {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if !h.full {
+		return append([]cycleSummary{}, h.entries[:h.next]...)
+	}
+	return append(append([]cycleSummary{}, h.entries[h.next:]...), h.entries[:h.next]...)
+}
+
+// historyHandler serves the recent cycle summaries as JSON, oldest first
+func historyHandler(w http.ResponseWriter, r *http.Request, history *cycleHistory) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(history.snapshot())
+}
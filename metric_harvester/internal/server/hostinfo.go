@@ -0,0 +1,124 @@
+package server
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"metric_harvester/internal/utils"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+// hostInfoUnknown is the label value of a host property that couldn't be determined, e.g. on macOS
+const hostInfoUnknown = "unknown"
+
+// hostInfo is harvester_host_info, describing the environment factors that drive rootless overhead
+// The properties are read once, on the first Collect, since they don't change while the harvester runs.
+// Reading them lazily keeps the commands, several ssh round trips for a remote target, out of startup
+type hostInfo struct {
+	executor *utils.SystemCommandExecutor
+	timeout  time.Duration
+	logger   *zap.Logger
+
+	once sync.Once
+	info *prometheus.GaugeVec
+}
+
+// newHostInfo creates harvester_host_info
+// Args:
+// - executor: the executor of the host being described
+// - timeout: the command timeout for reading the properties
+// - logger: logger for properties that couldn't be read
+// Returns:
+// - *hostInfo: a collector of a gauge set to 1 with the properties as labels
+func newHostInfo(executor *utils.SystemCommandExecutor, timeout time.Duration, logger *zap.Logger) *hostInfo {
+	return &hostInfo{
+		executor: executor,
+		timeout:  timeout,
+		logger:   logger,
+		info: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "harvester_host_info",
+				Help: "Kernel version, unprivileged user namespace support and cgroup version of the host",
+			},
+			[]string{"kernel", "unprivileged_userns", "cgroup_version"},
+		),
+	}
+}
+
+// Describe implements the prometheus.Collector interface
+func (h *hostInfo) Describe(ch chan<- *prometheus.Desc) {
+	h.info.Describe(ch)
+}
+
+// Collect implements the prometheus.Collector interface, reading the properties on the first call
+func (h *hostInfo) Collect(ch chan<- prometheus.Metric) {
+	h.once.Do(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), h.timeout)
+		defer cancel()
+
+		h.info.WithLabelValues(
+			kernelVersion(ctx, h.executor, h.logger),
+			unprivilegedUserns(ctx, h.executor),
+			cgroupVersion(ctx, h.executor, h.logger),
+		).Set(1)
+	})
+	h.info.Collect(ch)
+}
+
+// kernelVersion gets the kernel release
+// The command it runs is:
+// - uname -r
+func kernelVersion(ctx context.Context, executor *utils.SystemCommandExecutor, logger *zap.Logger) string {
+	output, err := executor.Execute(ctx, "uname", "-r")
+	if err != nil {
+		logger.Warn("Failed to get the kernel version for harvester_host_info", zap.Error(err))
+		return hostInfoUnknown
+	}
+	return strings.TrimSpace(string(output))
+}
+
+// unprivilegedUserns reports whether unprivileged users can create user namespaces, which rootless containers need
+// Either sysctl may be missing, unprivileged_userns_clone only exists on Debian-patched kernels.
+// It is unknown when neither can be read
+// The commands it runs are:
+// - cat /proc/sys/kernel/unprivileged_userns_clone
+// - cat /proc/sys/user/max_user_namespaces
+func unprivilegedUserns(ctx context.Context, executor *utils.SystemCommandExecutor) string {
+	result := hostInfoUnknown
+	for _, sysctl := range []string{"/proc/sys/kernel/unprivileged_userns_clone", "/proc/sys/user/max_user_namespaces"} {
+		output, err := executor.Execute(ctx, "cat", sysctl)
+		if err != nil {
+			continue
+		}
+		// Either one set to 0 disables them
+		if strings.TrimSpace(string(output)) == "0" {
+			return "false"
+		}
+		result = "true"
+	}
+	return result
+}
+
+// cgroupVersion gets the cgroup version from the filesystem type mounted at /sys/fs/cgroup
+// cgroup2fs is the unified v2 hierarchy, tmpfs holds the v1 hierarchies, including hybrid setups
+// The command it runs is:
+// - stat -fc %T /sys/fs/cgroup
+func cgroupVersion(ctx context.Context, executor *utils.SystemCommandExecutor, logger *zap.Logger) string {
+	output, err := executor.Execute(ctx, "stat", "-fc", "%T", "/sys/fs/cgroup")
+	if err != nil {
+		logger.Debug("Failed to get the cgroup version for harvester_host_info", zap.Error(err))
+		return hostInfoUnknown
+	}
+
+	switch strings.TrimSpace(string(output)) {
+	case "cgroup2fs":
+		return "v2"
+	case "tmpfs":
+		return "v1"
+	}
+	return hostInfoUnknown
+}
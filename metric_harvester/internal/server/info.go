@@ -0,0 +1,103 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"metric_harvester/internal/version"
+
+	"go.uber.org/zap"
+)
+
+// infoResponse is the response of the /info endpoint
+// The fields up to collection_interval are the original ones and are kept for compatibility
+type infoResponse struct {
+	Service            string    `json:"service"`
+	Collectors         int       `json:"collectors"`
+	DockerEnabled      bool      `json:"docker_enabled"`
+	PodmanEnabled      bool      `json:"podman_enabled"`
+	CollectionInterval string    `json:"collection_interval"`
+	Version            string    `json:"version"`
+	Commit             string    `json:"commit"`
+	StartTime          time.Time `json:"start_time"`
+	Uptime             string    `json:"uptime"`
+	CollectorNames     []string  `json:"collector_names"`
+	Profile            string    `json:"profile,omitempty"`
+	// CPUContention is keyed by target name, "local" without configured targets
+	CPUContention map[string]cpuContention `json:"cpu_contention,omitempty"`
+}
+
+// cpuContention is a host's CPU steal and iowait in the last collection, from system_cpu_usage_percent
+// High steal means the hypervisor ran other guests on the VM's cores, high iowait that the disk held the CPU back,
+// either of which makes a benchmark run on that host unrepresentative
+type cpuContention struct {
+	StealPercent  float64 `json:"steal_percent"`
+	IOWaitPercent float64 `json:"iowait_percent"`
+}
+
+// infoHandler reports a snapshot of the running harvester: its build, how long it has run and what it collects
+// It is the first thing to check when verifying a deployment, e.g. on the rootless VM
+func infoHandler(w http.ResponseWriter, r *http.Request, s *Server) {
+	names := make([]string, 0, len(s.collectors))
+	for _, collector := range s.collectors {
+		names = append(names, collector.Name())
+	}
+
+	build := version.Get()
+	info := infoResponse{
+		Service:            "metric_harvester",
+		Collectors:         len(s.collectors),
+		DockerEnabled:      s.config.Containers.DockerEnabled,
+		PodmanEnabled:      s.config.Containers.PodmanEnabled,
+		CollectionInterval: s.config.Metrics.CollectionInterval.Duration.String(),
+		Version:            build.Version,
+		Commit:             build.Commit,
+		StartTime:          s.startTime,
+		Uptime:             time.Since(s.startTime).Round(time.Second).String(),
+		CollectorNames:     names,
+		Profile:            s.config.Profile,
+		CPUContention:      s.cpuContention(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(info)
+}
+
+// cpuContention reads the steal and iowait of each target from the registry, before relabeling
+// Targets whose CPU source doesn't report them, e.g. macOS or the /proc/self/stat fallback, are left out
+func (s *Server) cpuContention() map[string]cpuContention {
+	families, err := s.registry.Gather()
+	if err != nil {
+		s.logger.Debug("Failed to gather metrics for /info", zap.Error(err))
+	}
+
+	var contention map[string]cpuContention
+	for _, family := range families {
+		if family.GetName() != "system_cpu_usage_percent" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			cpuType := labelValue(metric, "type")
+			if cpuType != "steal" && cpuType != "iowait" {
+				continue
+			}
+			host := labelValue(metric, "host")
+			if host == "" {
+				host = "local"
+			}
+			if contention == nil {
+				contention = make(map[string]cpuContention)
+			}
+			hostContention := contention[host]
+			if cpuType == "steal" {
+				hostContention.StealPercent = metric.GetGauge().GetValue()
+			} else {
+				hostContention.IOWaitPercent = metric.GetGauge().GetValue()
+			}
+			contention[host] = hostContention
+		}
+	}
+	return contention
+}
@@ -0,0 +1,69 @@
+package server
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// harvesterMetrics holds metrics describing the harvester itself rather than the host
+type harvesterMetrics struct {
+	// Prometheus metrics
+	// collectionOverruns: number of cycles that took longer than the overrun threshold of their interval
+	// lastCollection: unix time each collector last completed without any failure, used to detect stalled collectors
+	// collectionCycles: number of completed collection cycles, a heartbeat independent of collector success
+	// With the ticker each collector runs on its own interval, so every collector run counts as a cycle
+	// collectionSkipped: number of ticks skipped per collector because its previous collection was still running
+	collectionOverruns prometheus.Counter
+	lastCollection     *prometheus.GaugeVec
+	collectionCycles   prometheus.Counter
+	collectionSkipped  *prometheus.CounterVec
+}
+
+// newHarvesterMetrics creates a new harvesterMetrics
+// Returns:
+// - *harvesterMetrics: new harvesterMetrics instance
+func newHarvesterMetrics() *harvesterMetrics {
+	return &harvesterMetrics{
+		collectionOverruns: prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Name: "harvester_collection_overrun_total",
+				Help: "Number of collection cycles that exceeded the overrun threshold of the collection interval",
+			},
+		),
+		lastCollection: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "harvester_last_collection_timestamp_seconds",
+				Help: "Unix time at which each collector last collected without any failure",
+			},
+			[]string{"collector"},
+		),
+		collectionCycles: prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Name: "harvester_collection_cycles_total",
+				Help: "Number of completed collection cycles",
+			},
+		),
+		collectionSkipped: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "harvester_collection_skipped_total",
+				Help: "Number of collection ticks skipped because the collector's previous collection was still running",
+			},
+			[]string{"collector"},
+		),
+	}
+}
+
+// Describe implements the prometheus.Collector interface
+func (m *harvesterMetrics) Describe(ch chan<- *prometheus.Desc) {
+	m.collectionOverruns.Describe(ch)
+	m.lastCollection.Describe(ch)
+	m.collectionCycles.Describe(ch)
+	m.collectionSkipped.Describe(ch)
+}
+
+// Collect implements the prometheus.Collector interface
+func (m *harvesterMetrics) Collect(ch chan<- prometheus.Metric) {
+	m.collectionOverruns.Collect(ch)
+	m.lastCollection.Collect(ch)
+	m.collectionCycles.Collect(ch)
+	m.collectionSkipped.Collect(ch)
+}
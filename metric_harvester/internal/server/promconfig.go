@@ -0,0 +1,87 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"metric_harvester/internal/config"
+)
+
+// prometheusConfigHandler returns a Prometheus scrape_configs snippet that scrapes this instance
+// The address is the host of server.port, or the host the request was sent to when server.port only has a port,
+// so curling the endpoint from the Prometheus host gives an address that host can reach.
+// The job is named metric-harvester-<mode> when server.instance_labels has a mode, so the snippets of the rootful
+// and rootless hosts can be pasted into the same file
+func prometheusConfigHandler(w http.ResponseWriter, r *http.Request, cfg *config.Config) {
+	host, port, err := net.SplitHostPort(cfg.Server.Port)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("server.port %q has no port: %v", cfg.Server.Port, err), http.StatusInternalServerError)
+		return
+	}
+	if host == "" || net.ParseIP(host).IsUnspecified() {
+		host = r.Host
+		if requestHost, _, err := net.SplitHostPort(r.Host); err == nil {
+			host = requestHost
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(prometheusScrapeConfig(net.JoinHostPort(host, port), cfg)))
+}
+
+// prometheusScrapeConfig renders the scrape_configs snippet of an instance at address
+// Strings are double-quoted with strconv.Quote, whose escapes are valid YAML
+// Example:
+//
+//	scrape_configs:
+//	  - job_name: "metric-harvester-rootless"
+//	    scrape_interval: 5s
+//	    metrics_path: /metrics
+//	    scheme: http
+//	    static_configs:
+//	      - targets: ["vm1:8080"]
+//	        labels:
+//	          mode: "rootless"
+func prometheusScrapeConfig(address string, cfg *config.Config) string {
+	job := "metric-harvester"
+	if mode := cfg.Server.InstanceLabels["mode"]; mode != "" {
+		job += "-" + mode
+	}
+
+	var b strings.Builder
+	b.WriteString("scrape_configs:\n")
+	fmt.Fprintf(&b, "  - job_name: %s\n", strconv.Quote(job))
+	// Scraping more often than the collectors run only returns the same values again
+	fmt.Fprintf(&b, "    scrape_interval: %s\n", prometheusDuration(cfg.Metrics.CollectionInterval.Duration.Seconds()))
+	b.WriteString("    metrics_path: /metrics\n")
+	b.WriteString("    scheme: http\n")
+	b.WriteString("    static_configs:\n")
+	fmt.Fprintf(&b, "      - targets: [%s]\n", strconv.Quote(address))
+
+	if len(cfg.Server.InstanceLabels) > 0 {
+		names := make([]string, 0, len(cfg.Server.InstanceLabels))
+		for name := range cfg.Server.InstanceLabels {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		b.WriteString("        labels:\n")
+		for _, name := range names {
+			fmt.Fprintf(&b, "          %s: %s\n", name, strconv.Quote(cfg.Server.InstanceLabels[name]))
+		}
+	}
+	return b.String()
+}
+
+// prometheusDuration formats seconds as a Prometheus duration, which has no fractional units: 1500ms rather than 1.5s
+func prometheusDuration(seconds float64) string {
+	if seconds == float64(int64(seconds)) {
+		return strconv.FormatInt(int64(seconds), 10) + "s"
+	}
+	return strconv.FormatInt(int64(seconds*1000), 10) + "ms"
+}
@@ -0,0 +1,110 @@
+package server
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"metric_harvester/internal/config"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// relabelRule is a metrics.relabel rule with its metric pattern compiled
+type relabelRule struct {
+	config.RelabelRule
+	metrics *regexp.Regexp
+}
+
+// relabelGatherer applies metrics.relabel to what gatherer returns
+// Relabeling after gathering keeps the collectors and the comparison, which reads the host label, unaware of it
+func (s *Server) relabelGatherer(gatherer prometheus.Gatherer) prometheus.Gatherer {
+	if len(s.config.Metrics.Relabel) == 0 {
+		return gatherer
+	}
+
+	// The patterns were validated when the configuration was loaded
+	rules := make([]relabelRule, 0, len(s.config.Metrics.Relabel))
+	for _, rule := range s.config.Metrics.Relabel {
+		rules = append(rules, relabelRule{RelabelRule: rule, metrics: regexp.MustCompile(rule.MetricPattern)})
+	}
+
+	return prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+		families, err := gatherer.Gather()
+		for _, family := range families {
+			relabelFamily(family, rules)
+		}
+		return families, err
+	})
+}
+
+// relabelFamily applies the rules matching the family's name to each of its metrics
+// Renaming onto an existing label replaces it. When a rule makes two metrics identical, e.g. by dropping the only
+// label that told them apart, the first one is kept, since a scraper rejects duplicate series
+func relabelFamily(family *dto.MetricFamily, rules []relabelRule) {
+	var matching []relabelRule
+	for _, rule := range rules {
+		if rule.metrics.MatchString(family.GetName()) {
+			matching = append(matching, rule)
+		}
+	}
+	if len(matching) == 0 {
+		return
+	}
+
+	seen := make(map[string]bool, len(family.Metric))
+	metrics := family.Metric[:0]
+	for _, metric := range family.Metric {
+		for _, rule := range matching {
+			metric.Label = relabel(metric.Label, rule)
+		}
+		sort.Slice(metric.Label, func(i, j int) bool {
+			return metric.Label[i].GetName() < metric.Label[j].GetName()
+		})
+
+		key := labelsKey(metric.Label)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		metrics = append(metrics, metric)
+	}
+	family.Metric = metrics
+}
+
+// relabel applies a single rule to a metric's labels
+func relabel(labels []*dto.LabelPair, rule relabelRule) []*dto.LabelPair {
+	var source *dto.LabelPair
+	for _, label := range labels {
+		if label.GetName() == rule.SourceLabel {
+			source = label
+		}
+	}
+	if source == nil {
+		return labels
+	}
+
+	result := make([]*dto.LabelPair, 0, len(labels))
+	for _, label := range labels {
+		if label == source || (rule.Action == config.RelabelRename && label.GetName() == rule.TargetLabel) {
+			continue
+		}
+		result = append(result, label)
+	}
+	if rule.Action == config.RelabelRename {
+		name, value := rule.TargetLabel, source.GetValue()
+		result = append(result, &dto.LabelPair{Name: &name, Value: &value})
+	}
+	return result
+}
+
+// labelsKey identifies a metric within its family by its sorted labels
+func labelsKey(labels []*dto.LabelPair) string {
+	var key strings.Builder
+	for _, label := range labels {
+		fmt.Fprintf(&key, "%s=%q,", label.GetName(), label.GetValue())
+	}
+	return key.String()
+}
@@ -0,0 +1,97 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+
+	"metric_harvester/internal/config"
+)
+
+func TestRelabelGatherer(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules []config.RelabelRule
+		// want is the label sets of the network_interface_up series, in gather order
+		want []map[string]string
+		// wantOther is the label set of the system_disk_usage series, which no pattern-restricted rule matches
+		wantOther map[string]string
+	}{
+		{
+			name:      "rename",
+			rules:     []config.RelabelRule{{Action: config.RelabelRename, SourceLabel: "interface", TargetLabel: "device"}},
+			want:      []map[string]string{{"device": "eth0", "runtime": "docker"}, {"device": "eth0", "runtime": "podman"}},
+			wantOther: map[string]string{"mountpoint": "/"},
+		},
+		{
+			name:      "rename onto an existing label replaces it",
+			rules:     []config.RelabelRule{{Action: config.RelabelRename, SourceLabel: "interface", TargetLabel: "runtime"}},
+			want:      []map[string]string{{"runtime": "eth0"}},
+			wantOther: map[string]string{"mountpoint": "/"},
+		},
+		{
+			name: "drop merges the series it makes identical",
+			rules: []config.RelabelRule{
+				{Action: config.RelabelDrop, SourceLabel: "runtime"},
+			},
+			want:      []map[string]string{{"interface": "eth0"}},
+			wantOther: map[string]string{"mountpoint": "/"},
+		},
+		{
+			name: "metric pattern",
+			rules: []config.RelabelRule{
+				{Action: config.RelabelRename, SourceLabel: "mountpoint", TargetLabel: "path", MetricPattern: "^system_"},
+				{Action: config.RelabelRename, SourceLabel: "interface", TargetLabel: "device", MetricPattern: "^system_"},
+			},
+			want:      []map[string]string{{"interface": "eth0", "runtime": "docker"}, {"interface": "eth0", "runtime": "podman"}},
+			wantOther: map[string]string{"path": "/"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			up := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "network_interface_up", Help: "Up"}, []string{"interface", "runtime"})
+			up.WithLabelValues("eth0", "docker").Set(1)
+			up.WithLabelValues("eth0", "podman").Set(0)
+			disk := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "system_disk_usage", Help: "Disk"}, []string{"mountpoint"})
+			disk.WithLabelValues("/").Set(50)
+			registry := prometheus.NewRegistry()
+			registry.MustRegister(up, disk)
+
+			cfg := config.New()
+			cfg.Metrics.Relabel = tt.rules
+			s := &Server{config: cfg, logger: zap.NewNop()}
+
+			families, err := s.relabelGatherer(registry).Gather()
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, family := range families {
+				want := tt.want
+				if family.GetName() == "system_disk_usage" {
+					want = []map[string]string{tt.wantOther}
+				}
+				if len(family.Metric) != len(want) {
+					t.Fatalf("%s has %d series, want %d", family.GetName(), len(family.Metric), len(want))
+				}
+				for i, metric := range family.Metric {
+					got := make(map[string]string, len(metric.Label))
+					for _, label := range metric.Label {
+						got[label.GetName()] = label.GetValue()
+					}
+					if len(got) != len(want[i]) {
+						t.Errorf("%s series %d labels = %v, want %v", family.GetName(), i, got, want[i])
+						continue
+					}
+					for name, value := range want[i] {
+						if got[name] != value {
+							t.Errorf("%s series %d labels = %v, want %v", family.GetName(), i, got, want[i])
+							break
+						}
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,189 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+// reportHighlightPercent is the difference from which a row of the comparison report is highlighted
+const reportHighlightPercent = 10
+
+// reportSections are the sections of the comparison report, a metric goes into the first whose pattern matches its name
+// The harvester's own metrics are left out, they describe the harvester rather than the compared hosts
+var reportSections = []struct {
+	title   string
+	pattern *regexp.Regexp
+}{
+	{"Throughput", regexp.MustCompile(`_per_second$|throughput`)},
+	{"Latency", regexp.MustCompile(`latency|_milliseconds$|_duration_seconds$`)},
+	{"CPU", regexp.MustCompile(`cpu`)},
+	{"Memory", regexp.MustCompile(`memory`)},
+}
+
+// reportRow is a series of the comparison report, missing in a snapshot when its value there is nil
+type reportRow struct {
+	name      string
+	labels    string
+	baseline  *float64
+	candidate *float64
+}
+
+// WriteComparisonReport writes a Markdown report of the differences between two metric snapshots, e.g. the --once
+// outputs of the rootful and the rootless host, replacing pasting benchmark numbers side by side by hand.
+// Series are matched by name and labels and grouped into throughput, latency, CPU and memory sections. Histograms and
+// summaries are compared by their mean. Differences are (candidate - baseline) / baseline, those of at least
+// reportHighlightPercent are in bold
+// Args:
+// - w: io.Writer the report is written to
+// - baselineName, candidateName: string, the column titles, e.g. rootful and rootless
+// - baseline, candidate: []*dto.MetricFamily, the snapshots
+// Returns:
+// - error: error if writing fails
+func WriteComparisonReport(w io.Writer, baselineName, candidateName string, baseline, candidate []*dto.MetricFamily) error {
+	rows := make(map[string]*reportRow)
+	add := func(families []*dto.MetricFamily, isBaseline bool) {
+		for _, family := range families {
+			if reportSection(family.GetName()) < 0 {
+				continue
+			}
+			for _, metric := range family.GetMetric() {
+				value, ok := reportValue(family, metric)
+				if !ok {
+					continue
+				}
+				labels := formatLabels(metric.GetLabel())
+				key := family.GetName() + "{" + labels + "}"
+				row, ok := rows[key]
+				if !ok {
+					row = &reportRow{name: family.GetName(), labels: labels}
+					rows[key] = row
+				}
+				if isBaseline {
+					row.baseline = &value
+				} else {
+					row.candidate = &value
+				}
+			}
+		}
+	}
+	add(baseline, true)
+	add(candidate, false)
+
+	sections := make([][]*reportRow, len(reportSections))
+	for _, row := range rows {
+		section := reportSection(row.name)
+		sections[section] = append(sections[section], row)
+	}
+
+	var report strings.Builder
+	fmt.Fprintf(&report, "# Comparison: %s vs %s\n\n", baselineName, candidateName)
+	fmt.Fprintf(&report, "Differences are (%s - %s) / %s; those of %d%% or more are in **bold**. "+
+		"Histograms and summaries are compared by their mean.\n", candidateName, baselineName, baselineName, reportHighlightPercent)
+
+	empty := true
+	for i, section := range sections {
+		if len(section) == 0 {
+			continue
+		}
+		empty = false
+		sort.Slice(section, func(a, b int) bool {
+			if section[a].name != section[b].name {
+				return section[a].name < section[b].name
+			}
+			return section[a].labels < section[b].labels
+		})
+
+		fmt.Fprintf(&report, "\n## %s\n\n", reportSections[i].title)
+		fmt.Fprintf(&report, "| Metric | Labels | %s | %s | Difference |\n", baselineName, candidateName)
+		report.WriteString("|---|---|---:|---:|---:|\n")
+		for _, row := range section {
+			fmt.Fprintf(&report, "| %s | %s | %s | %s | %s |\n",
+				row.name, markdownCell(row.labels), formatReportValue(row.baseline), formatReportValue(row.candidate),
+				formatReportDifference(row.baseline, row.candidate))
+		}
+	}
+	if empty {
+		report.WriteString("\nNeither snapshot has throughput, latency, CPU or memory metrics.\n")
+	}
+
+	_, err := io.WriteString(w, report.String())
+	return err
+}
+
+// reportSection returns the index of the report section a metric belongs to, -1 when it is in none
+func reportSection(name string) int {
+	if strings.HasPrefix(name, "harvester_") {
+		return -1
+	}
+	for i, section := range reportSections {
+		if section.pattern.MatchString(name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// reportValue returns the value of a metric compared in the report, the mean for histograms and summaries
+func reportValue(family *dto.MetricFamily, metric *dto.Metric) (float64, bool) {
+	switch family.GetType() {
+	case dto.MetricType_COUNTER:
+		return metric.GetCounter().GetValue(), true
+	case dto.MetricType_GAUGE:
+		return metric.GetGauge().GetValue(), true
+	case dto.MetricType_UNTYPED:
+		return metric.GetUntyped().GetValue(), true
+	case dto.MetricType_HISTOGRAM:
+		histogram := metric.GetHistogram()
+		if histogram.GetSampleCount() == 0 {
+			return 0, false
+		}
+		return histogram.GetSampleSum() / float64(histogram.GetSampleCount()), true
+	case dto.MetricType_SUMMARY:
+		summary := metric.GetSummary()
+		if summary.GetSampleCount() == 0 {
+			return 0, false
+		}
+		return summary.GetSampleSum() / float64(summary.GetSampleCount()), true
+	}
+	return 0, false
+}
+
+// formatReportValue formats a value of the report, "-" when the series is missing from the snapshot
+func formatReportValue(value *float64) string {
+	if value == nil {
+		return "-"
+	}
+	return fmt.Sprintf("%.4g", *value)
+}
+
+// formatReportDifference formats the relative difference of the candidate to the baseline
+// It is "-" when either value is missing and n/a when only the baseline is 0
+func formatReportDifference(baseline, candidate *float64) string {
+	if baseline == nil || candidate == nil {
+		return "-"
+	}
+	if *baseline == 0 {
+		if *candidate == 0 {
+			return "+0.0%"
+		}
+		return "n/a"
+	}
+
+	percent := (*candidate - *baseline) / math.Abs(*baseline) * 100
+	difference := fmt.Sprintf("%+.1f%%", percent)
+	if math.Abs(percent) >= reportHighlightPercent {
+		return "**" + difference + "**"
+	}
+	return difference
+}
+
+// markdownCell escapes the pipes of a table cell, label values may contain them
+func markdownCell(value string) string {
+	return strings.ReplaceAll(value, "|", `\|`)
+}
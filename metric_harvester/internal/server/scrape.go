@@ -0,0 +1,47 @@
+package server
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// scrapeGatherer returns the gatherer served on /metrics, the live metrics of the Prometheus sink
+// With metrics.collect_on_scrape, each scrape first collects fresh metrics, so data is aligned to the scraper
+// instead of the ticker. Collection happens before gathering rather than lazily inside a prometheus.Collector,
+// since the registry collects all collectors concurrently and the others would serve the old values.
+// The collection runs under the scrape request's context, so it stops when the scraper gives up.
+// Configured target comparisons and metrics.relabel are applied as they are gathered.
+func (s *Server) scrapeGatherer(ctx context.Context) prometheus.Gatherer {
+	if !s.config.Metrics.CollectOnScrape {
+		return s.prometheus
+	}
+
+	return prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+		s.collectOnScrape(ctx)
+		return s.prometheus.Gather()
+	})
+}
+
+// collectOnScrape collects all the metrics unless the last collection is more recent than the collection interval
+// The debounce keeps frequent or concurrent scrapes from hammering the commands; concurrent scrapes wait for the
+// collection in progress and then serve its results. A collection cut short by its context doesn't count, so the
+// next scrape collects again
+func (s *Server) collectOnScrape(ctx context.Context) {
+	s.scrapeMu.Lock()
+	defer s.scrapeMu.Unlock()
+
+	if time.Since(s.lastScrapeCollection) < s.config.Metrics.CollectionInterval.Duration {
+		return
+	}
+
+	// Errors of the server's sinks are logged by publish
+	duration, _ := s.collectAllMetrics(ctx)
+	if ctx.Err() != nil {
+		return
+	}
+	s.checkOverrun(duration, s.config.Metrics.CollectionInterval.Duration)
+	s.lastScrapeCollection = time.Now()
+}
@@ -0,0 +1,138 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"metric_harvester/internal/collectors"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// newCollectors creates all the collectors enabled by the configuration
+// Args:
+// - deps: CollectorDependencies
+// Returns:
+// - []collectors.Collector: the collectors, in collection order
+func newCollectors(deps *collectors.CollectorDependencies) []collectors.Collector {
+	system_collector := collectors.NewSystemCollector(deps.Named("system"))
+	container_collector := collectors.NewContainerCollector(deps.Named("container"))
+	network_collector := collectors.NewNetworkCollector(deps.Named("network"))
+	protocol_collector := collectors.NewProtocolCollector(deps.Named("protocol"))
+	listening_ports_collector := collectors.NewListeningPortsCollector(deps.Named("listening_ports"))
+
+	result := []collectors.Collector{
+		system_collector,
+		container_collector,
+		network_collector,
+		protocol_collector,
+		listening_ports_collector,
+	}
+
+	if deps.Config.Metrics.EnablePSIMetrics {
+		psi_collector := collectors.NewPSICollector(deps.Named("psi"))
+		result = append(result, psi_collector)
+	}
+
+	// Federated upstream /metrics endpoints are only scraped when some are configured
+	if len(deps.Config.Federation.Upstreams) > 0 {
+		federation_collector := collectors.NewFederationCollector(deps.Named("federation"))
+		result = append(result, federation_collector)
+	}
+
+	// Collectors that depend on others run after them in a cycle
+	return collectors.MustOrderCollectors(result)
+}
+
+// selftestResult is the outcome of running a single collector once
+type selftestResult struct {
+	Collector string   `json:"collector"`
+	OK        bool     `json:"ok"`
+	Error     string   `json:"error,omitempty"`
+	Failed    []string `json:"failed,omitempty"`
+	Missing   []string `json:"missing_commands,omitempty"`
+	Series    int      `json:"series"`
+	Duration  string   `json:"duration"`
+}
+
+// selftestReport is the response of the /selftest endpoint
+type selftestReport struct {
+	OK         bool             `json:"ok"`
+	Collectors []selftestResult `json:"collectors"`
+}
+
+// selftestHandler runs every collector once and reports which succeeded
+// Fresh collectors are registered with throwaway registries so the long-lived gauges served on /metrics are not touched.
+// It is a deployment check, e.g. to confirm a rootless instance can reach docker/podman/ping before trusting its dashboards.
+// Responds 503 when any collector fails.
+func selftestHandler(w http.ResponseWriter, r *http.Request, targets []target) {
+	report := selftestReport{OK: true}
+
+	for _, target := range targets {
+		for _, collector := range target.newCollectors() {
+			result := runSelftest(r.Context(), collector, target.deps)
+			if !result.OK {
+				report.OK = false
+			}
+			report.Collectors = append(report.Collectors, result)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if !report.OK {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(report)
+}
+
+// runSelftest collects a single collector once with the command timeout
+// The collector is gathered from its own registry afterwards, which also validates what it exposes
+func runSelftest(ctx context.Context, collector collectors.Collector, deps *collectors.CollectorDependencies) selftestResult {
+	result := selftestResult{Collector: collector.Name(), OK: true}
+
+	// A collector missing commands isn't ready even if it happens to collect something
+	if result.Missing = missingCommands(collector); len(result.Missing) > 0 {
+		result.OK = false
+	}
+
+	registry := prometheus.NewRegistry()
+	if err := registry.Register(collector); err != nil {
+		result.OK = false
+		result.Error = err.Error()
+		return result
+	}
+
+	collectCtx, cancel := context.WithTimeout(ctx, deps.Config.Metrics.CommandTimeout.Duration)
+	defer cancel()
+
+	start := time.Now()
+	err := collector.CollectMetrics(collectCtx)
+	result.Duration = time.Since(start).String()
+
+	if err != nil {
+		result.OK = false
+		result.Error = err.Error()
+
+		var collectionErr *collectors.CollectionError
+		if errors.As(err, &collectionErr) {
+			result.Failed = collectionErr.FailedNames()
+		}
+	}
+
+	families, err := registry.Gather()
+	if err != nil {
+		result.OK = false
+		if result.Error != "" {
+			result.Error += "; "
+		}
+		result.Error += err.Error()
+	}
+	for _, family := range families {
+		result.Series += len(family.GetMetric())
+	}
+
+	return result
+}
@@ -2,13 +2,19 @@ package server
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
+	"sync"
+	"syscall"
 	"time"
 
 	"metric_harvester/internal/collectors"
 	"metric_harvester/internal/config"
 	"metric_harvester/internal/utils"
+	"metric_harvester/internal/version"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
@@ -22,13 +28,31 @@ type Server struct {
 	httpServer *http.Server
 	registry   *prometheus.Registry
 	collectors []collectors.Collector
+	targets    []target
+	metrics    *harvesterMetrics
+	history    *cycleHistory
+	// startTime is when the server was created, for the uptime on /info
+	startTime time.Time
+
+	// scrapeMu serializes scrape-triggered collections, lastScrapeCollection debounces them
+	scrapeMu             sync.Mutex
+	lastScrapeCollection time.Time
+
+	// sinks receive the metrics once per collection cycle, serialized by publishMu
+	// prometheus serves /metrics and is always the first, textfile is set when textfile.directory is and only
+	// receives metrics once the server starts, so one-shot exports don't write it
+	sinks      []Sink
+	publishMu  sync.Mutex
+	prometheus *prometheusSink
+	textfile   *textfileSink
 }
 
 // ServerParams is the parameters for the server
 type ServerParams struct {
-	Config   *config.Config
-	Logger   *zap.Logger
-	Executor *utils.SystemCommandExecutor
+	Config    *config.Config
+	Logger    *zap.Logger
+	Executor  *utils.SystemCommandExecutor
+	DockerAPI *utils.DockerAPIExecutor
 }
 
 // New creates a new server
@@ -36,95 +60,180 @@ type ServerParams struct {
 // - params: ServerParams
 // Returns:
 // - *Server: new Server instance
-func New(params *ServerParams) *Server {
+// - error: error if a target's configuration is invalid or metrics.help_overrides names a metric the server doesn't emit
+func New(params *ServerParams) (*Server, error) {
 	registry := prometheus.NewRegistry()
 
 	// Create collector dependencies
 	deps := &collectors.CollectorDependencies{
-		Executor: params.Executor,
-		Logger:   params.Logger,
-		Config:   params.Config,
+		Executor:      params.Executor,
+		Logger:        params.Logger,
+		Config:        params.Config,
+		DockerAPI:     params.DockerAPI,
+		ParseFailures: collectors.NewParseFailures(),
+	}
+
+	// The harvester's own memory cgroup, created before the collectors variable shadows the package
+	selfMemory := collectors.NewSelfCgroupMemory(params.Logger)
+
+	// Initialize the collectors of every target and register them with Prometheus
+	targets, err := newTargets(params, deps)
+	if err != nil {
+		return nil, err
+	}
+	var collectors []collectors.Collector
+	var registered []prometheus.Collector
+	for _, target := range targets {
+		targetCollectors := target.newCollectors()
+		registered = append(registered, target.register(registry, targetCollectors)...)
+		collectors = append(collectors, targetCollectors...)
 	}
 
-	// Initialize collectors
-	system_collector := collectors.NewSystemCollector(deps)
-	container_collector := collectors.NewContainerCollector(deps)
-	network_collector := collectors.NewNetworkCollector(deps)
+	// Register the harvester's own metrics
+	harvester_metrics := newHarvesterMetrics()
+	registry.MustRegister(harvester_metrics)
+	registry.MustRegister(selfMemory)
+	registered = append(registered, harvester_metrics, selfMemory)
 
-	// Register collectors with Prometheus
-	registry.MustRegister(system_collector)
-	registry.MustRegister(container_collector)
-	registry.MustRegister(network_collector)
+	s := &Server{
+		config:     params.Config,
+		logger:     params.Logger,
+		registry:   registry,
+		collectors: collectors,
+		targets:    targets,
+		metrics:    harvester_metrics,
+		history:    newCycleHistory(params.Config.Server.HistorySize),
+		startTime:  time.Now(),
+	}
+	if err := s.checkHelpOverrides(registered); err != nil {
+		return nil, err
+	}
 
-	collectors := []collectors.Collector{
-		system_collector,
-		container_collector,
-		network_collector,
+	// Sinks receiving the metrics once per collection cycle, the textfile sink is added by Start
+	s.prometheus = newPrometheusSink(s.Gatherer())
+	s.sinks = []Sink{s.prometheus}
+	if params.Config.Textfile.Directory != "" {
+		s.textfile = newTextfileSink(params.Config.Textfile.Directory, params.Config.Textfile.Interval.Duration)
 	}
 
 	// Create HTTP server
 	mux := http.NewServeMux()
 
 	// Prometheus metrics endpoint
-	mux.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{
-		EnableOpenMetrics: true,
+	// promhttp gzips the response when the scraper sends Accept-Encoding: gzip, which Prometheus does by default
+	// Scrapers can be allowed or denied by User-Agent with server.allowed_user_agents and server.denied_user_agents
+	userAgents := newUserAgentFilter(params.Config.Server.AllowedUserAgents, params.Config.Server.DeniedUserAgents, params.Logger)
+	// The handler is built per request so collect_on_scrape collects under the request's context
+	mux.Handle("/metrics", userAgents.handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		promhttp.HandlerFor(s.scrapeGatherer(r.Context()), promhttp.HandlerOpts{
+			EnableOpenMetrics: true,
+		}).ServeHTTP(w, r)
+	})))
+
+	// CSV export of the same metrics, for pasting into a spreadsheet
+	mux.HandleFunc("/metrics.csv", gzipHandler(func(w http.ResponseWriter, r *http.Request) {
+		csvHandler(w, r, s.scrapeGatherer(r.Context()), s.logger)
 	}))
 
-	// Health check endpoint
+	// History endpoint, summaries of the last server.history_size cycles
+	mux.HandleFunc("/history", func(w http.ResponseWriter, r *http.Request) {
+		historyHandler(w, r, s.history)
+	})
+
+	// Self-test endpoint, runs every collector once against a throwaway registry
+	mux.HandleFunc("/selftest", func(w http.ResponseWriter, r *http.Request) {
+		selftestHandler(w, r, targets)
+	})
+
+	// Health check endpoint, ?deep=1 also checks the container runtimes and the network
+	deep := &deepHealth{targets: targets}
 	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte(`{"status":"healthy","timestamp":"` + time.Now().UTC().Format(time.RFC3339) + `"}`))
+		healthHandler(w, r, deep)
 	})
 
-	// Info endpoint
+	// Info endpoint, the build, start time and uptime and the collectors that run
 	mux.HandleFunc("/info", func(w http.ResponseWriter, r *http.Request) {
+		infoHandler(w, r, s)
+	})
+
+	// Prometheus config endpoint, a scrape_configs snippet for this instance
+	mux.HandleFunc("/prometheus-config", func(w http.ResponseWriter, r *http.Request) {
+		prometheusConfigHandler(w, r, params.Config)
+	})
+
+	// Version endpoint, build metadata set through -ldflags
+	mux.HandleFunc("/version", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusOK)
-		info := fmt.Sprintf(`{
-			"service": "metric_harvester",
-			"collectors": %d,
-			"docker_enabled": %t,
-			"podman_enabled": %t,
-			"collection_interval": "%s"
-		}`,
-			len(collectors),
-			params.Config.Containers.DockerEnabled,
-			params.Config.Containers.PodmanEnabled,
-			params.Config.Metrics.CollectionInterval.Duration,
-		)
-		w.Write([]byte(info))
+		json.NewEncoder(w).Encode(version.Get())
 	})
 
-	httpServer := &http.Server{
-		Addr:         params.Config.Server.Port,
-		Handler:      mux,
-		ReadTimeout:  params.Config.Server.ReadTimeout.Duration,
-		WriteTimeout: params.Config.Server.WriteTimeout.Duration,
+	s.httpServer = &http.Server{
+		Addr:              params.Config.Server.Port,
+		Handler:           mux,
+		ReadTimeout:       params.Config.Server.ReadTimeout.Duration,
+		WriteTimeout:      params.Config.Server.WriteTimeout.Duration,
+		IdleTimeout:       params.Config.Server.IdleTimeout.Duration,
+		ReadHeaderTimeout: params.Config.Server.ReadHeaderTimeout.Duration,
 	}
 
-	return &Server{
-		config:     params.Config,
-		logger:     params.Logger,
-		httpServer: httpServer,
-		registry:   registry,
-		collectors: collectors,
-	}
+	return s, nil
+}
+
+// Gatherer returns the gatherer of all of the server's metrics, with comparisons, relabeling and help overrides
+// applied as on /metrics
+func (s *Server) Gatherer() prometheus.Gatherer {
+	return s.helpGatherer(s.relabelGatherer(s.comparisonGatherer(s.registry)))
+}
+
+// CollectOnce runs every collector a single time and publishes the metrics to sink along with the server's sinks,
+// for one-shot exports without the HTTP server
+// It returns the error of writing to sink
+func (s *Server) CollectOnce(ctx context.Context, sink Sink) error {
+	_, err := s.collectAllMetrics(ctx, sink)
+	return err
 }
 
 // Start starts the server
 func (s *Server) Start(ctx context.Context) error {
-	// Start metric collection in background
-	go s.startMetricCollection(ctx)
+	s.probeRequiredCommands()
+
+	// Added before collection starts and scrapes are served, so the sinks don't change while publish reads them
+	if s.textfile != nil {
+		s.logger.Info("Writing metrics for the node_exporter textfile collector",
+			zap.String("path", s.textfile.path),
+			zap.Duration("interval", s.config.Textfile.Interval.Duration),
+		)
+		s.sinks = append(s.sinks, s.textfile)
+		if s.config.Metrics.CollectOnScrape {
+			go s.collectForTextfileOnInterval(ctx)
+		}
+	}
+
+	// Start metric collection in background, unless scrapes trigger it
+	if s.config.Metrics.CollectOnScrape {
+		s.logger.Info("Collecting metrics on scrape",
+			zap.Duration("min_interval", s.config.Metrics.CollectionInterval.Duration),
+		)
+	} else {
+		go s.startMetricCollection(ctx)
+	}
 
 	s.logger.Info("Starting HTTP server",
 		zap.String("addr", s.httpServer.Addr),
 		zap.Duration("read_timeout", s.config.Server.ReadTimeout.Duration),
 		zap.Duration("write_timeout", s.config.Server.WriteTimeout.Duration),
+		zap.Duration("idle_timeout", s.config.Server.IdleTimeout.Duration),
+		zap.Duration("read_header_timeout", s.config.Server.ReadHeaderTimeout.Duration),
 	)
 
 	// Start HTTP server
-	if err := s.httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+	listener, err := s.listen()
+	if err != nil {
+		s.logger.Error("HTTP server failed", zap.Error(err))
+		return err
+	}
+	if err := s.httpServer.Serve(listener); err != nil && err != http.ErrServerClosed {
 		s.logger.Error("HTTP server failed", zap.Error(err))
 		return err
 	}
@@ -132,65 +241,284 @@ func (s *Server) Start(ctx context.Context) error {
 	return nil
 }
 
+// bindRetryDelay is the wait before the first retry of binding the server's address, doubled for each further retry
+const bindRetryDelay = 500 * time.Millisecond
+
+// listen binds the server's address, retrying up to server.bind_retries times while it is in use
+// The delay starts at bindRetryDelay and doubles, so a quick restart during a comparison run waits for the
+// previous process to release the port instead of exiting
+func (s *Server) listen() (net.Listener, error) {
+	delay := bindRetryDelay
+	for attempt := 0; ; attempt++ {
+		listener, err := net.Listen("tcp", s.httpServer.Addr)
+		if err == nil || !errors.Is(err, syscall.EADDRINUSE) {
+			return listener, err
+		}
+		if attempt >= s.config.Server.BindRetries {
+			return nil, fmt.Errorf("address still in use after %d retries: %w", attempt, err)
+		}
+
+		s.logger.Warn("Address in use, retrying bind",
+			zap.String("addr", s.httpServer.Addr),
+			zap.Int("retry", attempt+1),
+			zap.Int("bind_retries", s.config.Server.BindRetries),
+			zap.Duration("delay", delay),
+		)
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
 // Stop stops the server
+// The textfile gets a final snapshot within the same shutdown timeout, so a short benchmark run keeps its last state
 func (s *Server) Stop(ctx context.Context) error {
 	s.logger.Info("Shutting down HTTP server")
 
 	shutdownCtx, cancel := context.WithTimeout(ctx, s.config.Server.ShutdownTimeout.Duration)
 	defer cancel()
 
-	return s.httpServer.Shutdown(shutdownCtx)
+	err := s.httpServer.Shutdown(shutdownCtx)
+	if s.textfile != nil {
+		s.flushTextfile(shutdownCtx)
+	}
+	return err
 }
 
 // startMetricCollection starts the metric collection
-// It collects metrics at the specified interval
+// Each collector runs in its own goroutine at its metrics.collector_intervals entry,
+// falling back to the global collection interval, so e.g. df can run less often than ping
 func (s *Server) startMetricCollection(ctx context.Context) {
-	ticker := time.NewTicker(s.config.Metrics.CollectionInterval.Duration)
-	defer ticker.Stop()
+	names := make(map[string]bool)
+	for _, collector := range s.collectors {
+		name, _ := s.collectorSettings(collector)
+		names[name] = true
+	}
+	for name := range s.config.Metrics.CollectorIntervals {
+		if !names[name] {
+			s.logger.Warn("Ignoring interval for unknown or disabled collector", zap.String("collector", name))
+		}
+	}
+
+	if delay := s.config.Metrics.StartupDelay.Duration; delay > 0 {
+		s.logger.Info("Delaying the first collection", zap.Duration("startup_delay", delay))
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+	}
 
 	s.logger.Info("Starting metric collection",
 		zap.Duration("interval", s.config.Metrics.CollectionInterval.Duration),
 		zap.Int("collectors", len(s.collectors)),
 	)
 
-	// Collect metrics immediately on startup
-	s.collectAllMetrics(ctx)
+	// Collectors at their own intervals share one CycleState per target for as long as they run,
+	// so a dependent reads what its dependencies stored in their latest collection
+	stateCtxs := make(map[*config.Config]context.Context, len(s.targets))
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		s.publishOnInterval(ctx)
+	}()
+	for _, collector := range s.collectors {
+		_, cfg := s.collectorSettings(collector)
+		stateCtx, ok := stateCtxs[cfg]
+		if !ok {
+			stateCtx = collectors.WithCycleState(ctx)
+			stateCtxs[cfg] = stateCtx
+		}
+
+		wg.Add(1)
+		go func(ctx context.Context, collector collectors.Collector) {
+			defer wg.Done()
+			s.collectOnInterval(ctx, collector, s.collectorInterval(collector))
+		}(stateCtx, collector)
+	}
+	wg.Wait()
+
+	s.logger.Info("Stopping metric collection")
+}
+
+// collectorInterval returns the collection interval of a collector
+// Targets can override the interval per collector in their own configuration
+func (s *Server) collectorInterval(collector collectors.Collector) time.Duration {
+	name, cfg := s.collectorSettings(collector)
+	if interval, ok := cfg.Metrics.CollectorIntervals[name]; ok {
+		return interval.Duration
+	}
+	return cfg.Metrics.CollectionInterval.Duration
+}
+
+// collectOnInterval collects a single collector immediately and then at the given interval until ctx is done
+// A tick that fired while the previous collection was still running is skipped, so on an overloaded host a slow
+// collection isn't immediately followed by the next one, piling up docker stats calls
+func (s *Server) collectOnInterval(ctx context.Context, collector collectors.Collector, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	s.logger.Debug("Starting collector",
+		zap.String("collector", collector.Name()),
+		zap.Duration("interval", interval),
+	)
+
+	s.collectOnStartup(ctx, collector, interval)
+	lastEnd := time.Now()
 
 	for {
+		var tick time.Time
+		select {
+		case <-ctx.Done():
+			return
+		case tick = <-ticker.C:
+		}
+
+		if tick.Before(lastEnd) {
+			s.metrics.collectionSkipped.WithLabelValues(collector.Name()).Inc()
+			s.logger.Debug("Skipping collection, the previous one was still running at the tick",
+				zap.String("collector", collector.Name()),
+				zap.Time("tick", tick),
+				zap.Duration("interval", interval),
+			)
+			continue
+		}
+
+		duration, _ := s.collectWithTimeout(ctx, collector)
+		s.checkOverrun(duration, interval)
+		lastEnd = time.Now()
+	}
+}
+
+// startupRetryDelay is the wait between retries of a collector's first collection
+const startupRetryDelay = 2 * time.Second
+
+// collectOnStartup runs the first collection of a collector, retrying it up to metrics.startup_retries times
+// while it doesn't fully succeed, e.g. until podman's socket is up on a freshly booted rootless VM
+func (s *Server) collectOnStartup(ctx context.Context, collector collectors.Collector, interval time.Duration) {
+	for attempt := 0; ; attempt++ {
+		duration, status := s.collectWithTimeout(ctx, collector)
+		s.checkOverrun(duration, interval)
+
+		if status == statusOK || attempt >= s.config.Metrics.StartupRetries {
+			return
+		}
+
+		s.logger.Info("Retrying the first collection",
+			zap.String("collector", collector.Name()),
+			zap.String("status", status),
+			zap.Int("attempt", attempt+1),
+			zap.Int("startup_retries", s.config.Metrics.StartupRetries),
+		)
 		select {
 		case <-ctx.Done():
-			s.logger.Info("Stopping metric collection")
 			return
-		case <-ticker.C:
-			s.collectAllMetrics(ctx)
+		case <-time.After(startupRetryDelay):
 		}
 	}
 }
 
+// collectWithTimeout runs a single collector bounded by the command timeout
+// It returns how long the collection took and its status.
+func (s *Server) collectWithTimeout(ctx context.Context, collector collectors.Collector) (time.Duration, string) {
+	start := time.Now()
+
+	collectCtx, cancel := context.WithTimeout(ctx, s.config.Metrics.CommandTimeout.Duration)
+	defer cancel()
+	status := s.collect(collectCtx, collector)
+
+	duration := time.Since(start)
+	s.history.add(cycleSummary{
+		Timestamp:  start,
+		Duration:   duration.String(),
+		Collectors: map[string]string{collector.Name(): status},
+	})
+
+	return duration, status
+}
+
+// checkOverrun reports a collection that took too large a share of its collection interval
+// Once cycles approach the interval they start overlapping and metrics go stale,
+// so this gives early warning that the host can't keep up with the configured interval.
+func (s *Server) checkOverrun(duration, interval time.Duration) {
+	budget := time.Duration(float64(interval) * s.config.Metrics.OverrunThreshold)
+	if duration <= budget {
+		return
+	}
+
+	s.metrics.collectionOverruns.Inc()
+	s.logger.Warn("Metric collection is approaching the collection interval",
+		zap.Duration("duration", duration),
+		zap.Duration("interval", interval),
+		zap.Float64("threshold", s.config.Metrics.OverrunThreshold),
+	)
+}
+
 // collectAllMetrics collects all the metrics
 // It collects metrics from all the collectors
 // It can be used to collect metrics on demand. For example, when the server is started, the metrics are collected immediately.
 // Or when the server is stopped, the metrics are collected immediately.
-// It calls the CollectMetrics method of all the collectors.
-func (s *Server) collectAllMetrics(ctx context.Context) {
+// It calls the CollectMetrics method of all the collectors, in dependency order, then publishes the metrics to the sinks
+// and the extra sinks of this cycle only.
+// It returns how long the whole cycle took and the error of writing to the extra sinks.
+func (s *Server) collectAllMetrics(ctx context.Context, extra ...Sink) (time.Duration, error) {
 	start := time.Now()
 
 	// Create a timeout context for metric collection
 	collectCtx, cancel := context.WithTimeout(ctx, s.config.Metrics.CommandTimeout.Duration)
 	defer cancel()
 
+	// Collectors run in dependency order and share state through a CycleState per target
+	cycleCtxs := make(map[*config.Config]context.Context, len(s.targets))
+	statuses := make(map[string]string, len(s.collectors))
 	for _, collector := range s.collectors {
-		if err := collector.CollectMetrics(collectCtx); err != nil {
-			s.logger.Error("Failed to collect metrics",
-				zap.String("collector", collector.Name()),
-				zap.Error(err),
-			)
+		_, cfg := s.collectorSettings(collector)
+		cycleCtx, ok := cycleCtxs[cfg]
+		if !ok {
+			cycleCtx = collectors.WithCycleState(collectCtx)
+			cycleCtxs[cfg] = cycleCtx
 		}
+		statuses[collector.Name()] = s.collect(cycleCtx, collector)
 	}
 
 	duration := time.Since(start)
+	s.history.add(cycleSummary{
+		Timestamp:  start,
+		Duration:   duration.String(),
+		Collectors: statuses,
+	})
 	s.logger.Debug("Metric collection completed",
 		zap.Duration("duration", duration),
 		zap.Int("collectors", len(s.collectors)),
 	)
+
+	// Counted whether or not the collectors succeeded, it only shows that cycles run
+	s.metrics.collectionCycles.Inc()
+	return duration, s.publish(ctx, extra...)
+}
+
+// collect runs a single collector, logging its errors and recording when it last succeeded
+// It returns the collection status for the history: ok, partial or failed
+func (s *Server) collect(ctx context.Context, collector collectors.Collector) string {
+	if err := collector.CollectMetrics(ctx); err != nil {
+		// A partial failure is logged as a warning, but only a full success updates the last collection time,
+		// so a collector that keeps failing part of its work still shows as stalled
+		var collectionErr *collectors.CollectionError
+		if errors.As(err, &collectionErr) && collectionErr.Partial() {
+			s.logger.Warn("Partially failed to collect metrics",
+				zap.String("collector", collector.Name()),
+				zap.Strings("failed", collectionErr.FailedNames()),
+				zap.Error(err),
+			)
+			return statusPartial
+		}
+
+		s.logger.Error("Failed to collect metrics",
+			zap.String("collector", collector.Name()),
+			zap.Error(err),
+		)
+		return statusFailed
+	}
+	s.metrics.lastCollection.WithLabelValues(collector.Name()).SetToCurrentTime()
+	return statusOK
 }
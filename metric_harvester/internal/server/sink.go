@@ -0,0 +1,101 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"go.uber.org/zap"
+)
+
+// Sink receives a snapshot of all metrics once per collection cycle, or once for --once and the SIGUSR1 dump
+// The metrics are gathered once per publish and handed to every sink, with comparisons and relabeling applied,
+// so an export format doesn't gather the registry on its own
+type Sink interface {
+	// Name identifies the sink in logs
+	Name() string
+	// Write exports a snapshot. The families are shared between the sinks and must not be modified
+	Write(ctx context.Context, families []*dto.MetricFamily) error
+}
+
+// publish gathers the metrics once and writes the snapshot to every sink, and to the extra sinks of this publish only
+// It runs once per cycle: after collectAllMetrics, or every collection interval while collectors run at their own
+// intervals, see publishOnInterval, and on demand for --once and the SIGUSR1 dump, which pass their sink as extra.
+// It is serialized so the sinks see the snapshots in order.
+// Errors of the server's sinks are only logged, the errors of the extra sinks are returned to the caller
+func (s *Server) publish(ctx context.Context, extra ...Sink) error {
+	s.publishMu.Lock()
+	defer s.publishMu.Unlock()
+
+	// Gather returns what it could gather along with the error
+	families, err := s.Gatherer().Gather()
+	if err != nil {
+		s.logger.Warn("Failed to gather some metrics for the sinks", zap.Error(err))
+	}
+
+	for _, sink := range s.sinks {
+		if err := sink.Write(ctx, families); err != nil {
+			s.logger.Error("Failed to write metrics to sink", zap.String("sink", sink.Name()), zap.Error(err))
+		}
+	}
+
+	var errs []error
+	for _, sink := range extra {
+		if err := sink.Write(ctx, families); err != nil {
+			errs = append(errs, fmt.Errorf("sink %s: %w", sink.Name(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// prometheusSink is the default sink, serving /metrics and /metrics.csv
+// It gathers live on every scrape rather than serving the published snapshot, so values computed at scrape time,
+// e.g. harvester_executor_inflight_commands and the harvester's own memory, are current
+type prometheusSink struct {
+	live prometheus.Gatherer
+}
+
+// newPrometheusSink creates the Prometheus sink
+// Args:
+// - live: the gatherer served on every scrape
+// Returns:
+// - *prometheusSink: new prometheusSink instance
+func newPrometheusSink(live prometheus.Gatherer) *prometheusSink {
+	return &prometheusSink{live: live}
+}
+
+func (p *prometheusSink) Name() string {
+	return "prometheus"
+}
+
+// Write implements Sink, scrapes gather live so the snapshot isn't kept
+func (p *prometheusSink) Write(_ context.Context, _ []*dto.MetricFamily) error {
+	return nil
+}
+
+// Gather implements prometheus.Gatherer, gathering the live metrics
+func (p *prometheusSink) Gather() ([]*dto.MetricFamily, error) {
+	return p.live.Gather()
+}
+
+// publishOnInterval publishes the metrics every collection interval until ctx is done
+// Collectors running at their own metrics.collector_intervals have no common cycle, so each collection interval
+// counts as one: the sinks get one snapshot and harvester_collection_cycles_total one increment per interval
+// instead of one per collector run
+func (s *Server) publishOnInterval(ctx context.Context) {
+	ticker := time.NewTicker(s.config.Metrics.CollectionInterval.Duration)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.metrics.collectionCycles.Inc()
+			s.publish(ctx)
+		}
+	}
+}
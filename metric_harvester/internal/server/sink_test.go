@@ -0,0 +1,171 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"go.uber.org/zap"
+
+	"metric_harvester/internal/config"
+)
+
+// recordingSink keeps every snapshot it receives
+type recordingSink struct {
+	mu        sync.Mutex
+	snapshots [][]*dto.MetricFamily
+}
+
+// Name implements Sink
+func (r *recordingSink) Name() string {
+	return "recording"
+}
+
+// Write implements Sink, appending the snapshot
+func (r *recordingSink) Write(_ context.Context, families []*dto.MetricFamily) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.snapshots = append(r.snapshots, families)
+	return nil
+}
+
+// Snapshots returns the snapshots received so far
+func (r *recordingSink) Snapshots() [][]*dto.MetricFamily {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.snapshots
+}
+
+func TestPublishDeliversOneSnapshotToEverySink(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	gauge := prometheus.NewGauge(prometheus.GaugeOpts{Name: "test_value", Help: "Test value"})
+	registry.MustRegister(gauge)
+	gauge.Set(42)
+
+	first, second := &recordingSink{}, &recordingSink{}
+	s := &Server{
+		config:   config.New(),
+		logger:   zap.NewNop(),
+		registry: registry,
+		sinks:    []Sink{first, second},
+	}
+
+	s.publish(context.Background())
+
+	for _, sink := range []*recordingSink{first, second} {
+		snapshots := sink.Snapshots()
+		if len(snapshots) != 1 {
+			t.Fatalf("got %d snapshots, want 1", len(snapshots))
+		}
+		families := snapshots[0]
+		if len(families) != 1 || families[0].GetName() != "test_value" {
+			t.Fatalf("got families %v, want test_value", families)
+		}
+		if value := families[0].GetMetric()[0].GetGauge().GetValue(); value != 42 {
+			t.Errorf("got value %v, want 42", value)
+		}
+	}
+}
+
+// failingSink fails every write
+type failingSink struct{}
+
+func (failingSink) Name() string { return "failing" }
+
+func (failingSink) Write(context.Context, []*dto.MetricFamily) error {
+	return errors.New("disk full")
+}
+
+func TestPublishReturnsOnlyExtraSinkErrors(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	extra := &recordingSink{}
+	s := &Server{
+		config:   config.New(),
+		logger:   zap.NewNop(),
+		registry: registry,
+		sinks:    []Sink{failingSink{}},
+	}
+
+	// The server's own sinks only log their errors
+	if err := s.publish(context.Background(), extra); err != nil {
+		t.Fatalf("publish with a failing server sink returned %v, want nil", err)
+	}
+	if got := len(extra.Snapshots()); got != 1 {
+		t.Fatalf("extra sink got %d snapshots, want 1", got)
+	}
+
+	s.sinks = nil
+	if err := s.publish(context.Background(), failingSink{}); err == nil {
+		t.Fatal("publish with a failing extra sink returned nil, want its error")
+	}
+}
+
+func TestWriterSink(t *testing.T) {
+	gauge := prometheus.NewGauge(prometheus.GaugeOpts{Name: "test_value", Help: "Test value"})
+	gauge.Set(42)
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(gauge)
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		format  string
+		want    string
+		wantErr bool
+	}{
+		{format: "prom", want: "test_value 42\n"},
+		{format: "csv", want: "test_value,,42,"},
+		{format: "json", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var buf bytes.Buffer
+			sink, err := NewWriterSink(&buf, tt.format)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if err := sink.Write(context.Background(), families); err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(buf.String(), tt.want) {
+				t.Errorf("output %q doesn't contain %q", buf.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestDumpMetricsWritesFile(t *testing.T) {
+	gauge := prometheus.NewGauge(prometheus.GaugeOpts{Name: "test_value", Help: "Test value"})
+	gauge.Set(42)
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(gauge)
+	s := &Server{config: config.New(), logger: zap.NewNop(), registry: registry}
+
+	dir := filepath.Join(t.TempDir(), "results")
+	path, err := s.DumpMetrics(context.Background(), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Dir(path) != dir || !strings.HasPrefix(filepath.Base(path), "metrics-") {
+		t.Errorf("got path %q, want a metrics-<time>.prom file in %q", path, dir)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "test_value 42\n") {
+		t.Errorf("dump %q doesn't contain test_value 42", data)
+	}
+}
@@ -0,0 +1,118 @@
+package server
+
+import (
+	"metric_harvester/internal/collectors"
+	"metric_harvester/internal/config"
+	"metric_harvester/internal/utils"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+// target is a host collected by its own set of collectors
+// Without configured targets there is a single target with an empty name, the local host with the top-level configuration
+type target struct {
+	name string
+	deps *collectors.CollectorDependencies
+}
+
+// newTargets creates the dependencies of every configured target
+// Each target gets its own executor and Docker API client built from its configuration
+// Args:
+// - params: ServerParams
+// - deps: the top-level CollectorDependencies, used when no targets are configured
+// Returns:
+// - []target: the targets, in configuration order
+// - error: error if the configuration of a target is invalid
+func newTargets(params *ServerParams, deps *collectors.CollectorDependencies) ([]target, error) {
+	if len(params.Config.Targets) == 0 {
+		return []target{{deps: deps}}, nil
+	}
+
+	targets := make([]target, 0, len(params.Config.Targets))
+	for _, t := range params.Config.Targets {
+		cfg, err := params.Config.ForTarget(t)
+		if err != nil {
+			return nil, err
+		}
+
+		logger := params.Logger.With(zap.String("target", t.Name))
+		var dockerAPI *utils.DockerAPIExecutor
+		if cfg.Containers.DockerSocket != "" {
+			dockerAPI = utils.NewDockerAPIExecutor(cfg.Containers.DockerSocket, logger)
+		}
+
+		targets = append(targets, target{
+			name: t.Name,
+			deps: &collectors.CollectorDependencies{
+				Executor:      utils.NewSystemCommandExecutor(logger, cfg),
+				Logger:        logger,
+				Config:        cfg,
+				DockerAPI:     dockerAPI,
+				ParseFailures: collectors.NewParseFailures(),
+			},
+		})
+	}
+	return targets, nil
+}
+
+// register registers the target's executor, parse failures, host info and collectors, adding a host label when the target is named
+// The label is host rather than target, which the ping metrics already use for the pinged host
+// It returns everything it registered
+func (t target) register(registry *prometheus.Registry, collectors []collectors.Collector) []prometheus.Collector {
+	var registerer prometheus.Registerer = registry
+	if t.name != "" {
+		registerer = prometheus.WrapRegistererWith(prometheus.Labels{"host": t.name}, registry)
+	}
+
+	registered := []prometheus.Collector{t.deps.Executor, t.deps.ParseFailures}
+	if t.deps.Config.Metrics.EnableHostInfo {
+		registered = append(registered, newHostInfo(t.deps.Executor, t.deps.Config.Metrics.CommandTimeout.Duration, t.deps.Logger))
+	}
+	for _, collector := range collectors {
+		registered = append(registered, collector)
+	}
+	registerer.MustRegister(registered...)
+	return registered
+}
+
+// newCollectors creates the target's collectors, named <target>/<collector> when the target is named
+func (t target) newCollectors() []collectors.Collector {
+	result := newCollectors(t.deps)
+	if t.name == "" {
+		return result
+	}
+
+	for i, collector := range result {
+		result[i] = &targetCollector{Collector: collector, target: t.name, config: t.deps.Config}
+	}
+	return result
+}
+
+// targetCollector is a collector of a named target
+// The target prefixes its name, so logs, /history and harvester_last_collection_timestamp_seconds tell targets apart
+type targetCollector struct {
+	collectors.Collector
+	target string
+	config *config.Config
+}
+
+func (c *targetCollector) Name() string {
+	return c.target + "/" + c.Collector.Name()
+}
+
+// RequiredCommands only needs ssh locally for remote targets, the commands on the remote host can't be probed from here
+func (c *targetCollector) RequiredCommands() []string {
+	if c.config.Executor.SSHHost != "" {
+		return []string{"ssh"}
+	}
+	return c.Collector.RequiredCommands()
+}
+
+// collectorSettings returns the name a collector is configured under and the configuration it runs with
+func (s *Server) collectorSettings(collector collectors.Collector) (string, *config.Config) {
+	if c, ok := collector.(*targetCollector); ok {
+		return c.Collector.Name(), c.config
+	}
+	return collector.Name(), s.config
+}
@@ -0,0 +1,131 @@
+package server
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"go.uber.org/zap"
+)
+
+// textfileName is the file written in textfile.directory, node_exporter's textfile collector reads *.prom
+const textfileName = "harvester.prom"
+
+// textfileSink writes the metrics to textfile.directory for node_exporter's textfile collector
+// Snapshots arrive once per collection cycle, the file is rewritten at most every textfile.interval
+type textfileSink struct {
+	path     string
+	interval time.Duration
+
+	mu        sync.Mutex
+	lastWrite time.Time
+}
+
+// newTextfileSink creates the textfile sink
+// Args:
+// - directory: textfile.directory
+// - interval: textfile.interval, the minimum time between writes
+// Returns:
+// - *textfileSink: new textfileSink instance
+func newTextfileSink(directory string, interval time.Duration) *textfileSink {
+	return &textfileSink{
+		path:     filepath.Join(directory, textfileName),
+		interval: interval,
+	}
+}
+
+func (t *textfileSink) Name() string {
+	return "textfile"
+}
+
+// Write implements Sink, writing the snapshot unless the file was written less than textfile.interval ago
+func (t *textfileSink) Write(_ context.Context, families []*dto.MetricFamily) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if time.Since(t.lastWrite) < t.interval {
+		return nil
+	}
+	t.lastWrite = time.Now()
+	return writeTextfile(t.path, families)
+}
+
+// flush writes a snapshot regardless of textfile.interval
+func (t *textfileSink) flush(families []*dto.MetricFamily) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.lastWrite = time.Now()
+	return writeTextfile(t.path, families)
+}
+
+// collectForTextfileOnInterval collects every textfile.interval with collect_on_scrape until ctx is done
+// Each collection counts as a scrape, so the file stays fresh without a scraper attached
+func (s *Server) collectForTextfileOnInterval(ctx context.Context) {
+	ticker := time.NewTicker(s.config.Textfile.Interval.Duration)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.collectOnScrape(ctx)
+		}
+	}
+}
+
+// flushTextfile gathers the metrics and writes them to textfile.directory on shutdown
+// Otherwise the file keeps the state of the last interval, missing the end of a short benchmark run.
+// The write is abandoned when ctx is done, e.g. on a hung network filesystem
+func (s *Server) flushTextfile(ctx context.Context) {
+	done := make(chan error, 1)
+	go func() {
+		families, err := s.Gatherer().Gather()
+		if err != nil {
+			s.logger.Warn("Failed to gather some metrics for the final textfile", zap.Error(err))
+		}
+		done <- s.textfile.flush(families)
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			s.logger.Error("Failed to write the final metrics textfile", zap.String("path", s.textfile.path), zap.Error(err))
+			return
+		}
+		s.logger.Info("Wrote the final metrics textfile", zap.String("path", s.textfile.path))
+	case <-ctx.Done():
+		s.logger.Warn("Shutdown timeout reached before the final metrics textfile was written",
+			zap.String("path", s.textfile.path),
+			zap.Error(ctx.Err()))
+	}
+}
+
+// writeTextfile writes the metrics in the Prometheus text format to path atomically
+// It writes a temporary file in the same directory and renames it over path, so node_exporter never reads
+// a partial file. The temporary name doesn't end in .prom so node_exporter ignores it
+func writeTextfile(path string, families []*dto.MetricFamily) error {
+	file, err := os.CreateTemp(filepath.Dir(path), textfileName+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+
+	if err := writeText(file, families); err != nil {
+		file.Close()
+		return err
+	}
+	// CreateTemp makes the file readable by its owner only, node_exporter usually runs as another user
+	if err := file.Chmod(0o644); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), path)
+}
@@ -0,0 +1,75 @@
+package server
+
+import (
+	"net/http"
+	"regexp"
+
+	"go.uber.org/zap"
+)
+
+// userAgentFilter keeps scrapers off /metrics by their User-Agent, e.g. a rogue scraper in a shared lab
+// It is not authentication, any client can send the User-Agent it likes
+type userAgentFilter struct {
+	allowed []*regexp.Regexp
+	denied  []*regexp.Regexp
+	logger  *zap.Logger
+}
+
+// newUserAgentFilter compiles server.allowed_user_agents and server.denied_user_agents
+// Invalid patterns are skipped here since config validation already rejects them
+func newUserAgentFilter(allowed, denied []string, logger *zap.Logger) *userAgentFilter {
+	return &userAgentFilter{
+		allowed: compileUserAgentPatterns(allowed),
+		denied:  compileUserAgentPatterns(denied),
+		logger:  logger,
+	}
+}
+
+func compileUserAgentPatterns(patterns []string) []*regexp.Regexp {
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		if re, err := regexp.Compile(pattern); err == nil {
+			compiled = append(compiled, re)
+		}
+	}
+	return compiled
+}
+
+// permits checks a User-Agent against the lists
+// A denied match always wins, an empty allow list allows every agent that isn't denied
+func (f *userAgentFilter) permits(userAgent string) bool {
+	for _, re := range f.denied {
+		if re.MatchString(userAgent) {
+			return false
+		}
+	}
+	if len(f.allowed) == 0 {
+		return true
+	}
+	for _, re := range f.allowed {
+		if re.MatchString(userAgent) {
+			return true
+		}
+	}
+	return false
+}
+
+// handler responds 403 to requests whose User-Agent isn't permitted and passes the others to next
+func (f *userAgentFilter) handler(next http.Handler) http.Handler {
+	if len(f.allowed) == 0 && len(f.denied) == 0 {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !f.permits(r.UserAgent()) {
+			f.logger.Debug("Rejected request from disallowed user agent",
+				zap.String("path", r.URL.Path),
+				zap.String("user_agent", r.UserAgent()),
+				zap.String("remote_addr", r.RemoteAddr),
+			)
+			http.Error(w, "user agent not allowed", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
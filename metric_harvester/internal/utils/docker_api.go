@@ -0,0 +1,99 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+
+	"go.uber.org/zap"
+)
+
+// DockerAPIExecutor talks to the Docker Engine API over its unix socket
+// It is used instead of the docker CLI where only the socket is available
+type DockerAPIExecutor struct {
+	logger *zap.Logger
+	client *http.Client
+}
+
+// NewDockerAPIExecutor creates a new DockerAPIExecutor
+// Args:
+// - socketPath: path to the docker unix socket, e.g. /var/run/docker.sock
+// - logger: *zap.Logger
+// Returns:
+// - *DockerAPIExecutor: new DockerAPIExecutor instance
+func NewDockerAPIExecutor(socketPath string, logger *zap.Logger) *DockerAPIExecutor {
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, "unix", socketPath)
+		},
+	}
+
+	return &DockerAPIExecutor{
+		logger: logger.Named("docker_api"),
+		client: &http.Client{Transport: transport},
+	}
+}
+
+// get performs a GET request against the Docker API and returns the response body
+// The host part of the URL is ignored since the transport always dials the socket
+func (e *DockerAPIExecutor) get(ctx context.Context, path string) ([]byte, error) {
+	e.logger.Debug("Calling Docker API", zap.String("path", path))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://docker"+path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		e.logger.Error("Docker API request failed",
+			zap.String("path", path),
+			zap.Error(err),
+		)
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("docker API %s returned %s: %s", path, resp.Status, body)
+	}
+
+	return body, nil
+}
+
+// ListContainers lists the running containers
+// The endpoint it calls is:
+// - GET /containers/json
+func (e *DockerAPIExecutor) ListContainers(ctx context.Context) ([]byte, error) {
+	return e.get(ctx, "/containers/json")
+}
+
+// GetInfo gets system-wide information about the Docker daemon as JSON
+// The endpoint it calls is:
+// - GET /info
+func (e *DockerAPIExecutor) GetInfo(ctx context.Context) ([]byte, error) {
+	return e.get(ctx, "/info")
+}
+
+// GetDockerStats gets a single stats sample for a container as JSON
+// The endpoint it calls is:
+// - GET /containers/<name>/stats?stream=false
+func (e *DockerAPIExecutor) GetDockerStats(ctx context.Context, containerName string) ([]byte, error) {
+	return e.get(ctx, "/containers/"+url.PathEscape(containerName)+"/stats?stream=false")
+}
+
+// InspectContainer gets low-level information about a container as JSON
+// The endpoint it calls is:
+// - GET /containers/<name>/json
+func (e *DockerAPIExecutor) InspectContainer(ctx context.Context, containerName string) ([]byte, error) {
+	return e.get(ctx, "/containers/"+url.PathEscape(containerName)+"/json")
+}
@@ -2,10 +2,20 @@ package utils
 
 import (
 	"context"
+	"errors"
+	"math"
+	"metric_harvester/internal/config"
+	"os"
 	"os/exec"
+	"os/user"
+	"path/filepath"
+	"runtime"
 	"strconv"
 	"strings"
+	"sync/atomic"
+	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"go.uber.org/zap"
 )
 
@@ -18,24 +28,128 @@ type CommandExecutor interface {
 	GetDiskUsage(ctx context.Context, path string) ([]byte, error)
 	GetNetworkStats(ctx context.Context) ([]byte, error)
 	GetSystemUptime(ctx context.Context) ([]byte, error)
+	GetCPUFrequencies(ctx context.Context) ([]byte, error)
+	GetMacCPUUsage(ctx context.Context) ([]byte, error)
+	GetMacVMStat(ctx context.Context) ([]byte, error)
+	GetMacPhysicalMemory(ctx context.Context) ([]byte, error)
 
 	// Container metrics methods
 	GetDockerStats(ctx context.Context, containerName string) ([]byte, error)
 	GetPodmanStats(ctx context.Context, containerName string) ([]byte, error)
+	ListDockerContainers(ctx context.Context) ([]byte, error)
+	ListPodmanContainers(ctx context.Context) ([]byte, error)
+	GetDockerContainerInfo(ctx context.Context) ([]byte, error)
+	GetPodmanContainerInfo(ctx context.Context) ([]byte, error)
+	GetDockerSecurityOptions(ctx context.Context) ([]byte, error)
+	GetPodmanRootless(ctx context.Context) ([]byte, error)
+	GetDockerContainerPID(ctx context.Context, containerName string) ([]byte, error)
+	GetPodmanContainerPID(ctx context.Context, containerName string) ([]byte, error)
+	GetDockerContainerOOMKilled(ctx context.Context, containerName string) ([]byte, error)
+	GetPodmanContainerOOMKilled(ctx context.Context, containerName string) ([]byte, error)
 
 	// Network testing methods
 	PingHost(ctx context.Context, host string, count int) ([]byte, error)
+	PingHosts(ctx context.Context, hosts []string, count int) ([]byte, error)
+	GetQdiscStats(ctx context.Context, iface string) ([]byte, error)
+	GetInterfaceLinkStats(ctx context.Context) ([]byte, error)
+	GetInterfaceStates(ctx context.Context, ifaces []string) ([]byte, error)
+	GetInterfaceQueues(ctx context.Context, iface string) ([]byte, error)
+	GetInterfaceDriverStats(ctx context.Context, iface string) ([]byte, error)
 	GetProcessInfo(ctx context.Context, pid string) ([]byte, error)
 }
 
 type SystemCommandExecutor struct {
 	logger *zap.Logger
+	config *config.Config
+
+	// Prometheus metrics for the cost of shelling out, which itself differs under rootless (fork/exec + namespaces)
+	// commandsTotal: number of commands run
+	// commandFailures: number of commands that failed
+	// commandDuration: time taken by each command
+	commandsTotal   *prometheus.CounterVec
+	commandFailures *prometheus.CounterVec
+	commandDuration *prometheus.HistogramVec
+
+	// inflight is the number of commands running right now and maxInflight its high-water mark since startup,
+	// showing how many processes concurrent collection spawns at once
+	inflight         atomic.Int64
+	maxInflight      atomic.Int64
+	inflightGauge    prometheus.GaugeFunc
+	maxInflightGauge prometheus.GaugeFunc
+}
+
+func NewSystemCommandExecutor(logger *zap.Logger, config *config.Config) *SystemCommandExecutor {
+	e := &SystemCommandExecutor{
+		logger: logger.Named("executor"),
+		config: config,
+		commandsTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "harvester_commands_total",
+				Help: "Total number of commands run by the harvester",
+			},
+			[]string{"command"},
+		),
+		commandFailures: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "harvester_command_failures_total",
+				Help: "Total number of commands run by the harvester that failed",
+			},
+			[]string{"command"},
+		),
+		commandDuration: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "harvester_command_duration_seconds",
+				Help:    "Time taken by commands run by the harvester",
+				Buckets: prometheus.DefBuckets,
+			},
+			[]string{"command"},
+		),
+	}
+	e.inflightGauge = prometheus.NewGaugeFunc(
+		prometheus.GaugeOpts{
+			Name: "harvester_executor_inflight_commands",
+			Help: "Number of commands run by the harvester that are currently running",
+		},
+		func() float64 { return float64(e.inflight.Load()) },
+	)
+	e.maxInflightGauge = prometheus.NewGaugeFunc(
+		prometheus.GaugeOpts{
+			Name: "harvester_executor_max_inflight",
+			Help: "Highest number of commands run by the harvester at the same time since startup",
+		},
+		func() float64 { return float64(e.maxInflight.Load()) },
+	)
+	return e
+}
+
+// Describe implements the prometheus.Collector interface
+func (e *SystemCommandExecutor) Describe(ch chan<- *prometheus.Desc) {
+	e.commandsTotal.Describe(ch)
+	e.commandFailures.Describe(ch)
+	e.commandDuration.Describe(ch)
+	e.inflightGauge.Describe(ch)
+	e.maxInflightGauge.Describe(ch)
+}
+
+// Collect implements the prometheus.Collector interface
+func (e *SystemCommandExecutor) Collect(ch chan<- prometheus.Metric) {
+	e.commandsTotal.Collect(ch)
+	e.commandFailures.Collect(ch)
+	e.commandDuration.Collect(ch)
+	e.inflightGauge.Collect(ch)
+	e.maxInflightGauge.Collect(ch)
 }
 
-func NewSystemCommandExecutor(logger *zap.Logger) *SystemCommandExecutor {
-	return &SystemCommandExecutor{
-		logger: logger,
+// trackInflight counts a command as running until the returned function is called, raising the high-water mark
+func (e *SystemCommandExecutor) trackInflight() func() {
+	running := e.inflight.Add(1)
+	for {
+		highest := e.maxInflight.Load()
+		if running <= highest || e.maxInflight.CompareAndSwap(highest, running) {
+			break
+		}
 	}
+	return func() { e.inflight.Add(-1) }
 }
 
 // Execute executes a command and returns the output
@@ -47,32 +161,90 @@ func NewSystemCommandExecutor(logger *zap.Logger) *SystemCommandExecutor {
 // - []byte: output of the command
 // - error: error if the command fails
 func (e *SystemCommandExecutor) Execute(ctx context.Context, command string, args ...string) ([]byte, error) {
+	output, err := e.execute(ctx, false, command, args...)
+	if err != nil {
+		e.logFailure(command, args, err)
+		return nil, err
+	}
+	return output, nil
+}
+
+// execute runs a command for Execute, returning its output also when it fails
+// With combined, stderr is returned along with stdout, for commands like fping that report on stderr.
+// Failures are counted but not logged, callers log them with logFailure unless they accept or retry them
+func (e *SystemCommandExecutor) execute(ctx context.Context, combined bool, command string, args ...string) ([]byte, error) {
 	cmd := exec.CommandContext(ctx, command, args...)
+	if host := e.config.Executor.SSHHost; host != "" {
+		cmd = exec.CommandContext(ctx, "ssh", sshArgs(host, e.config.Executor.Env, command, args)...)
+	}
+	// Later entries win, so the configured environment overrides the inherited one
+	cmd.Env = append(os.Environ(), e.config.Executor.Env...)
 
 	e.logger.Debug("Executing command",
 		zap.String("command", command),
 		zap.Strings("args", args),
 	)
 
-	output, err := cmd.Output()
+	// Commands are labeled by their base name, e.g. "docker" for /usr/bin/docker, to keep cardinality bounded
+	name := filepath.Base(command)
+	done := e.trackInflight()
+	start := time.Now()
+	var output []byte
+	var err error
+	if combined {
+		output, err = cmd.CombinedOutput()
+	} else {
+		output, err = cmd.Output()
+	}
+	done()
+	e.commandDuration.WithLabelValues(name).Observe(time.Since(start).Seconds())
+	e.commandsTotal.WithLabelValues(name).Inc()
+
 	if err != nil {
-		e.logger.Error("Command execution failed",
-			zap.String("command", command),
-			zap.Strings("args", args),
-			zap.Error(err),
-		)
-		return nil, err
+		e.commandFailures.WithLabelValues(name).Inc()
 	}
 
-	return output, nil
+	return output, err
+}
+
+// logFailure logs a command that failed for good
+func (e *SystemCommandExecutor) logFailure(command string, args []string, err error) {
+	e.logger.Error("Command execution failed",
+		zap.String("command", command),
+		zap.Strings("args", args),
+		zap.Error(err),
+	)
+}
+
+// sshArgs builds the ssh arguments that run a command on a remote host
+// ssh hands the remote shell a single string, so the environment, command and args are quoted for it
+// BatchMode makes ssh fail instead of prompting when the key or host key isn't set up
+// The command it runs is:
+// - ssh -o BatchMode=yes <host> -- env KEY=VALUE... command args...
+func sshArgs(host string, env []string, command string, args []string) []string {
+	words := append([]string{"env"}, env...)
+	words = append(words, command)
+	words = append(words, args...)
+
+	quoted := make([]string, len(words))
+	for i, word := range words {
+		quoted[i] = "'" + strings.ReplaceAll(word, "'", `'\''`) + "'"
+	}
+
+	return []string{"-o", "BatchMode=yes", host, "--", strings.Join(quoted, " ")}
 }
 
 // Helper functions for common system commands
 
 // GetCPUUsage gets CPU usage on Linux
+// With metrics.cpu_sample_window, top takes a second sample after the window, the first one covers the time since boot
 // The command it runs is:
-// - top -bn1
+// - top -bn1, or top -bn2 -d <window seconds> with metrics.cpu_sample_window
 func (e *SystemCommandExecutor) GetCPUUsage(ctx context.Context) ([]byte, error) {
+	if window := e.config.Metrics.CPUSampleWindow.Duration; window > 0 {
+		return e.Execute(ctx, "top", "-bn2", "-d", strconv.FormatFloat(window.Seconds(), 'f', -1, 64))
+	}
+
 	// Use top command to get CPU usage on Linux
 	return e.Execute(ctx, "top", "-bn1")
 }
@@ -85,24 +257,259 @@ func (e *SystemCommandExecutor) GetMemoryUsage(ctx context.Context) ([]byte, err
 	return e.Execute(ctx, "free", "-b")
 }
 
+// cpuFrequencyGlob matches the cpufreq file of every core
+const cpuFrequencyGlob = "/sys/devices/system/cpu/cpu[0-9]*/cpufreq/scaling_cur_freq"
+
+// GetCPUFrequencies gets the current frequency in kHz of each core that has cpufreq, one "path:value" line per core
+// Cores without cpufreq, common in VMs, have no file and are left out. Locally nothing is run when no core has one.
+// On an ssh target the glob is expanded by the target's shell, where no match leaves the output empty
+// The commands it runs are:
+// - grep -H . /sys/devices/system/cpu/cpu*/cpufreq/scaling_cur_freq
+// - sh -c "grep -H . /sys/devices/system/cpu/cpu[0-9]*/cpufreq/scaling_cur_freq 2>/dev/null || true", on an ssh target
+func (e *SystemCommandExecutor) GetCPUFrequencies(ctx context.Context) ([]byte, error) {
+	if e.config.Executor.SSHHost != "" {
+		return e.Execute(ctx, "sh", "-c", "grep -H . "+cpuFrequencyGlob+" 2>/dev/null || true")
+	}
+
+	files, err := filepath.Glob(cpuFrequencyGlob)
+	if err != nil || len(files) == 0 {
+		return nil, err
+	}
+	return e.Execute(ctx, "grep", append([]string{"-H", "."}, files...)...)
+}
+
+// GetMacCPUUsage gets CPU usage on macOS
+// The command it runs is:
+// - top -l 1 -n 0
+func (e *SystemCommandExecutor) GetMacCPUUsage(ctx context.Context) ([]byte, error) {
+	// One logging-mode sample without the process list
+	return e.Execute(ctx, "top", "-l", "1", "-n", "0")
+}
+
+// GetMacVMStat gets virtual memory statistics on macOS
+// The command it runs is:
+// - vm_stat
+func (e *SystemCommandExecutor) GetMacVMStat(ctx context.Context) ([]byte, error) {
+	return e.Execute(ctx, "vm_stat")
+}
+
+// GetMacPhysicalMemory gets the physical memory size in bytes on macOS
+// vm_stat only reports page counts, so the total comes from sysctl
+// The command it runs is:
+// - sysctl -n hw.memsize
+func (e *SystemCommandExecutor) GetMacPhysicalMemory(ctx context.Context) ([]byte, error) {
+	return e.Execute(ctx, "sysctl", "-n", "hw.memsize")
+}
+
 // GetDockerStats gets Docker stats
 // The command it runs is:
-// - docker stats --no-stream --format "table {{.Container}}\\t{{.CPUPerc}}\\t{{.MemUsage}}\\t{{.NetIO}}\\t{{.BlockIO}}"
+// - docker stats --no-stream [--no-trunc] --format "table {{.Container}}\\t{{.CPUPerc}}\\t{{.MemUsage}}\\t{{.NetIO}}\\t{{.BlockIO}}"
 func (e *SystemCommandExecutor) GetDockerStats(ctx context.Context, containerName string) ([]byte, error) {
-	if containerName == "" {
-		return e.Execute(ctx, "docker", "stats", "--no-stream", "--format", "table {{.Container}}\\t{{.CPUPerc}}\\t{{.MemUsage}}\\t{{.NetIO}}\\t{{.BlockIO}}")
-	}
-	return e.Execute(ctx, "docker", "stats", "--no-stream", "--format", "table {{.Container}}\\t{{.CPUPerc}}\\t{{.MemUsage}}\\t{{.NetIO}}\\t{{.BlockIO}}", containerName)
+	return e.executeDocker(ctx, e.statsArgs("table {{.Container}}\\t{{.CPUPerc}}\\t{{.MemUsage}}\\t{{.NetIO}}\\t{{.BlockIO}}", containerName)...)
 }
 
 // GetPodmanStats gets Podman stats
 // The command it runs is:
-// - podman stats --no-stream --format "table {{.Name}}\\t{{.CPUPerc}}\\t{{.MemUsage}}\\t{{.NetIO}}\\t{{.BlockIO}}"
+// - podman stats --no-stream [--no-trunc] --format "table {{.Name}}\\t{{.CPUPerc}}\\t{{.MemUsage}}\\t{{.NetIO}}\\t{{.BlockIO}}"
 func (e *SystemCommandExecutor) GetPodmanStats(ctx context.Context, containerName string) ([]byte, error) {
-	if containerName == "" {
-		return e.Execute(ctx, "podman", "stats", "--no-stream", "--format", "table {{.Name}}\\t{{.CPUPerc}}\\t{{.MemUsage}}\\t{{.NetIO}}\\t{{.BlockIO}}")
+	return e.executePodman(ctx, e.statsArgs("table {{.Name}}\\t{{.CPUPerc}}\\t{{.MemUsage}}\\t{{.NetIO}}\\t{{.BlockIO}}", containerName)...)
+}
+
+// statsArgs builds the stats arguments shared by docker and podman
+// With containers.no_trunc, full container IDs are printed so containers sharing an ID prefix don't collide
+// An empty containerName gets stats for all containers
+func (e *SystemCommandExecutor) statsArgs(format, containerName string) []string {
+	args := []string{"stats", "--no-stream"}
+	if e.config.Containers.NoTrunc {
+		args = append(args, "--no-trunc")
+	}
+	args = append(args, "--format", format)
+	if containerName != "" {
+		args = append(args, containerName)
+	}
+	return args
+}
+
+// ListDockerContainers lists the names of running Docker containers, one per line
+// The command it runs is:
+// - docker ps --format {{.Names}}
+func (e *SystemCommandExecutor) ListDockerContainers(ctx context.Context) ([]byte, error) {
+	return e.executeDocker(ctx, "ps", "--format", "{{.Names}}")
+}
+
+// ListPodmanContainers lists the names of running Podman containers, one per line
+// The command it runs is:
+// - podman ps --format {{.Names}}
+func (e *SystemCommandExecutor) ListPodmanContainers(ctx context.Context) ([]byte, error) {
+	return e.executePodman(ctx, "ps", "--format", "{{.Names}}")
+}
+
+// GetDockerContainerInfo gets the name, image and creation time of running Docker containers, tab separated
+// The command it runs is:
+// - docker ps --format "{{.Names}}\t{{.Image}}\t{{.CreatedAt}}"
+func (e *SystemCommandExecutor) GetDockerContainerInfo(ctx context.Context) ([]byte, error) {
+	return e.executeDocker(ctx, "ps", "--format", "{{.Names}}\t{{.Image}}\t{{.CreatedAt}}")
+}
+
+// GetPodmanContainerInfo gets the name, image and creation time of running Podman containers, tab separated
+// The command it runs is:
+// - podman ps --format "{{.Names}}\t{{.Image}}\t{{.CreatedAt}}"
+func (e *SystemCommandExecutor) GetPodmanContainerInfo(ctx context.Context) ([]byte, error) {
+	return e.executePodman(ctx, "ps", "--format", "{{.Names}}\t{{.Image}}\t{{.CreatedAt}}")
+}
+
+// GetDockerSecurityOptions gets the security options of the Docker daemon, e.g. "[name=seccomp,profile=builtin name=rootless]"
+// The command it runs is:
+// - docker info --format {{.SecurityOptions}}
+func (e *SystemCommandExecutor) GetDockerSecurityOptions(ctx context.Context) ([]byte, error) {
+	return e.executeDocker(ctx, "info", "--format", "{{.SecurityOptions}}")
+}
+
+// GetPodmanRootless gets whether Podman runs rootless, "true" or "false"
+// The command it runs is:
+// - podman info --format {{.Host.Security.Rootless}}
+func (e *SystemCommandExecutor) GetPodmanRootless(ctx context.Context) ([]byte, error) {
+	return e.executePodman(ctx, "info", "--format", "{{.Host.Security.Rootless}}")
+}
+
+// GetDockerServerVersion gets the version of the Docker daemon, failing when the daemon can't be reached
+// The command it runs is:
+// - docker version --format {{.Server.Version}}
+func (e *SystemCommandExecutor) GetDockerServerVersion(ctx context.Context) ([]byte, error) {
+	return e.executeDocker(ctx, "version", "--format", "{{.Server.Version}}")
+}
+
+// GetPodmanVersion gets the version of Podman, failing when its service or storage can't be reached
+// The command it runs is:
+// - podman info --format {{.Version.Version}}
+func (e *SystemCommandExecutor) GetPodmanVersion(ctx context.Context) ([]byte, error) {
+	return e.executePodman(ctx, "info", "--format", "{{.Version.Version}}")
+}
+
+// GetDockerContainerPID gets the host PID of a Docker container's main process, 0 when it isn't running
+// The command it runs is:
+// - docker inspect -f {{.State.Pid}} <name>
+func (e *SystemCommandExecutor) GetDockerContainerPID(ctx context.Context, containerName string) ([]byte, error) {
+	return e.executeDocker(ctx, "inspect", "-f", "{{.State.Pid}}", containerName)
+}
+
+// GetPodmanContainerPID gets the host PID of a Podman container's main process, 0 when it isn't running
+// The command it runs is:
+// - podman inspect -f {{.State.Pid}} <name>
+func (e *SystemCommandExecutor) GetPodmanContainerPID(ctx context.Context, containerName string) ([]byte, error) {
+	return e.executePodman(ctx, "inspect", "-f", "{{.State.Pid}}", containerName)
+}
+
+// GetDockerContainerOOMKilled gets whether a Docker container's last exit was an OOM kill, true or false
+// The command it runs is:
+// - docker inspect -f {{.State.OOMKilled}} <name>
+func (e *SystemCommandExecutor) GetDockerContainerOOMKilled(ctx context.Context, containerName string) ([]byte, error) {
+	return e.executeDocker(ctx, "inspect", "-f", "{{.State.OOMKilled}}", containerName)
+}
+
+// GetPodmanContainerOOMKilled gets whether a Podman container's last exit was an OOM kill, true or false
+// The command it runs is:
+// - podman inspect -f {{.State.OOMKilled}} <name>
+func (e *SystemCommandExecutor) GetPodmanContainerOOMKilled(ctx context.Context, containerName string) ([]byte, error) {
+	return e.executePodman(ctx, "inspect", "-f", "{{.State.OOMKilled}}", containerName)
+}
+
+// executeDocker runs docker with the given args
+// containers.docker_path and containers.docker_extra_args, e.g. ["-H", "unix:///run/user/1000/docker.sock"],
+// select the binary and daemon:
+// - <docker_path> <docker_extra_args...> args...
+func (e *SystemCommandExecutor) executeDocker(ctx context.Context, args ...string) ([]byte, error) {
+	args = append(append([]string{}, e.config.Containers.DockerExtraArgs...), args...)
+	return e.executeRuntime(ctx, e.config.Containers.DockerPath, args...)
+}
+
+// executePodman runs podman with the given args
+// containers.podman_path and containers.podman_extra_args, e.g. ["--url", "unix:///run/user/1000/podman/podman.sock"],
+// select the binary and service the same way as for docker
+// When containers.podman_user is set, podman runs as that user so its rootless containers are visible:
+// - sudo -n -u <user> env XDG_RUNTIME_DIR=/run/user/<uid> podman args...
+// This requires a sudoers rule letting the harvester's user run podman as that user without a password
+func (e *SystemCommandExecutor) executePodman(ctx context.Context, args ...string) ([]byte, error) {
+	podmanPath := e.config.Containers.PodmanPath
+	args = append(append([]string{}, e.config.Containers.PodmanExtraArgs...), args...)
+
+	podmanUser := e.config.Containers.PodmanUser
+	if podmanUser == "" {
+		return e.executeRuntime(ctx, podmanPath, args...)
+	}
+
+	account, err := user.Lookup(podmanUser)
+	if err != nil {
+		return nil, err
 	}
-	return e.Execute(ctx, "podman", "stats", "--no-stream", "--format", "table {{.Name}}\\t{{.CPUPerc}}\\t{{.MemUsage}}\\t{{.NetIO}}\\t{{.BlockIO}}", containerName)
+
+	// Rootless podman locates its runtime state through XDG_RUNTIME_DIR, which sudo doesn't set
+	sudoArgs := []string{"-n", "-u", podmanUser, "env", "XDG_RUNTIME_DIR=/run/user/" + account.Uid, podmanPath}
+	return e.executeRuntime(ctx, "sudo", append(sudoArgs, args...)...)
+}
+
+// runtimeRetryBackoff is the wait before the first retry of an unreachable container runtime, doubled for each next one
+const runtimeRetryBackoff = 250 * time.Millisecond
+
+// runtimeUnreachableMessages are what docker and podman print to stderr when their daemon or socket can't be reached,
+// e.g. while the daemon restarts or the rootless podman socket is being reactivated
+var runtimeUnreachableMessages = []string{
+	"connection refused",
+	"Cannot connect to the Docker daemon",
+	"Cannot connect to Podman",
+}
+
+// executeRuntime runs a docker or podman command, retrying it containers.runtime_retries times with backoff
+// while the runtime is unreachable, so a daemon restart doesn't cost a whole cycle of container metrics.
+// Other failures, like a container that no longer exists, are returned right away.
+// Only the final failure is logged as an error, the attempts before it as retries
+func (e *SystemCommandExecutor) executeRuntime(ctx context.Context, command string, args ...string) ([]byte, error) {
+	backoff := runtimeRetryBackoff
+	for attempt := 1; ; attempt++ {
+		output, err := e.execute(ctx, false, command, args...)
+		if err == nil {
+			return output, nil
+		}
+		if attempt > e.config.Containers.RuntimeRetries || !isRuntimeUnreachable(err) {
+			e.logFailure(command, args, err)
+			return nil, err
+		}
+
+		e.logger.Warn("Container runtime unreachable, retrying",
+			zap.String("command", command),
+			zap.Int("attempt", attempt),
+			zap.Duration("backoff", backoff),
+			zap.Error(err),
+		)
+		select {
+		case <-ctx.Done():
+			e.logFailure(command, args, err)
+			return nil, err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// isRuntimeUnreachable reports whether a docker or podman command failed because its daemon or socket couldn't be
+// reached, from the stderr exec captures in the exit error
+// "no such container" never counts, as the container is gone rather than the runtime briefly away
+func isRuntimeUnreachable(err error) bool {
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return false
+	}
+
+	stderr := string(exitErr.Stderr)
+	if strings.Contains(strings.ToLower(stderr), "no such container") {
+		return false
+	}
+	for _, message := range runtimeUnreachableMessages {
+		if strings.Contains(stderr, message) {
+			return true
+		}
+	}
+	return false
 }
 
 // GetNetworkStats gets network stats
@@ -113,11 +520,89 @@ func (e *SystemCommandExecutor) GetNetworkStats(ctx context.Context) ([]byte, er
 	return e.Execute(ctx, "netstat", "-i")
 }
 
+// GetInterfaceLinkStats gets the statistics of every interface from the kernel's netlink interface,
+// which still works where /proc/net/dev is restricted
+// The command it runs is:
+// - ip -s link
+func (e *SystemCommandExecutor) GetInterfaceLinkStats(ctx context.Context) ([]byte, error) {
+	return e.Execute(ctx, "ip", "-s", "link")
+}
+
+// GetInterfaceStates gets the operational state and flags of interfaces, one "path:value" line per file
+// grep exits 2 when a file can't be read, e.g. of an interface that vanished since the listing, while still
+// printing the others, so that is a result as long as there is output
+// The command it runs is:
+// - grep -H . /sys/class/net/<iface>/operstate /sys/class/net/<iface>/flags ...
+func (e *SystemCommandExecutor) GetInterfaceStates(ctx context.Context, ifaces []string) ([]byte, error) {
+	args := []string{"-H", "."}
+	for _, iface := range ifaces {
+		args = append(args, "/sys/class/net/"+iface+"/operstate", "/sys/class/net/"+iface+"/flags")
+	}
+	output, err := e.execute(ctx, false, "grep", args...)
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 2 && len(output) > 0 {
+		return output, nil
+	}
+	if err != nil {
+		e.logFailure("grep", args, err)
+	}
+	return output, err
+}
+
+// GetQdiscStats gets the statistics of the qdiscs attached to an interface
+// The command it runs is:
+// - tc -s qdisc show dev <iface>
+func (e *SystemCommandExecutor) GetQdiscStats(ctx context.Context, iface string) ([]byte, error) {
+	return e.Execute(ctx, "tc", "-s", "qdisc", "show", "dev", iface)
+}
+
+// GetInterfaceQueues lists the rx-<n> and tx-<n> queue directories of an interface
+// The command it runs is:
+// - ls /sys/class/net/<iface>/queues
+func (e *SystemCommandExecutor) GetInterfaceQueues(ctx context.Context, iface string) ([]byte, error) {
+	return e.Execute(ctx, "ls", "/sys/class/net/"+iface+"/queues")
+}
+
+// GetInterfaceDriverStats gets the driver statistics of an interface, which include per-queue counters on multiqueue NICs
+// The command it runs is:
+// - ethtool -S <iface>
+func (e *SystemCommandExecutor) GetInterfaceDriverStats(ctx context.Context, iface string) ([]byte, error) {
+	return e.Execute(ctx, "ethtool", "-S", iface)
+}
+
 // PingHost pings a host
+// -W takes whole seconds on Linux (iputils and BusyBox) and milliseconds on macOS
 // The command it runs is:
-// - ping -c count host
+// - <network.ping_path> -c count -W <network.ping_timeout> host
 func (e *SystemCommandExecutor) PingHost(ctx context.Context, host string, count int) ([]byte, error) {
-	return e.Execute(ctx, "ping", "-c", strconv.Itoa(count), host)
+	timeout := e.config.Network.PingTimeout.Duration
+	wait := strconv.Itoa(max(1, int(math.Ceil(timeout.Seconds()))))
+	if runtime.GOOS == "darwin" {
+		wait = strconv.FormatInt(timeout.Milliseconds(), 10)
+	}
+	return e.Execute(ctx, e.config.Network.PingPath, "-c", strconv.Itoa(count), "-W", wait, host)
+}
+
+// PingHosts pings several hosts in parallel with a single fping
+// The per-reply lines are on stdout and the per-host summaries on stderr, so both are returned.
+// fping exits 1 when some host didn't reply and 2 when some host name didn't resolve, which are results of the
+// other hosts rather than failures
+// The command it runs is:
+// - fping -c count -t <network.ping_timeout in ms> host...
+func (e *SystemCommandExecutor) PingHosts(ctx context.Context, hosts []string, count int) ([]byte, error) {
+	args := []string{"-c", strconv.Itoa(count), "-t", strconv.FormatInt(e.config.Network.PingTimeout.Milliseconds(), 10)}
+	args = append(args, hosts...)
+	output, err := e.execute(ctx, true, "fping", args...)
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && (exitErr.ExitCode() == 1 || exitErr.ExitCode() == 2) {
+		return output, nil
+	}
+	if err != nil {
+		e.logFailure("fping", args, err)
+	}
+	return output, err
 }
 
 // GetProcessInfo gets process info
@@ -134,14 +619,15 @@ func (e *SystemCommandExecutor) GetSystemUptime(ctx context.Context) ([]byte, er
 	return e.Execute(ctx, "uptime")
 }
 
-// GetDiskUsage gets disk usage
+// GetDiskUsage gets disk usage in 1K blocks
+// -k is used rather than -h so the sizes are numeric, and is supported by both GNU and BSD df
 // The command it runs is:
-// - df -h /
+// - df -k /
 func (e *SystemCommandExecutor) GetDiskUsage(ctx context.Context, path string) ([]byte, error) {
 	if path == "" {
 		path = "/"
 	}
-	return e.Execute(ctx, "df", "-h", path)
+	return e.Execute(ctx, "df", "-k", path)
 }
 
 // ParseCommandOutput provides utilities to parse common command outputs
@@ -0,0 +1,64 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"testing"
+)
+
+func TestIsRuntimeUnreachable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{
+			name: "docker daemon down",
+			err:  &exec.ExitError{Stderr: []byte("Cannot connect to the Docker daemon at unix:///var/run/docker.sock. Is the docker daemon running?\n")},
+			want: true,
+		},
+		{
+			name: "podman socket down",
+			err:  &exec.ExitError{Stderr: []byte("Error: Cannot connect to Podman. Please verify your connection to the Linux system\n")},
+			want: true,
+		},
+		{
+			name: "connection refused",
+			err:  &exec.ExitError{Stderr: []byte("dial unix /run/user/1000/podman/podman.sock: connect: connection refused\n")},
+			want: true,
+		},
+		{
+			name: "wrapped",
+			err:  fmt.Errorf("docker stats: %w", &exec.ExitError{Stderr: []byte("connection refused")}),
+			want: true,
+		},
+		{
+			name: "no such container",
+			err:  &exec.ExitError{Stderr: []byte("Error response from daemon: No such container: web\n")},
+		},
+		{
+			name: "no such container while the socket is refused",
+			err:  &exec.ExitError{Stderr: []byte("Error: no such container web: connection refused\n")},
+		},
+		{
+			name: "other failure",
+			err:  &exec.ExitError{Stderr: []byte("Error: unknown flag: --no-trunc\n")},
+		},
+		{
+			name: "not an exit error",
+			err:  errors.New("exec: \"docker\": executable file not found in $PATH"),
+		},
+		{
+			name: "no error",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isRuntimeUnreachable(tt.err); got != tt.want {
+				t.Errorf("isRuntimeUnreachable() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
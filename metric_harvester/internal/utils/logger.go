@@ -0,0 +1,76 @@
+package utils
+
+import (
+	"metric_harvester/internal/config"
+	"strings"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// NewLogger creates the harvester's logger at logging.level
+// logging.format json uses zap's production config, console its development config with stack traces on warnings
+// Loggers named after a subsystem, e.g. logger.Named("executor"), log at their logging.levels entry instead,
+// so a single area can be debugged without the container parsing output of the others
+// Args:
+// - cfg: *config.Config
+// Returns:
+// - *zap.Logger: new logger
+// - error: error if the logger can't be built
+func NewLogger(cfg *config.Config) (*zap.Logger, error) {
+	// Both were validated when the configuration was loaded
+	level, _ := zapcore.ParseLevel(cfg.Logging.Level)
+	levels := make(map[string]zapcore.Level, len(cfg.Logging.Levels))
+	lowest := level
+	for name, value := range cfg.Logging.Levels {
+		subsystemLevel, _ := zapcore.ParseLevel(value)
+		levels[name] = subsystemLevel
+		lowest = min(lowest, subsystemLevel)
+	}
+
+	// The underlying core lets everything through down to the lowest configured level, the wrapper filters per subsystem
+	// Sampling is disabled so the debug output of a subsystem isn't thinned out
+	zapConfig := zap.NewProductionConfig()
+	zapConfig.Sampling = nil
+	if cfg.Logging.Format == config.LogFormatConsole {
+		zapConfig = zap.NewDevelopmentConfig()
+	}
+	zapConfig.Level = zap.NewAtomicLevelAt(lowest)
+	return zapConfig.Build(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		return &subsystemCore{Core: core, level: level, levels: levels}
+	}))
+}
+
+// subsystemCore filters entries by the level of the logger's subsystem
+type subsystemCore struct {
+	zapcore.Core
+	level  zapcore.Level
+	levels map[string]zapcore.Level
+}
+
+func (c *subsystemCore) With(fields []zapcore.Field) zapcore.Core {
+	return &subsystemCore{Core: c.Core.With(fields), level: c.level, levels: c.levels}
+}
+
+func (c *subsystemCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if !c.levelFor(entry.LoggerName).Enabled(entry.Level) {
+		return checked
+	}
+	return c.Core.Check(entry, checked)
+}
+
+// levelFor returns the level of a logger name, falling back to its parents, e.g. "executor.ssh" to "executor",
+// and to logging.level for loggers outside any configured subsystem
+func (c *subsystemCore) levelFor(name string) zapcore.Level {
+	for name != "" {
+		if level, ok := c.levels[name]; ok {
+			return level
+		}
+		index := strings.LastIndex(name, ".")
+		if index < 0 {
+			break
+		}
+		name = name[:index]
+	}
+	return c.level
+}
@@ -0,0 +1,34 @@
+package version
+
+import "runtime"
+
+// Build metadata, set at build time with:
+// go build -ldflags "-X metric_harvester/internal/version.Version=v1.2.3 -X metric_harvester/internal/version.Commit=$(git rev-parse HEAD) -X metric_harvester/internal/version.BuildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+var (
+	Version   = "dev"
+	Commit    = "unknown"
+	BuildTime = "unknown"
+)
+
+// Info is the build metadata of the running binary
+// Comparing it across the rootful and rootless hosts confirms both run the same harvester build
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildTime string `json:"build_time"`
+	GoVersion string `json:"go_version"`
+	GOOS      string `json:"goos"`
+	GOARCH    string `json:"goarch"`
+}
+
+// Get returns the build metadata of the running binary
+func Get() Info {
+	return Info{
+		Version:   Version,
+		Commit:    Commit,
+		BuildTime: BuildTime,
+		GoVersion: runtime.Version(),
+		GOOS:      runtime.GOOS,
+		GOARCH:    runtime.GOARCH,
+	}
+}
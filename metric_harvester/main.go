@@ -2,11 +2,19 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"metric_harvester/internal/config"
 	"metric_harvester/internal/server"
 	"metric_harvester/internal/utils"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
 
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
 	"go.uber.org/fx"
 	"go.uber.org/fx/fxevent"
 	"go.uber.org/zap"
@@ -14,32 +22,64 @@ import (
 
 var configPath = "internal/config/configurations.json"
 
+// providers provides the dependencies shared by the long-running server and --once
+var providers = fx.Provide(
+	// Provide logger at the configured levels
+	utils.NewLogger,
+	// Load configuration from JSON file to config.Config
+	func() *config.Config {
+		cfg, err := config.LoadFromJSON(configPath)
+		if err != nil {
+			panic(fmt.Sprintf("Failed to load configuration: %v", err))
+		}
+		return cfg
+	},
+	// Provide system command executor using logger and config
+	utils.NewSystemCommandExecutor,
+	// Provide Docker API executor only when a docker socket is configured
+	func(cfg *config.Config, logger *zap.Logger) *utils.DockerAPIExecutor {
+		if cfg.Containers.DockerSocket == "" {
+			return nil
+		}
+		return utils.NewDockerAPIExecutor(cfg.Containers.DockerSocket, logger)
+	},
+	// Provide ServerParams using config, logger and executors
+	func(cfg *config.Config, logger *zap.Logger, executor *utils.SystemCommandExecutor, dockerAPI *utils.DockerAPIExecutor) *server.ServerParams {
+		return &server.ServerParams{
+			Config:    cfg,
+			Logger:    logger,
+			Executor:  executor,
+			DockerAPI: dockerAPI,
+		}
+	},
+	server.New,
+)
+
 func main() {
+	once := flag.Bool("once", false, "collect all metrics once, write them to stdout and exit")
+	format := flag.String("format", "prom", "output format of --once: prom or csv")
+	report := flag.Bool("report", false, "write a Markdown comparison of the two metric snapshots given as arguments, baseline first, and exit")
+	flag.Parse()
+
+	if *report {
+		if err := runReport(flag.Args()); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to write the comparison report: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *once {
+		if err := runOnce(*format); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to collect metrics once: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	app := fx.New(
 		// Provide dependencies
-		fx.Provide(
-			// Provide logger
-			zap.NewDevelopment,
-			// Load configuration from JSON file to config.Config
-			func() *config.Config {
-				cfg, err := config.LoadFromJSON(configPath)
-				if err != nil {
-					panic(fmt.Sprintf("Failed to load configuration: %v", err))
-				}
-				return cfg
-			},
-			// Provide system command executor using logger
-			utils.NewSystemCommandExecutor,
-			// Provide ServerParams using config, logger and executor
-			func(cfg *config.Config, logger *zap.Logger, executor *utils.SystemCommandExecutor) *server.ServerParams {
-				return &server.ServerParams{
-					Config:   cfg,
-					Logger:   logger,
-					Executor: executor,
-				}
-			},
-			server.New,
-		),
+		providers,
 
 		// Invoke startup functions
 		fx.Invoke(
@@ -57,6 +97,7 @@ func main() {
 					OnStop: server.Stop,
 				})
 			},
+			registerMetricsDumpSignal,
 		),
 
 		// Configure logging
@@ -67,3 +108,100 @@ func main() {
 
 	app.Run()
 }
+
+// registerMetricsDumpSignal writes a snapshot of the current metrics on SIGUSR1
+// This preserves a point-in-time capture for post-mortems when no scraper is attached
+func registerMetricsDumpSignal(lifecycle fx.Lifecycle, server *server.Server, cfg *config.Config, logger *zap.Logger) {
+	signals := make(chan os.Signal, 1)
+	done := make(chan struct{})
+
+	lifecycle.Append(fx.Hook{
+		OnStart: func(ctx context.Context) error {
+			signal.Notify(signals, syscall.SIGUSR1)
+			go func() {
+				for {
+					select {
+					case <-done:
+						return
+					case <-signals:
+						path, err := server.DumpMetrics(context.Background(), cfg.Benchmarking.ResultsPath)
+						if err != nil {
+							logger.Error("Failed to dump metrics", zap.Error(err))
+							continue
+						}
+						logger.Info("Dumped metrics", zap.String("path", path))
+					}
+				}
+			}()
+			return nil
+		},
+		OnStop: func(ctx context.Context) error {
+			signal.Stop(signals)
+			close(done)
+			return nil
+		},
+	})
+}
+
+// runOnce collects every metric a single time and writes them to stdout in the given format, prom or csv
+// It skips the HTTP server and ticker, e.g. to record a comparison run from a script
+func runOnce(format string) error {
+	sink, err := server.NewWriterSink(os.Stdout, format)
+	if err != nil {
+		return err
+	}
+
+	app := fx.New(
+		providers,
+		fx.Invoke(func(harvester *server.Server) error {
+			return harvester.CollectOnce(context.Background(), sink)
+		}),
+		fx.NopLogger,
+	)
+	return app.Err()
+}
+
+// runReport writes a Markdown comparison of two metric snapshots to stdout, see server.WriteComparisonReport
+// The snapshots are Prometheus text files as written by --once or the SIGUSR1 dump, e.g. one from each host of a
+// rootful vs rootless run. The columns are named after the files without their extension
+func runReport(paths []string) error {
+	if len(paths) != 2 {
+		return fmt.Errorf("expected two snapshot files, baseline and candidate, got %d", len(paths))
+	}
+
+	baseline, err := readSnapshot(paths[0])
+	if err != nil {
+		return err
+	}
+	candidate, err := readSnapshot(paths[1])
+	if err != nil {
+		return err
+	}
+	return server.WriteComparisonReport(os.Stdout, snapshotName(paths[0]), snapshotName(paths[1]), baseline, candidate)
+}
+
+// readSnapshot reads a metric snapshot in the Prometheus text format
+func readSnapshot(path string) ([]*dto.MetricFamily, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var parser expfmt.TextParser
+	byName, err := parser.TextToMetricFamilies(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	families := make([]*dto.MetricFamily, 0, len(byName))
+	for _, family := range byName {
+		families = append(families, family)
+	}
+	return families, nil
+}
+
+// snapshotName names a snapshot after its file, e.g. rootless for results/rootless.prom
+func snapshotName(path string) string {
+	name := filepath.Base(path)
+	return strings.TrimSuffix(name, filepath.Ext(name))
+}